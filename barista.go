@@ -16,6 +16,7 @@
 package barista
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"image/color"
@@ -24,9 +25,11 @@ import (
 	"os/exec"
 	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/dbus"
 	"github.com/leosunmo/barista/core"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/oauth"
@@ -56,8 +59,16 @@ type i3Bar struct {
 	// The list of modules that make up this bar.
 	modules   []bar.Module
 	moduleSet *core.ModuleSet
-	// A map of previously set click handlers for each segment.
-	clickHandlers map[string]func(bar.Event)
+	// The encoded output and click handlers for each module, so that
+	// only modules that have updated need to be serialised again.
+	caches []moduleCache
+	// The modules that have updated since the bar was last printed.
+	// Guarded by the mutex, since modules update on their own goroutines.
+	dirty []bool
+	// The last frame written to the output stream, and a scratch buffer
+	// for the next frame, used to skip printing identical frames.
+	lastFrame []byte
+	frame     []byte
 	// The function to call when an error segment is right-clicked.
 	errorHandler func(bar.ErrorEvent)
 	// The channel that receives a signal on module updates.
@@ -68,8 +79,6 @@ type i3Bar struct {
 	reader io.Reader
 	// The Writer to write bar output to (e.g. stdout)
 	writer io.Writer
-	// A json encoder set to write to the output stream.
-	encoder *json.Encoder
 	// Flipped when Run() is called, to prevent issues with modules
 	// being added after the bar has been started.
 	started bool
@@ -80,6 +89,11 @@ type i3Bar struct {
 	// whether it needs to be refreshed on resume.
 	paused          bool
 	refreshOnResume bool
+	// Whether the system is suspended, and the bus used to watch for
+	// system sleep. Timing is paused if either the bar is paused or
+	// the system is suspended.
+	sleeping bool
+	sleepBus dbus.BusType
 	// For testing, output the associated error in the json as well.
 	// This allows output tester to accurately check for errors.
 	includeErrorsInOutput bool
@@ -94,8 +108,18 @@ const (
 	dEvtPaused debugEventKind = 1 << iota
 	dEvtResumed
 	dEvtModuleStopped
+	dEvtSleep
+	dEvtWake
 )
 
+// moduleCache holds the serialised output of a single module.
+type moduleCache struct {
+	// Comma-separated JSON objects, one for each segment.
+	json []byte
+	// Click handlers for each segment, nil if the segment has none.
+	clickHandlers []func(bar.Event)
+}
+
 // debugEvent is used for tests to synchronise on some events that
 // are otherwise extremely hard to user.
 type debugEvent struct {
@@ -117,6 +141,7 @@ func construct() {
 			paused: true,
 			// Default to i3-nagbar when right-clicking errors.
 			errorHandler: DefaultErrorHandler,
+			sleepBus:     dbus.System,
 		}
 	})
 }
@@ -176,14 +201,16 @@ func Run(modules ...bar.Module) error {
 
 	b.modules = append(b.modules, modules...)
 	b.moduleSet = core.NewModuleSet(b.modules)
+	b.caches = make([]moduleCache, b.moduleSet.Len())
+	b.dirty = make([]bool, b.moduleSet.Len())
 
 	// Mark the bar as started.
 	b.started = true
 	l.Log("Bar started")
 
 	go func(i <-chan int) {
-		for range i {
-			b.refresh()
+		for idx := range i {
+			b.refresh(idx)
 		}
 	}(b.moduleSet.Stream())
 
@@ -205,10 +232,7 @@ func Run(modules ...bar.Module) error {
 		header.StopSignal = int(unix.SIGUSR1)
 		header.ContSignal = int(unix.SIGUSR2)
 	}
-	// Set up the encoder for the output stream,
-	// so that module outputs can be written directly.
-	b.encoder = json.NewEncoder(b.writer)
-	if err := b.encoder.Encode(&header); err != nil {
+	if err := json.NewEncoder(b.writer).Encode(&header); err != nil {
 		return err
 	}
 	// Start the infinite array.
@@ -216,6 +240,10 @@ func Run(modules ...bar.Module) error {
 		return err
 	}
 
+	if b.sleepBus != nil {
+		b.watchSleep(b.sleepBus)
+	}
+
 	// Bar starts paused, so resume it to get the initial output.
 	b.resume()
 
@@ -223,12 +251,13 @@ func Run(modules ...bar.Module) error {
 	for {
 		select {
 		case <-b.update:
-			// The complete bar needs to printed on each update.
+			// The complete bar needs to printed on each update,
+			// but only updated modules need to be serialised.
 			if err := b.print(); err != nil {
 				return err
 			}
 		case event := <-b.events:
-			if onClick, ok := b.clickHandlers[event.Name]; ok {
+			if onClick := b.clickHandler(event.Name); onClick != nil {
 				go onClick(event.Event)
 			}
 		case sig := <-signalChan:
@@ -296,44 +325,106 @@ func i3map(s *bar.Segment) map[string]interface{} {
 }
 
 // print outputs the entire bar, using the last output for each module.
+// Only modules that have updated since the last print are serialised again,
+// and the bar is not printed at all if the output would be unchanged.
 func (b *i3Bar) print() error {
-	// Store the set of click handlers for any segments that can handle clicks.
-	// When i3bar sends us the click event, it will include an identifier that
-	// we can use to look up the function to call.
-	b.clickHandlers = map[string]func(bar.Event){}
+	b.Lock()
+	var updated []int
+	for idx, dirty := range b.dirty {
+		if dirty {
+			updated = append(updated, idx)
+			b.dirty[idx] = false
+		}
+	}
+	b.Unlock()
+	for _, idx := range updated {
+		if err := b.encodeModule(idx); err != nil {
+			return err
+		}
+	}
 	// i3bar requires the entire bar to be printed at once, so we just take the
-	// last cached value for each module and construct the current bar.
-	output := make([]map[string]interface{}, 0)
-	for _, segments := range b.moduleSet.LastOutputs() {
-		for _, segment := range segments {
-			out := i3map(segment)
-			var clickHandler func(bar.Event)
-			if err := segment.GetError(); err != nil {
-				// because go.
-				segment := segment
-				clickHandler = func(e bar.Event) {
-					if e.Button == bar.ButtonRight {
-						b.errorHandler(bar.ErrorEvent{Error: err, Event: e})
-					} else {
-						segment.Click(e)
-					}
+	// cached output for each module and construct the current bar.
+	frame := append(b.frame[:0], '[')
+	needsComma := false
+	for _, c := range b.caches {
+		if len(c.json) == 0 {
+			continue
+		}
+		if needsComma {
+			frame = append(frame, ',')
+		}
+		frame = append(frame, c.json...)
+		needsComma = true
+	}
+	frame = append(frame, "]\n,\n"...)
+	if bytes.Equal(frame, b.lastFrame) {
+		l.Fine("Skipping identical output")
+		b.frame = frame
+		return nil
+	}
+	b.frame, b.lastFrame = b.lastFrame, frame
+	_, err := b.writer.Write(frame)
+	return err
+}
+
+// encodeModule serialises the last output of the module at the given index,
+// and stores the click handlers for any segments that can handle clicks.
+func (b *i3Bar) encodeModule(idx int) error {
+	c := &b.caches[idx]
+	segments := b.moduleSet.LastOutput(idx)
+	c.json = c.json[:0]
+	c.clickHandlers = make([]func(bar.Event), len(segments))
+	for segIdx, segment := range segments {
+		out := i3map(segment)
+		var clickHandler func(bar.Event)
+		if err := segment.GetError(); err != nil {
+			// because go.
+			segment := segment
+			clickHandler = func(e bar.Event) {
+				if e.Button == bar.ButtonRight {
+					b.errorHandler(bar.ErrorEvent{Error: err, Event: e})
+				} else {
+					segment.Click(e)
 				}
-			} else if segment.HasClick() {
-				clickHandler = segment.Click
 			}
-			if clickHandler != nil {
-				name := strconv.Itoa(len(b.clickHandlers))
-				out["name"] = name
-				b.clickHandlers[name] = clickHandler
-			}
-			output = append(output, out)
+		} else if segment.HasClick() {
+			clickHandler = segment.Click
+		}
+		if clickHandler != nil {
+			// When i3bar sends us the click event, it will include this
+			// identifier that we can use to look up the function to call.
+			out["name"] = "m/" + strconv.Itoa(idx) + "/" + strconv.Itoa(segIdx)
+			c.clickHandlers[segIdx] = clickHandler
+		}
+		encoded, err := json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		if segIdx > 0 {
+			c.json = append(c.json, ',')
 		}
+		c.json = append(c.json, encoded...)
 	}
-	if err := b.encoder.Encode(output); err != nil {
-		return err
+	return nil
+}
+
+// clickHandler returns the click handler for the segment identified by
+// name, or nil if there is no such segment or it does not handle clicks.
+func (b *i3Bar) clickHandler(name string) func(bar.Event) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 || parts[0] != "m" {
+		return nil
 	}
-	_, err := io.WriteString(b.writer, ",\n")
-	return err
+	idx, err := strconv.Atoi(parts[1])
+	if err != nil || idx < 0 || idx >= len(b.caches) {
+		return nil
+	}
+	handlers := b.caches[idx].clickHandlers
+	segIdx, err := strconv.Atoi(parts[2])
+	if err != nil || segIdx < 0 || segIdx >= len(handlers) {
+		return nil
+	}
+	return handlers[segIdx]
 }
 
 // readEvents parses the infinite stream of events received from i3.
@@ -377,7 +468,9 @@ func (b *i3Bar) resume() {
 		return
 	}
 	b.paused = false
-	timing.Resume()
+	if !b.sleeping {
+		timing.Resume()
+	}
 	if b.refreshOnResume {
 		b.refreshOnResume = false
 		b.maybeUpdate()
@@ -385,10 +478,12 @@ func (b *i3Bar) resume() {
 	b.emitDebugEvent(dEvtResumed, "")
 }
 
-// refresh requests an update of the bar's output.
-func (b *i3Bar) refresh() {
+// refresh requests an update of the bar's output, marking the module at
+// the given index as updated.
+func (b *i3Bar) refresh(idx int) {
 	b.Lock()
 	defer b.Unlock()
+	b.dirty[idx] = true
 	// If paused, defer the refresh until the bar resumes.
 	if b.paused {
 		l.Fine("Refresh on next resume")
@@ -429,4 +524,5 @@ func TestMode(reader io.Reader, writer io.Writer) {
 	instance.reader = reader
 	instance.writer = writer
 	instance.includeErrorsInOutput = true
+	instance.sleepBus = nil
 }
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/dbus"
 	"github.com/leosunmo/barista/outputs"
 	"github.com/leosunmo/barista/testing/mockio"
 	testModule "github.com/leosunmo/barista/testing/module"
@@ -116,6 +117,10 @@ func TestSingleModule(t *testing.T) {
 	require.Equal(t, []string{"other"}, out,
 		"output updates when module sends an update")
 
+	module.OutputText("other")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"identical output is not printed again")
+
 	require.Panics(t,
 		func() { Add(testModule.New(t)) },
 		"adding a module to a running bar")
@@ -144,8 +149,8 @@ func TestEmptyOutputs(t *testing.T) {
 
 	module2.AssertStarted()
 	module2.Output(nil)
-	out = readOutputTexts(t, mockStdout)
-	require.Empty(t, out, "all modules are empty")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"identical empty output is not printed again")
 }
 
 func TestMultipleModules(t *testing.T) {
@@ -330,6 +335,99 @@ func TestPauseResume(t *testing.T) {
 		"Partial updates while paused")
 }
 
+type refresherModule struct {
+	*testModule.TestModule
+	refreshed chan struct{}
+}
+
+func (r refresherModule) Refresh() {
+	r.refreshed <- struct{}{}
+}
+
+func TestSleepWake(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	bus := dbus.SetupTestBus()
+	logind := bus.RegisterService(logindService).Object(logindPath, logindManager)
+	instance.sleepBus = dbus.Test
+	evts := debugEvents(dEvtPaused, dEvtResumed, dEvtSleep, dEvtWake)
+	// Bars from previous tests are still running and handling signals, so
+	// pause and resume this bar directly instead.
+	b := instance
+
+	module1 := refresherModule{testModule.New(t), make(chan struct{}, 1)}
+	module2 := testModule.New(t)
+	go Run(module1, module2)
+	require.Equal(t, dEvtResumed, (<-evts).kind)
+	module1.AssertStarted()
+	module2.AssertStarted()
+
+	logind.Emit("PrepareForSleep", true)
+	require.Equal(t, dEvtSleep, (<-evts).kind)
+	sch := timing.NewScheduler().After(time.Millisecond)
+	select {
+	case <-sch.C:
+		require.Fail(t, "Scheduler triggered while system is asleep")
+	case <-time.After(10 * time.Millisecond): // test passed
+	}
+
+	// Resuming the bar while asleep should not resume timing.
+	b.pause()
+	require.Equal(t, dEvtPaused, (<-evts).kind)
+	b.resume()
+	require.Equal(t, dEvtResumed, (<-evts).kind)
+	select {
+	case <-sch.C:
+		require.Fail(t, "Scheduler triggered while system is asleep")
+	case <-time.After(10 * time.Millisecond): // test passed
+	}
+
+	logind.Emit("PrepareForSleep", false)
+	require.Equal(t, dEvtWake, (<-evts).kind)
+	select {
+	case <-sch.C:
+	case <-time.After(time.Second):
+		require.Fail(t, "Scheduler not triggered after system wakes")
+	}
+	select {
+	case <-module1.refreshed:
+	case <-time.After(time.Second):
+		require.Fail(t, "Module not refreshed after system wakes")
+	}
+
+	logind.Emit("PrepareForSleep", false)
+	select {
+	case <-evts:
+		require.Fail(t, "Waking an awake system is a nop")
+	case <-module1.refreshed:
+		require.Fail(t, "Waking an awake system is a nop")
+	case <-time.After(10 * time.Millisecond): // test passed.
+	}
+
+	// Sleeping while the bar is paused should not resume timing on wake.
+	b.pause()
+	require.Equal(t, dEvtPaused, (<-evts).kind)
+	logind.Emit("PrepareForSleep", true)
+	require.Equal(t, dEvtSleep, (<-evts).kind)
+	logind.Emit("PrepareForSleep", false)
+	require.Equal(t, dEvtWake, (<-evts).kind)
+	<-module1.refreshed
+	sch.After(time.Millisecond)
+	select {
+	case <-sch.C:
+		require.Fail(t, "Scheduler triggered while bar is paused")
+	case <-time.After(10 * time.Millisecond): // test passed
+	}
+	b.resume()
+	require.Equal(t, dEvtResumed, (<-evts).kind)
+	select {
+	case <-sch.C:
+	case <-time.After(time.Second):
+		require.Fail(t, "Scheduler not triggered after bar is resumed")
+	}
+}
+
 func TestClickEvents(t *testing.T) {
 	mockStdin := mockio.Stdin()
 	mockStdout := mockio.Stdout()
@@ -402,7 +500,8 @@ func TestClickEvents(t *testing.T) {
 	module2.AssertClicked("events are received after the weird name")
 
 	module1.Close()
-	readOutput(t, mockStdout)
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"identical output is not printed again on module close")
 
 	mockStdin.WriteString(fmt.Sprintf("{\"name\": \"%s\"},", module2Name))
 	module2.AssertClicked()
@@ -522,8 +621,8 @@ func TestErrorHandling(t *testing.T) {
 		"click events do not cause any updates")
 
 	module.Close()
-	require.Equal(t, []string{"Error", "regular", "other"}, readOutputTexts(t, mockStdout),
-		"new outputs on module close (for updated click handling)")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"identical output is not printed again on module close")
 
 	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s", "button": 3},`, errorSegmentName))
 	module.AssertNotClicked("on right click of error segment")
@@ -544,8 +643,8 @@ func TestErrorHandling(t *testing.T) {
 	require.Equal(t, 3, len(out), "All segments in output")
 
 	module.Close()
-	out = readOutput(t, mockStdout)
-	require.Equal(t, 3, len(out), "All segments in output")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"identical output is not printed again on module close")
 
 	regularSegmentName = out[1]["name"].(string)
 	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s", "button": 1},`, regularSegmentName))
@@ -0,0 +1,73 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package helper runs user-configured commands that change system settings,
+// which usually requires root, e.g. through pkexec or sudo.
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// ErrNotConfigured is returned when running a helper that was not set.
+var ErrNotConfigured = errors.New("no helper configured")
+
+// Command is a helper command, along with any arguments that are always
+// passed to it, e.g. {"pkexec", "/usr/local/bin/battery-helper"}.
+type Command []string
+
+// New constructs a helper command.
+func New(cmd string, args ...string) Command {
+	return append(Command{cmd}, args...)
+}
+
+// Run runs the helper with the given arguments appended. If the helper
+// fails, the error includes its output, which usually explains why.
+func (c Command) Run(args ...string) error {
+	if len(c) == 0 {
+		return ErrNotConfigured
+	}
+	runMu.RLock()
+	run := runCommand
+	runMu.RUnlock()
+	return run(c[0], append(append([]string(nil), c[1:]...), args...)...)
+}
+
+var (
+	runMu      sync.RWMutex
+	runCommand = execCommand
+)
+
+func execCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+// SetRunnerForTest replaces the function used to run helper commands, so
+// that tests don't run real commands. Passing nil restores the default.
+func SetRunnerForTest(run func(name string, args ...string) error) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	if run == nil {
+		run = execCommand
+	}
+	runCommand = run
+}
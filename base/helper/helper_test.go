@@ -0,0 +1,44 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	require.Equal(t, ErrNotConfigured, Command(nil).Run("foo"))
+
+	require.NoError(t, New("sh", "-c", `test "$0 $1" = "a b"`).Run("a", "b"))
+	err := New("sh", "-c", "echo not allowed >&2; exit 1").Run()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not allowed", "includes output")
+	require.Error(t, New("/does/not/exist").Run(), "without output")
+
+	var got []string
+	SetRunnerForTest(func(name string, args ...string) error {
+		got = append([]string{name}, args...)
+		return nil
+	})
+	defer SetRunnerForTest(nil)
+	cmd := New("pkexec", "helper")
+	require.NoError(t, cmd.Run("governor", "performance"))
+	require.Equal(t, []string{"pkexec", "helper", "governor", "performance"}, got)
+	require.NoError(t, cmd.Run("epp", "power"))
+	require.Equal(t, []string{"pkexec", "helper", "epp", "power"}, got,
+		"fixed arguments are not modified")
+}
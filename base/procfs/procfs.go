@@ -0,0 +1,191 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package procfs provides helpers for reading process information from /proc.
+// All functions take the filesystem to read from, so that modules using them
+// can be tested with an in-memory filesystem.
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+)
+
+// clockTicks is the number of clock ticks per second used by the kernel for
+// times in /proc (USER_HZ), which is 100 on all supported architectures.
+const clockTicks = 100
+
+// Process represents a single process.
+type Process struct {
+	PID  int
+	PPID int
+	// Name is the executable name, truncated by the kernel to 15 characters.
+	Name string
+	// State is the single character state of the process, e.g. 'R' for
+	// running, 'S' for sleeping, or 'Z' for zombie.
+	State byte
+	UID   int
+	// Threads is the number of threads in the process.
+	Threads int
+	// UserTime and SystemTime are the total CPU time spent by the process
+	// in user and kernel mode respectively.
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// StartTime is the time the process was started, relative to boot.
+	StartTime time.Duration
+	// RSS is the resident set size, and is zero for kernel threads.
+	RSS unit.Datasize
+}
+
+// CPUTime returns the total CPU time spent by the process.
+func (p Process) CPUTime() time.Duration {
+	return p.UserTime + p.SystemTime
+}
+
+// PIDs returns the IDs of all processes currently running.
+func PIDs(fs afero.Fs) ([]int, error) {
+	files, err := afero.ReadDir(fs, "/proc")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		if pid, err := strconv.Atoi(f.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// Processes returns all processes currently running. Processes that cannot
+// be read, e.g. because they exited during the scan (ESRCH) or belong to
+// another user (EACCES), are skipped. Only failing to list /proc is an error.
+func Processes(fs afero.Fs) ([]Process, error) {
+	pids, err := PIDs(fs)
+	if err != nil {
+		return nil, err
+	}
+	procs := make([]Process, 0, len(pids))
+	for _, pid := range pids {
+		p, err := ReadProcess(fs, pid)
+		if err != nil {
+			continue
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// ReadProcess reads information about a single process, from both
+// /proc/[pid]/stat and /proc/[pid]/status.
+func ReadProcess(fs afero.Fs, pid int) (Process, error) {
+	p := Process{PID: pid}
+	dir := fmt.Sprintf("/proc/%d/", pid)
+	stat, err := afero.ReadFile(fs, dir+"stat")
+	if err != nil {
+		return p, err
+	}
+	if err := parseStat(string(stat), &p); err != nil {
+		return p, fmt.Errorf("%sstat: %s", dir, err)
+	}
+	f, err := fs.Open(dir + "status")
+	if err != nil {
+		return p, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		colon := strings.IndexByte(s.Text(), ':')
+		if colon < 0 {
+			continue
+		}
+		key := s.Text()[:colon]
+		value := strings.TrimSpace(s.Text()[colon+1:])
+		switch key {
+		case "Name":
+			p.Name = value
+		case "Uid":
+			// Real, effective, saved, and filesystem UIDs.
+			if fields := strings.Fields(value); len(fields) > 0 {
+				p.UID, _ = strconv.Atoi(fields[0])
+			}
+		case "VmRSS":
+			kb, _ := strconv.ParseInt(strings.TrimSuffix(value, " kB"), 10, 64)
+			p.RSS = unit.Datasize(kb) * unit.Kibibyte
+		}
+	}
+	return p, s.Err()
+}
+
+// parseStat parses the contents of /proc/[pid]/stat. See proc(5) for the
+// format.
+func parseStat(stat string, p *Process) error {
+	// The name is in parentheses, and can itself contain spaces or
+	// parentheses, so the fields start after the last closing paren.
+	start, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return fmt.Errorf("malformed: %q", stat)
+	}
+	p.Name = stat[start+1 : end]
+	// fields[0] is field 3 in proc(5), the state.
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return fmt.Errorf("too few fields: %q", stat)
+	}
+	p.State = fields[0][0]
+	var err error
+	num := func(idx int) int64 {
+		if err != nil {
+			return 0
+		}
+		var v int64
+		v, err = strconv.ParseInt(fields[idx-3], 10, 64)
+		return v
+	}
+	ticks := func(idx int) time.Duration {
+		return time.Duration(num(idx)) * time.Second / clockTicks
+	}
+	p.PPID = int(num(4))
+	p.UserTime = ticks(14)
+	p.SystemTime = ticks(15)
+	p.Threads = int(num(20))
+	p.StartTime = ticks(22)
+	return err
+}
+
+// Uptime returns the time since boot, from /proc/uptime.
+func Uptime(fs afero.Fs) (time.Duration, error) {
+	data, err := afero.ReadFile(fs, "/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("/proc/uptime: malformed: %q", data)
+	}
+	secs, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, fs afero.Fs, path, content string) {
+	t.Helper()
+	require.NoError(t, afero.WriteFile(fs, path, []byte(content), 0644))
+}
+
+func TestProcesses(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeFile(t, fs, "/proc/uptime", "12345.67 45678.90\n")
+	writeFile(t, fs, "/proc/stat", "cpu  1 2 3 4\n")
+	writeFile(t, fs, "/proc/1/stat",
+		"1 (systemd) S 0 1 1 0 -1 4194560 1 2 3 4 150 250 5 6 20 0 1 0 10 1000 2000 0\n")
+	writeFile(t, fs, "/proc/1/status", `Name:	systemd
+Umask:	0000
+State:	S (sleeping)
+Uid:	0	0	0	0
+VmRSS:	   12288 kB
+Threads:	1
+`)
+	writeFile(t, fs, "/proc/42/stat",
+		"42 (my (weird) app) R 1 42 42 0 -1 0 0 0 0 0 1000 500 0 0 20 0 4 0 50000 0 0\n")
+	writeFile(t, fs, "/proc/42/status", "Name:\tmy (weird) app\nUid:\t1000\t1000\t1000\t1000\n")
+	// Directory without files, e.g. a process that exited during the scan.
+	require.NoError(t, fs.MkdirAll("/proc/99", 0755))
+
+	pids, err := PIDs(fs)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int{1, 42, 99}, pids)
+
+	procs, err := Processes(fs)
+	require.NoError(t, err)
+	require.Len(t, procs, 2, "exited process skipped")
+
+	systemd := procs[0]
+	require.Equal(t, 1, systemd.PID)
+	require.Equal(t, 0, systemd.PPID)
+	require.Equal(t, "systemd", systemd.Name)
+	require.Equal(t, byte('S'), systemd.State)
+	require.Equal(t, 0, systemd.UID)
+	require.Equal(t, 1, systemd.Threads)
+	require.Equal(t, 1500*time.Millisecond, systemd.UserTime)
+	require.Equal(t, 2500*time.Millisecond, systemd.SystemTime)
+	require.Equal(t, 4*time.Second, systemd.CPUTime())
+	require.Equal(t, 100*time.Millisecond, systemd.StartTime)
+	require.Equal(t, 12*unit.Mebibyte, systemd.RSS)
+
+	app := procs[1]
+	require.Equal(t, "my (weird) app", app.Name)
+	require.Equal(t, byte('R'), app.State)
+	require.Equal(t, 1, app.PPID)
+	require.Equal(t, 1000, app.UID)
+	require.Equal(t, 4, app.Threads)
+	require.Equal(t, 15*time.Second, app.CPUTime())
+	require.Equal(t, 500*time.Second, app.StartTime)
+	require.Equal(t, unit.Datasize(0), app.RSS)
+
+	uptime, err := Uptime(fs)
+	require.NoError(t, err)
+	require.Equal(t, 12345670*time.Millisecond, uptime)
+}
+
+func TestErrors(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_, err := Processes(fs)
+	require.Error(t, err, "without /proc")
+	_, err = Uptime(fs)
+	require.Error(t, err, "without /proc/uptime")
+
+	writeFile(t, fs, "/proc/uptime", "")
+	_, err = Uptime(fs)
+	require.Error(t, err, "empty /proc/uptime")
+
+	writeFile(t, fs, "/proc/7/stat", "7 no-parens R 1")
+	_, err = ReadProcess(fs, 7)
+	require.Error(t, err, "malformed stat")
+
+	writeFile(t, fs, "/proc/7/stat", "7 (short) R 1 2 3")
+	_, err = ReadProcess(fs, 7)
+	require.Error(t, err, "too few fields")
+	procs, err := Processes(fs)
+	require.NoError(t, err, "unreadable process skipped")
+	require.Empty(t, procs)
+
+	writeFile(t, fs, "/proc/7/stat",
+		"7 (bad) R 1 7 7 0 -1 0 0 0 0 0 abc 0 0 0 20 0 1 0 0 0 0\n")
+	_, err = ReadProcess(fs, 7)
+	require.Error(t, err, "non-numeric field")
+}
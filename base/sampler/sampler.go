@@ -0,0 +1,313 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package sampler provides a shared registry for polling system information,
+such as files in /proc and /sys.
+
+Rather than each module (or module instance) running its own timer, all
+samplers share a single scheduler, and sampling times after the first are
+aligned to multiples of each sampler's interval. This means that all samplers with the
+same interval (or multiples of it) wake up together, and any source that is
+due for more than one sampler is only read once per tick, with the result
+fanned out to all samplers that are due.
+
+Typically, a module will create a sampler for its source:
+
+	m.sampler = sampler.New("/proc/meminfo", readMeminfo).Every(3 * time.Second)
+
+and use the Subscribe and Get methods to update its output:
+
+	sub, done := m.sampler.Subscribe()
+	defer done()
+	for range sub {
+		info, err := m.sampler.Get()
+		// update code.
+	}
+*/
+package sampler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/leosunmo/barista/base/value"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/timing"
+)
+
+// ReadFunc reads a single sample from a source.
+type ReadFunc func() (interface{}, error)
+
+// source represents a single system source shared by one or more samplers.
+type source struct {
+	key      string
+	read     ReadFunc
+	samplers map[*Sampler]struct{}
+}
+
+// Sampler represents a subscription to a shared source, sampled at a specific
+// interval. It provides the latest sample, and notifications when a new
+// sample is available.
+type Sampler struct {
+	src *source
+	val value.ErrorValue // of the source's sample type
+
+	// Guarded by the package mutex.
+	interval time.Duration
+	next     time.Time
+	sampled  bool
+}
+
+var (
+	mu        sync.Mutex
+	sources   map[string]*source
+	scheduler *timing.Scheduler
+	stop      chan struct{}
+	stopped   chan struct{}
+)
+
+// construct initialises the shared scheduler. Must be called with mu held.
+func construct() {
+	if scheduler != nil {
+		return
+	}
+	sources = map[string]*source{}
+	scheduler = timing.NewScheduler()
+	stop = make(chan struct{})
+	stopped = make(chan struct{})
+	l.Attach(nil, scheduler, "sampler.scheduler")
+	go func(sch *timing.Scheduler, stop <-chan struct{}, stopped chan<- struct{}) {
+		defer close(stopped)
+		for {
+			policyChanged := timing.IntervalPolicyChanged()
+			select {
+			case <-sch.C:
+				tick(sch)
+			case <-policyChanged:
+				applyIntervalPolicy(sch)
+			case <-stop:
+				return
+			}
+		}
+	}(scheduler, stop, stopped)
+}
+
+// New creates a sampler for the source identified by key, which is usually
+// the path being read. All samplers with the same key share the same source,
+// so they must also use the same read function; New panics if the key is
+// already in use with a different one. Callers that parse the same path
+// differently should add a suffix to the key, e.g. "/proc/stat#cpuusage".
+// The sampler is not polled until an interval is set using Every.
+func New(key string, read ReadFunc) *Sampler {
+	mu.Lock()
+	defer mu.Unlock()
+	construct()
+	src, ok := sources[key]
+	if ok && !sameFunc(src.read, read) {
+		panic(fmt.Errorf("sampler key %q already in use with a different read func", key))
+	}
+	if !ok {
+		src = &source{key: key, read: read, samplers: map[*Sampler]struct{}{}}
+		sources[key] = src
+	}
+	s := &Sampler{src: src}
+	src.samplers[s] = struct{}{}
+	l.Label(s, key)
+	l.Register(s, "val")
+	return s
+}
+
+// sameFunc returns true if both read funcs have the same code. Closures
+// created by the same function literal are considered the same, since they
+// differ only in captured variables, which are usually derived from the key.
+func sameFunc(a, b ReadFunc) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// Every sets the interval at which the source is sampled for this sampler.
+// The first sample is taken one interval later, and subsequent samples at
+// multiples of the interval on the wall clock, so that samplers with the same
+// interval are always sampled together. The interval is adjusted by the
+// current timing.IntervalPolicy, if any.
+func (s *Sampler) Every(interval time.Duration) *Sampler {
+	if interval <= 0 {
+		panic(errors.New("non-positive interval for Sampler#Every"))
+	}
+	l.Fine("%s Every(%v)", l.ID(s), interval)
+	mu.Lock()
+	defer mu.Unlock()
+	construct()
+	if _, ok := sources[s.src.key]; !ok {
+		sources[s.src.key] = s.src
+	}
+	s.src.samplers[s] = struct{}{}
+	s.interval = interval
+	s.next = timing.Now().Add(timing.AdjustInterval(interval))
+	rescheduleLocked()
+	return s
+}
+
+// Stop stops sampling the source for this sampler, and removes it from the
+// registry. Sampling can be resumed by calling Every again.
+func (s *Sampler) Stop() {
+	l.Fine("%s Stop", l.ID(s))
+	mu.Lock()
+	defer mu.Unlock()
+	construct()
+	delete(s.src.samplers, s)
+	s.interval = 0
+	s.next = time.Time{}
+	rescheduleLocked()
+}
+
+// Get returns the latest sample from the source, or the error encountered
+// while reading it. If the sampler has not yet received a sample, the source
+// is read immediately.
+func (s *Sampler) Get() (interface{}, error) {
+	mu.Lock()
+	sampled := s.sampled
+	mu.Unlock()
+	if !sampled {
+		s.Refresh()
+	}
+	return s.val.Get()
+}
+
+// Next returns a channel that will be closed when the next sample is
+// available.
+func (s *Sampler) Next() <-chan struct{} {
+	return s.val.Next()
+}
+
+// Subscribe returns a channel that will receive an empty struct{} each time
+// a sample is available, until it's cleaned up using the done func.
+func (s *Sampler) Subscribe() (sub <-chan struct{}, done func()) {
+	return s.val.Subscribe()
+}
+
+// Refresh reads the source immediately, outside of the regular schedule, and
+// updates this sampler with the new sample. Other samplers for the same
+// source are not affected, and only receive samples on their own schedule.
+func (s *Sampler) Refresh() {
+	l.Fine("%s Refresh", l.ID(s))
+	s.src.sample([]*Sampler{s})
+}
+
+// sample reads the source once and sends the result to the given samplers.
+func (src *source) sample(samplers []*Sampler) {
+	val, err := src.read()
+	l.Fine("sampler: read %s: %v, %v", src.key, val, err)
+	for _, s := range samplers {
+		s.val.SetOrError(val, err)
+	}
+	// Only marked as sampled once the value is stored, otherwise a concurrent
+	// Get could return the zero value.
+	mu.Lock()
+	for _, s := range samplers {
+		s.sampled = true
+	}
+	mu.Unlock()
+}
+
+// tick samples all sources that are due for at least one sampler.
+func tick(sch *timing.Scheduler) {
+	now := timing.Now()
+	due := map[*source][]*Sampler{}
+	mu.Lock()
+	if scheduler != sch {
+		// Discarded by TestMode.
+		mu.Unlock()
+		return
+	}
+	for _, src := range sources {
+		for s := range src.samplers {
+			if s.interval > 0 && !s.next.After(now) {
+				due[src] = append(due[src], s)
+				s.next = nextSample(now, timing.AdjustInterval(s.interval))
+			}
+		}
+	}
+	rescheduleLocked()
+	mu.Unlock()
+	for src, samplers := range due {
+		src.sample(samplers)
+	}
+}
+
+// applyIntervalPolicy recomputes the next sample time of all samplers when
+// the interval policy changes, so that a relaxed policy takes effect
+// immediately rather than after the next (possibly much later) sample.
+func applyIntervalPolicy(sch *timing.Scheduler) {
+	mu.Lock()
+	defer mu.Unlock()
+	if scheduler != sch {
+		// Discarded by TestMode.
+		return
+	}
+	now := timing.Now()
+	for _, src := range sources {
+		for s := range src.samplers {
+			if s.interval > 0 {
+				s.next = nextSample(now, timing.AdjustInterval(s.interval))
+			}
+		}
+	}
+	rescheduleLocked()
+}
+
+// rescheduleLocked sets the shared scheduler to trigger at the earliest time
+// any sampler is due. Must be called with mu held.
+func rescheduleLocked() {
+	var next time.Time
+	for _, src := range sources {
+		for s := range src.samplers {
+			if s.interval > 0 && (next.IsZero() || s.next.Before(next)) {
+				next = s.next
+			}
+		}
+	}
+	if next.IsZero() {
+		scheduler.Stop()
+		return
+	}
+	scheduler.At(next)
+}
+
+// nextSample returns the next multiple of interval strictly after now.
+func nextSample(now time.Time, interval time.Duration) time.Time {
+	return now.Truncate(interval).Add(interval)
+}
+
+// TestMode discards all existing samplers and the shared scheduler, so that
+// samplers created afterwards use a scheduler associated with the current
+// test. It waits for any sample in progress, and should be called after
+// timing.TestMode().
+func TestMode() {
+	mu.Lock()
+	sch, stopCh, stoppedCh := scheduler, stop, stopped
+	scheduler = nil
+	sources = nil
+	stop = nil
+	stopped = nil
+	mu.Unlock()
+	if sch != nil {
+		sch.Close()
+		close(stopCh)
+		<-stoppedCh
+	}
+}
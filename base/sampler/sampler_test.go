@@ -0,0 +1,231 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampler
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/barista/testing/notifier"
+	"github.com/leosunmo/barista/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func countingSource() (read ReadFunc, count func() int64) {
+	var reads int64
+	return func() (interface{}, error) {
+			return atomic.AddInt64(&reads, 1), nil
+		}, func() int64 {
+			return atomic.LoadInt64(&reads)
+		}
+}
+
+func setup() {
+	timing.TestMode()
+	TestMode()
+}
+
+func TestSharedSource(t *testing.T) {
+	setup()
+	read, reads := countingSource()
+	a := New("src", read).Every(3 * time.Second)
+	b := New("src", read).Every(3 * time.Second)
+	c := New("src", read).Every(time.Second)
+
+	val, err := a.Get()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), val, "reads source on first Get")
+	val, _ = b.Get()
+	require.Equal(t, int64(2), val, "initial sample read for each sampler")
+	require.Equal(t, int64(2), reads())
+	val, _ = a.Get()
+	require.Equal(t, int64(1), val, "not updated by other sampler's read")
+
+	start := timing.Now()
+	cNext := c.Next()
+	require.Equal(t, start.Add(time.Second), timing.NextTick())
+	notifier.AssertClosed(t, cNext, "on tick")
+	val, _ = c.Get()
+	require.Equal(t, int64(3), val)
+	val, _ = a.Get()
+	require.Equal(t, int64(1), val, "not updated when not due")
+
+	aNext, bNext := a.Next(), b.Next()
+	cNext = c.Next()
+	timing.NextTick()
+	notifier.AssertClosed(t, cNext, "on tick")
+	cNext = c.Next()
+	timing.NextTick()
+	notifier.AssertClosed(t, cNext, "on tick")
+	notifier.AssertClosed(t, aNext, "on aligned tick")
+	notifier.AssertClosed(t, bNext, "on aligned tick")
+	require.Equal(t, int64(5), reads(), "source read once per tick")
+	val, _ = a.Get()
+	require.Equal(t, int64(5), val)
+	val, _ = c.Get()
+	require.Equal(t, int64(5), val, "same sample fanned out")
+	require.Equal(t, start.Add(3*time.Second), timing.Now())
+}
+
+func TestRefresh(t *testing.T) {
+	setup()
+	read, reads := countingSource()
+	a := New("refresh", read).Every(time.Second)
+	b := New("refresh", read).Every(time.Second)
+	a.Get()
+	b.Get()
+
+	bNext := b.Next()
+	a.Refresh()
+	val, _ := a.Get()
+	require.Equal(t, int64(3), val, "refresh updates caller")
+	notifier.AssertNoUpdate(t, bNext, "refresh does not update other samplers")
+	val, _ = b.Get()
+	require.Equal(t, int64(2), val)
+
+	timing.NextTick()
+	notifier.AssertClosed(t, bNext, "on tick")
+	require.Equal(t, int64(4), reads())
+	val, _ = a.Get()
+	require.Equal(t, int64(4), val, "scheduled sample shared")
+}
+
+func TestKeyConflict(t *testing.T) {
+	setup()
+	read, _ := countingSource()
+	other := func() (interface{}, error) { return "other", nil }
+	New("key", read)
+	require.NotPanics(t, func() { New("key", read) }, "same read func")
+	require.Panics(t, func() { New("key", other) }, "different read func")
+	require.NotPanics(t, func() { New("key#other", other) }, "different key")
+}
+
+func TestConcurrentGet(t *testing.T) {
+	setup()
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	s := New("slow", func() (interface{}, error) {
+		started <- struct{}{}
+		<-release
+		return "ok", nil
+	})
+	go s.Refresh()
+	<-started
+	got := make(chan interface{})
+	go func() {
+		val, _ := s.Get()
+		got <- val
+	}()
+	close(release)
+	require.Equal(t, "ok", <-got, "Get during first read returns the sample")
+}
+
+func TestAlignment(t *testing.T) {
+	setup()
+	read, _ := countingSource()
+	// Start at an unaligned time.
+	timing.AdvanceBy(1500 * time.Millisecond)
+	start := timing.Now()
+	s := New("align", read).Every(time.Minute)
+	next := s.Next()
+	require.Equal(t, start.Add(time.Minute), timing.NextTick(),
+		"first sample one interval later")
+	notifier.AssertClosed(t, next, "on tick")
+
+	next = s.Next()
+	tick := timing.NextTick()
+	require.Equal(t, tick.Truncate(time.Minute), tick,
+		"later samples aligned to multiples of interval")
+	notifier.AssertClosed(t, next, "on tick")
+
+	other := New("align#other", read).Every(20 * time.Second)
+	next = s.Next()
+	for i := 0; i < 2; i++ {
+		otherNext := other.Next()
+		timing.NextTick()
+		notifier.AssertClosed(t, otherNext, "on tick")
+	}
+	notifier.AssertNoUpdate(t, next, "not due yet")
+	otherNext := other.Next()
+	tick = timing.NextTick()
+	require.Equal(t, tick.Truncate(time.Minute), tick)
+	notifier.AssertClosed(t, otherNext, "on tick")
+	notifier.AssertClosed(t, next, "sampled together with other sampler")
+}
+
+func TestStopAndErrors(t *testing.T) {
+	setup()
+	fail := int32(0)
+	read := func() (interface{}, error) {
+		if atomic.LoadInt32(&fail) == 1 {
+			return nil, errors.New("foo")
+		}
+		return "ok", nil
+	}
+	s := New("errors", read).Every(time.Second)
+	val, err := s.Get()
+	require.NoError(t, err)
+	require.Equal(t, "ok", val)
+
+	atomic.StoreInt32(&fail, 1)
+	next := s.Next()
+	timing.NextTick()
+	notifier.AssertClosed(t, next, "on tick")
+	_, err = s.Get()
+	require.Error(t, err, "read errors are propagated")
+
+	atomic.StoreInt32(&fail, 0)
+	next = s.Next()
+	s.Refresh()
+	notifier.AssertClosed(t, next, "on refresh")
+	val, err = s.Get()
+	require.NoError(t, err)
+	require.Equal(t, "ok", val)
+
+	s.Stop()
+	now := timing.Now()
+	require.Equal(t, now, timing.NextTick(), "no ticks when stopped")
+
+	s.Every(time.Minute)
+	require.Equal(t, now.Add(time.Minute), timing.NextTick(), "resumes on Every")
+
+	require.Panics(t, func() { s.Every(0) }, "non-positive interval")
+}
+
+func TestIntervalPolicy(t *testing.T) {
+	setup()
+	read, _ := countingSource()
+	timing.SetIntervalPolicy(timing.Stretch(10))
+	s := New("policy", read).Every(time.Second)
+	start := timing.Now()
+	next := s.Next()
+	require.Equal(t, start.Add(10*time.Second), timing.NextTick(),
+		"interval adjusted by policy")
+	notifier.AssertClosed(t, next, "on tick")
+
+	now := timing.Now()
+	timing.SetIntervalPolicy(nil)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return s.next.Equal(now.Add(time.Second))
+	}, time.Second, time.Millisecond, "rescheduled when policy changes")
+	next = s.Next()
+	require.Equal(t, now.Add(time.Second), timing.NextTick())
+	notifier.AssertClosed(t, next, "on tick")
+}
@@ -300,6 +300,10 @@ func (p *PropertiesWatcher) propChangeHandler(sig *Signal, fetch Fetcher) map[st
 	return r
 }
 
+// PropertiesSet is the DBus method used to set a property on an object, for
+// use with BusObject.Call.
+const PropertiesSet = "org.freedesktop.DBus.Properties.Set"
+
 // WatchProperties constructs a DBus properties watcher for the given object and
 // interface, using a specified bus and service name. The list of properties is
 // further used to filter events, as well as to fetch initial data when the
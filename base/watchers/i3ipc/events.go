@@ -0,0 +1,325 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3ipc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/leosunmo/barista/base/value"
+	l "github.com/leosunmo/barista/logging"
+)
+
+// EventType represents a type of event that can be subscribed to.
+type EventType string
+
+// Event types, from the i3 IPC documentation.
+const (
+	WorkspaceEvents       EventType = "workspace"
+	OutputEvents          EventType = "output"
+	ModeEvents            EventType = "mode"
+	WindowEvents          EventType = "window"
+	BarconfigUpdateEvents EventType = "barconfig_update"
+	BindingEvents         EventType = "binding"
+	ShutdownEvents        EventType = "shutdown"
+	TickEvents            EventType = "tick"
+	InputEvents           EventType = "input" // sway only
+)
+
+// eventTypes maps event message types (without the event bit) to the event
+// type used to subscribe.
+var eventTypes = map[messageType]EventType{
+	0:    WorkspaceEvents,
+	1:    OutputEvents,
+	2:    ModeEvents,
+	3:    WindowEvents,
+	4:    BarconfigUpdateEvents,
+	5:    BindingEvents,
+	6:    ShutdownEvents,
+	7:    TickEvents,
+	0x15: InputEvents,
+}
+
+// Event represents a single event received from the window manager.
+type Event struct {
+	Type EventType
+	// Payload is the raw JSON payload of the event. It is empty when the
+	// subscription was (re-)established, e.g. after i3 restarts, in which
+	// case any state derived from previous events should be refetched.
+	Payload json.RawMessage
+}
+
+// Decode unmarshals the event payload into v, e.g. a WorkspaceEvent for
+// events of type WorkspaceEvents.
+func (e Event) Decode(v interface{}) error {
+	if len(e.Payload) == 0 {
+		return errors.New("no event payload")
+	}
+	return json.Unmarshal(e.Payload, v)
+}
+
+// WorkspaceEvent is the payload of workspace events.
+type WorkspaceEvent struct {
+	Change  string `json:"change"`
+	Current *Node  `json:"current"`
+	Old     *Node  `json:"old"`
+}
+
+// OutputEvent is the payload of output events.
+type OutputEvent struct {
+	Change string `json:"change"`
+}
+
+// ModeEvent is the payload of mode events.
+type ModeEvent struct {
+	Change      string `json:"change"`
+	PangoMarkup bool   `json:"pango_markup"`
+}
+
+// WindowEvent is the payload of window events.
+type WindowEvent struct {
+	Change    string `json:"change"`
+	Container Node   `json:"container"`
+}
+
+// ShutdownEvent is the payload of shutdown events.
+type ShutdownEvent struct {
+	Change string `json:"change"`
+}
+
+// TickEvent is the payload of tick events.
+type TickEvent struct {
+	First   bool   `json:"first"`
+	Payload string `json:"payload"`
+}
+
+// InputEvent is the payload of input events (sway only).
+type InputEvent struct {
+	Change string `json:"change"`
+	Input  Input  `json:"input"`
+}
+
+// Reconnection delays for the event connection, e.g. while i3 restarts.
+const (
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 10 * time.Second
+)
+
+var (
+	// Latest event for each event type, shared by all subscriptions.
+	values   = map[EventType]*value.Value{} // of Event
+	valuesMu sync.RWMutex
+
+	// The connection used for events, nil while disconnected.
+	eventConn    *conn
+	reconnecting bool
+	// generation changes on reset, to stop any pending reconnection.
+	generation int
+	eventMu    sync.Mutex
+)
+
+// Subscription represents a shared subscription to a single event type,
+// which provides the latest event received.
+type Subscription struct {
+	C       <-chan struct{}
+	value   *value.Value // of Event
+	doneSub func()
+}
+
+// Subscribe creates a subscription to the given event type. Subscriptions
+// for the same event type share the same underlying IPC subscription.
+func Subscribe(event EventType) *Subscription {
+	valuesMu.Lock()
+	v, ok := values[event]
+	if !ok {
+		v = new(value.Value)
+		v.Set(Event{Type: event})
+		values[event] = v
+	}
+	valuesMu.Unlock()
+	if !ok {
+		subscribeEvents(event)
+	}
+	s := &Subscription{value: v}
+	s.C, s.doneSub = v.Subscribe()
+	return s
+}
+
+// Get returns the most recent event received for the subscription.
+func (s *Subscription) Get() Event {
+	return s.value.Get().(Event)
+}
+
+// Next returns a channel that will be closed on the next event.
+func (s *Subscription) Next() <-chan struct{} {
+	return s.value.Next()
+}
+
+// Unsubscribe stops further notifications and closes the channel.
+func (s *Subscription) Unsubscribe() {
+	s.doneSub()
+}
+
+// subscribeEvents adds the event type to the event connection, connecting
+// first if needed.
+func subscribeEvents(event EventType) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	if eventConn == nil {
+		if !reconnecting {
+			if err := connectLocked(); err != nil {
+				l.Log("i3ipc: failed to connect: %v", err)
+				reconnectLocked()
+			}
+		}
+		return
+	}
+	if err := eventConn.subscribe(event); err != nil {
+		l.Log("i3ipc: failed to subscribe to %s: %v", event, err)
+		// The event loop will notice the closed connection, and reconnect.
+		eventConn.Close()
+	}
+}
+
+// connectLocked connects to the IPC socket, and subscribes to all event
+// types with subscriptions. Must be called with eventMu held.
+func connectLocked() error {
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	eventConn = c
+	go readEvents(c)
+	var events []EventType
+	valuesMu.RLock()
+	for e := range values {
+		events = append(events, e)
+	}
+	valuesMu.RUnlock()
+	if err := c.subscribe(events...); err != nil {
+		eventConn = nil
+		c.Close()
+		return err
+	}
+	return nil
+}
+
+// reconnectLocked starts reconnecting in the background, unless a
+// reconnection is already pending. Must be called with eventMu held.
+func reconnectLocked() {
+	if reconnecting {
+		return
+	}
+	reconnecting = true
+	eventConn = nil
+	go reconnect(generation)
+}
+
+func reconnect(gen int) {
+	delay := minReconnectDelay
+	for {
+		time.Sleep(delay)
+		eventMu.Lock()
+		if gen != generation {
+			eventMu.Unlock()
+			return
+		}
+		err := connectLocked()
+		if err == nil {
+			reconnecting = false
+			eventMu.Unlock()
+			l.Log("i3ipc: reconnected")
+			notifyAll()
+			return
+		}
+		eventMu.Unlock()
+		l.Fine("i3ipc: reconnect failed: %v", err)
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// notifyAll notifies all subscriptions with an empty event, so that they
+// refetch any state that might have changed while disconnected.
+func notifyAll() {
+	valuesMu.RLock()
+	defer valuesMu.RUnlock()
+	for e, v := range values {
+		v.Set(Event{Type: e})
+	}
+}
+
+// readEvents reads events from the connection until it's closed, and then
+// starts reconnecting if it's still the current event connection.
+func readEvents(c *conn) {
+	for {
+		typ, payload, err := readMessage(c)
+		if err != nil {
+			l.Fine("i3ipc: event connection closed: %v", err)
+			break
+		}
+		if typ&eventMask == 0 {
+			select {
+			case c.replies <- payload:
+			default:
+				l.Log("i3ipc: dropping unexpected reply %d", typ)
+			}
+			continue
+		}
+		event, ok := eventTypes[typ&^eventMask]
+		if !ok {
+			l.Fine("i3ipc: ignoring unknown event %d", typ&^eventMask)
+			continue
+		}
+		valuesMu.RLock()
+		v := values[event]
+		valuesMu.RUnlock()
+		if v != nil {
+			v.Set(Event{Type: event, Payload: payload})
+		}
+	}
+	c.Close()
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	if eventConn == c {
+		reconnectLocked()
+	}
+}
+
+// subscribe subscribes the connection to the given event types, waiting
+// for the reply from the event loop.
+func (c *conn) subscribe(events ...EventType) error {
+	payload, _ := json.Marshal(events)
+	if err := c.write(subscribe, payload); err != nil {
+		return err
+	}
+	select {
+	case reply := <-c.replies:
+		var result commandResult
+		if err := json.Unmarshal(reply, &result); err != nil {
+			return err
+		}
+		if !result.Success {
+			return fmt.Errorf("subscribe to %v failed", events)
+		}
+		return nil
+	case <-time.After(time.Second):
+		return errors.New("timed out waiting for subscribe reply")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3ipc
+
+import (
+	"testing"
+
+	"github.com/leosunmo/barista/testing/notifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptions(t *testing.T) {
+	srv := SetupTestServer()
+	ws1 := Subscribe(WorkspaceEvents)
+	ws2 := Subscribe(WorkspaceEvents)
+	mode := Subscribe(ModeEvents)
+	defer mode.Unsubscribe()
+
+	srv.Emit(WorkspaceEvents, WorkspaceEvent{
+		Change:  "focus",
+		Current: &Node{Name: "2", Type: "workspace"},
+	})
+	notifier.AssertNotified(t, ws1.C, "on event")
+	notifier.AssertNotified(t, ws2.C, "shared subscription")
+	notifier.AssertNoUpdate(t, mode.C, "other event type")
+
+	var ev WorkspaceEvent
+	require.NoError(t, ws1.Get().Decode(&ev))
+	require.Equal(t, "focus", ev.Change)
+	require.Equal(t, "2", ev.Current.Name)
+	require.Equal(t, ws1.Get(), ws2.Get())
+
+	next := mode.Next()
+	srv.Emit(ModeEvents, ModeEvent{Change: "resize"})
+	notifier.AssertClosed(t, next)
+	var m ModeEvent
+	require.NoError(t, mode.Get().Decode(&m))
+	require.Equal(t, "resize", m.Change)
+	notifier.AssertNoUpdate(t, ws1.C, "other event type")
+
+	ws2.Unsubscribe()
+	srv.Emit(WorkspaceEvents, WorkspaceEvent{Change: "init"})
+	notifier.AssertNotified(t, ws1.C, "after other subscription removed")
+	ws1.Unsubscribe()
+
+	window := Subscribe(WindowEvents)
+	srv.Emit(WindowEvents, WindowEvent{Change: "title",
+		Container: Node{Name: "vim", Focused: true}})
+	notifier.AssertNotified(t, window.C, "subscribed on existing connection")
+	var w WindowEvent
+	require.NoError(t, window.Get().Decode(&w))
+	require.Equal(t, "vim", w.Container.Name)
+
+	input := Subscribe(InputEvents)
+	defer input.Unsubscribe()
+	srv.Emit(InputEvents, InputEvent{Change: "xkb_layout",
+		Input: Input{Identifier: "1:1:kbd", XkbActiveLayoutName: "German"}})
+	notifier.AssertNotified(t, input.C, "on sway input event")
+	var i InputEvent
+	require.NoError(t, input.Get().Decode(&i))
+	require.Equal(t, "German", i.Input.XkbActiveLayoutName)
+
+	srv.Restart()
+	notifier.AssertNotified(t, window.C, "on reconnection")
+	require.Equal(t, Event{Type: WindowEvents}, window.Get())
+	srv.Emit(WindowEvents, WindowEvent{Change: "close"})
+	notifier.AssertNotified(t, window.C, "resubscribed after reconnection")
+}
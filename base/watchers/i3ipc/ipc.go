@@ -0,0 +1,284 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package i3ipc provides access to the IPC interface of i3 and sway.
+
+Requests (e.g. RunCommand, Workspaces) use a shared connection to the window
+manager, and events are delivered through shared subscriptions, so that any
+number of modules can watch the same event type using a single connection:
+
+	sub := i3ipc.Subscribe(i3ipc.WorkspaceEvents)
+	defer sub.Unsubscribe()
+	for {
+		workspaces, err := i3ipc.Workspaces()
+		// update code.
+		<-sub.Next()
+	}
+
+The socket is found using the I3SOCK or SWAYSOCK environment variables,
+falling back to `i3 --get-socketpath`.
+*/
+package i3ipc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"unsafe"
+
+	l "github.com/leosunmo/barista/logging"
+)
+
+// messageType identifies the type of a request, reply, or event.
+type messageType uint32
+
+// Message types, from the i3 IPC documentation.
+const (
+	runCommand      messageType = 0
+	getWorkspaces   messageType = 1
+	subscribe       messageType = 2
+	getOutputs      messageType = 3
+	getTree         messageType = 4
+	getBarConfig    messageType = 6
+	getBindingState messageType = 12
+	getInputs       messageType = 100 // sway only
+
+	// Events have the highest bit set in the message type.
+	eventMask messageType = 1 << 31
+)
+
+// magic is the prefix of all IPC messages.
+const magic = "i3-ipc"
+
+// headerLen is the length of the magic string, payload length, and type.
+const headerLen = len(magic) + 8
+
+// byteOrder is the native byte order, used by i3 for message headers.
+var byteOrder binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		byteOrder = binary.BigEndian
+	}
+}
+
+func writeMessage(w io.Writer, typ messageType, payload []byte) error {
+	buf := make([]byte, headerLen+len(payload))
+	copy(buf, magic)
+	byteOrder.PutUint32(buf[len(magic):], uint32(len(payload)))
+	byteOrder.PutUint32(buf[len(magic)+4:], uint32(typ))
+	copy(buf[headerLen:], payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readMessage(r io.Reader) (messageType, []byte, error) {
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return 0, nil, fmt.Errorf("invalid IPC header %q", header)
+	}
+	length := byteOrder.Uint32(header[len(magic):])
+	typ := messageType(byteOrder.Uint32(header[len(magic)+4:]))
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return typ, payload, nil
+}
+
+// conn is a single connection to the IPC socket.
+type conn struct {
+	net.Conn
+	writeMu sync.Mutex
+	// Replies to requests on a connection that is reading events, which
+	// must be read by the event loop.
+	replies chan []byte
+}
+
+func (c *conn) write(typ messageType, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeMessage(c, typ, payload)
+}
+
+// roundTrip sends a request and reads its reply, for connections that are
+// not subscribed to any events.
+func (c *conn) roundTrip(typ messageType, payload []byte) ([]byte, error) {
+	if err := c.write(typ, payload); err != nil {
+		return nil, err
+	}
+	replyType, reply, err := readMessage(c)
+	if err != nil {
+		return nil, err
+	}
+	if replyType != typ {
+		return nil, fmt.Errorf("unexpected reply type %d for request %d", replyType, typ)
+	}
+	return reply, nil
+}
+
+// socketPath returns the path to the IPC socket. It's replaced in tests.
+var socketPath = findSocketPath
+
+func findSocketPath() (string, error) {
+	for _, env := range []string{"I3SOCK", "SWAYSOCK"} {
+		if path := os.Getenv(env); path != "" {
+			return path, nil
+		}
+	}
+	out, err := exec.Command("i3", "--get-socketpath").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not find i3 socket: %w", err)
+	}
+	path := strings.TrimSpace(string(out))
+	if path == "" {
+		return "", errors.New("could not find i3 socket")
+	}
+	return path, nil
+}
+
+func dial() (*conn, error) {
+	path, err := socketPath()
+	if err != nil {
+		return nil, err
+	}
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, replies: make(chan []byte, 1)}, nil
+}
+
+var (
+	reqConn *conn
+	reqMu   sync.Mutex
+)
+
+// request sends a request over the shared request connection, and unmarshals
+// the reply into out. If the connection was closed (e.g. if i3 restarted), it
+// reconnects once before giving up.
+func request(typ messageType, payload string, out interface{}) error {
+	reqMu.Lock()
+	defer reqMu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if reqConn == nil {
+			c, err := dial()
+			if err != nil {
+				return err
+			}
+			reqConn = c
+		}
+		reply, err := reqConn.roundTrip(typ, []byte(payload))
+		if err != nil {
+			reqConn.Close()
+			reqConn = nil
+			if attempt == 0 {
+				l.Fine("i3ipc: retrying request %d: %v", typ, err)
+				continue
+			}
+			return err
+		}
+		return json.Unmarshal(reply, out)
+	}
+}
+
+// commandResult is the reply to each command in a RUN_COMMAND request.
+type commandResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// RunCommand runs one or more commands, separated by ',' or ';', as if they
+// were bound to a key. It returns an error if any of the commands failed.
+func RunCommand(command string) error {
+	var results []commandResult
+	if err := request(runCommand, command, &results); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.Success {
+			if r.Error == "" {
+				r.Error = "command failed"
+			}
+			return fmt.Errorf("%s: %s", command, r.Error)
+		}
+	}
+	return nil
+}
+
+// Workspaces returns the current workspaces.
+func Workspaces() ([]Workspace, error) {
+	var workspaces []Workspace
+	err := request(getWorkspaces, "", &workspaces)
+	return workspaces, err
+}
+
+// Outputs returns the current outputs.
+func Outputs() ([]Output, error) {
+	var outputs []Output
+	err := request(getOutputs, "", &outputs)
+	return outputs, err
+}
+
+// Tree returns the layout tree, rooted at the root node.
+func Tree() (*Node, error) {
+	root := new(Node)
+	err := request(getTree, "", root)
+	return root, err
+}
+
+// BarIDs returns the IDs of all configured bars.
+func BarIDs() ([]string, error) {
+	var ids []string
+	err := request(getBarConfig, "", &ids)
+	return ids, err
+}
+
+// GetBarConfig returns the configuration of the bar with the given ID.
+func GetBarConfig(id string) (BarConfig, error) {
+	var cfg BarConfig
+	err := request(getBarConfig, id, &cfg)
+	if err == nil && cfg.ID == "" {
+		err = fmt.Errorf("no bar with id %q", id)
+	}
+	return cfg, err
+}
+
+// BindingState returns the name of the current binding mode.
+func BindingState() (string, error) {
+	var state struct {
+		Name string `json:"name"`
+	}
+	err := request(getBindingState, "", &state)
+	return state.Name, err
+}
+
+// Inputs returns the input devices (sway only).
+func Inputs() ([]Input, error) {
+	var inputs []Input
+	err := request(getInputs, "", &inputs)
+	return inputs, err
+}
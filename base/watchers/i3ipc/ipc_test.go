@@ -0,0 +1,151 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3ipc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFraming(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeMessage(&buf, getTree, []byte(`{"id":1}`)))
+	require.Equal(t, headerLen+8, buf.Len())
+	require.Equal(t, "i3-ipc", string(buf.Bytes()[:6]))
+
+	typ, payload, err := readMessage(&buf)
+	require.NoError(t, err)
+	require.Equal(t, getTree, typ)
+	require.Equal(t, `{"id":1}`, string(payload))
+
+	_, _, err = readMessage(bytes.NewBufferString("i3-ipc\x00"))
+	require.Error(t, err, "truncated header")
+	_, _, err = readMessage(bytes.NewBufferString("i4-ipc\x00\x00\x00\x00\x00\x00\x00\x00"))
+	require.Error(t, err, "invalid magic")
+}
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("I3SOCK", "")
+	t.Setenv("SWAYSOCK", "")
+	_, err := findSocketPath()
+	require.Error(t, err, "without i3 binary")
+
+	t.Setenv("SWAYSOCK", "/run/user/1000/sway-ipc.sock")
+	path, _ := findSocketPath()
+	require.Equal(t, "/run/user/1000/sway-ipc.sock", path)
+
+	t.Setenv("I3SOCK", "/run/user/1000/i3/ipc-socket")
+	path, _ = findSocketPath()
+	require.Equal(t, "/run/user/1000/i3/ipc-socket", path)
+}
+
+func TestRequests(t *testing.T) {
+	srv := SetupTestServer()
+	srv.SetWorkspaces(
+		Workspace{Num: 1, Name: "1", Output: "eDP-1", Visible: true, Focused: true},
+		Workspace{Num: 2, Name: "2: web", Output: "HDMI-1", Urgent: true},
+	)
+	srv.SetOutputs(Output{Name: "eDP-1", Active: true, CurrentWorkspace: "1"})
+	srv.SetBarConfigs(BarConfig{ID: "bar-0", Position: "top"})
+	srv.SetTree(Node{ID: 1, Type: "root", Nodes: []*Node{
+		{ID: 2, Type: "output", Name: "eDP-1", Nodes: []*Node{
+			{ID: 3, Type: "workspace", Name: "1",
+				Nodes: []*Node{{ID: 4, Type: "con", Name: "vim"}},
+				FloatingNodes: []*Node{{ID: 5, Type: "floating_con",
+					Focused: true, AppID: "foot"}},
+			},
+		}},
+	}})
+
+	ws, err := Workspaces()
+	require.NoError(t, err)
+	require.Len(t, ws, 2)
+	require.Equal(t, "2: web", ws[1].Name)
+	require.True(t, ws[1].Urgent)
+
+	outputs, err := Outputs()
+	require.NoError(t, err)
+	require.Equal(t, []Output{{Name: "eDP-1", Active: true, CurrentWorkspace: "1"}}, outputs)
+
+	ids, err := BarIDs()
+	require.NoError(t, err)
+	require.Equal(t, []string{"bar-0"}, ids)
+	bar, err := GetBarConfig("bar-0")
+	require.NoError(t, err)
+	require.Equal(t, "top", bar.Position)
+	_, err = GetBarConfig("bar-1")
+	require.Error(t, err, "unknown bar")
+
+	mode, err := BindingState()
+	require.NoError(t, err)
+	require.Equal(t, "default", mode)
+	srv.SetBindingState("resize")
+	mode, _ = BindingState()
+	require.Equal(t, "resize", mode)
+
+	srv.SetInputs(Input{Identifier: "1:1:kbd", Type: "keyboard",
+		XkbLayoutNames: []string{"English (US)", "German"}})
+	inputs, err := Inputs()
+	require.NoError(t, err)
+	require.Len(t, inputs, 1)
+	require.Equal(t, "German", inputs[0].XkbLayoutNames[1])
+
+	tree, err := Tree()
+	require.NoError(t, err)
+	focused := tree.FocusedNode()
+	require.NotNil(t, focused)
+	require.Equal(t, "foot", focused.AppID)
+	require.True(t, focused.IsFloating())
+	require.Equal(t, "vim", tree.Find(func(n *Node) bool { return n.ID == 4 }).Name)
+	require.Nil(t, tree.Find(func(n *Node) bool { return n.ID == 6 }))
+
+	require.NoError(t, RunCommand("workspace 2"))
+	require.Equal(t, "workspace 2", <-srv.Commands())
+	srv.OnCommand(func(cmd string) error { return errors.New("unknown command") })
+	require.Error(t, RunCommand("frobnicate"))
+	require.Equal(t, "frobnicate", <-srv.Commands())
+
+	srv.OnCommand(func(string) error { return nil })
+	srv.Restart()
+	require.NoError(t, RunCommand("nop"), "reconnects after restart")
+
+	srv.Close()
+	_, err = Workspaces()
+	require.Error(t, err, "with server closed")
+}
+
+func TestNoServer(t *testing.T) {
+	reset(func() (string, error) { return "", errors.New("no i3") })
+	_, err := Workspaces()
+	require.Error(t, err)
+
+	sub := Subscribe(WorkspaceEvents)
+	defer sub.Unsubscribe()
+	require.Equal(t, Event{Type: WorkspaceEvents}, sub.Get())
+	require.Error(t, sub.Get().Decode(new(WorkspaceEvent)))
+
+	srv := SetupTestServer()
+	require.NoError(t, RunCommand("nop"))
+	select {
+	case <-srv.Commands():
+	case <-time.After(time.Second):
+		require.Fail(t, "command not received")
+	}
+}
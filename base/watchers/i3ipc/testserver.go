@@ -0,0 +1,327 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3ipc
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/leosunmo/barista/base/value"
+	l "github.com/leosunmo/barista/logging"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestServer is a fake i3 IPC server for testing. It replies to requests
+// with the state set on it, records all commands, and sends events to all
+// connections subscribed to them.
+type TestServer struct {
+	mu       sync.Mutex
+	dir      string
+	listener net.Listener
+	conns    map[*testConn]bool
+
+	workspaces []Workspace
+	outputs    []Output
+	tree       Node
+	bars       []BarConfig
+	mode       string
+	inputs     []Input
+	onCommand  func(string) error
+	commands   chan string
+}
+
+type testConn struct {
+	net.Conn
+	mu     sync.Mutex
+	events map[EventType]bool
+}
+
+func (c *testConn) write(typ messageType, payload interface{}) error {
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return writeMessage(c, typ, bytes)
+}
+
+var testServer *TestServer
+
+// SetupTestServer starts a fake IPC server, and connects all subsequent
+// requests and subscriptions to it. Any existing connections, subscriptions,
+// and test servers are discarded.
+func SetupTestServer() *TestServer {
+	dir, err := os.MkdirTemp("", "i3ipc")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "ipc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		panic(err)
+	}
+	t := &TestServer{
+		dir:       dir,
+		listener:  listener,
+		conns:     map[*testConn]bool{},
+		mode:      "default",
+		onCommand: func(string) error { return nil },
+		commands:  make(chan string, 100),
+	}
+	if testServer != nil {
+		testServer.Close()
+	}
+	testServer = t
+	reset(func() (string, error) { return path, nil })
+	go t.serve()
+	return t
+}
+
+// reset discards all connections and subscriptions, and uses the given
+// function to find the socket for new connections.
+func reset(path func() (string, error)) {
+	reqMu.Lock()
+	if reqConn != nil {
+		reqConn.Close()
+		reqConn = nil
+	}
+	socketPath = path
+	reqMu.Unlock()
+
+	eventMu.Lock()
+	generation++
+	reconnecting = false
+	if eventConn != nil {
+		eventConn.Close()
+		eventConn = nil
+	}
+	eventMu.Unlock()
+
+	valuesMu.Lock()
+	values = map[EventType]*value.Value{}
+	valuesMu.Unlock()
+}
+
+func (t *TestServer) serve() {
+	for {
+		c, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		tc := &testConn{Conn: c, events: map[EventType]bool{}}
+		t.mu.Lock()
+		t.conns[tc] = true
+		t.mu.Unlock()
+		go t.handle(tc)
+	}
+}
+
+func (t *TestServer) handle(c *testConn) {
+	defer func() {
+		c.Close()
+		t.mu.Lock()
+		delete(t.conns, c)
+		t.mu.Unlock()
+	}()
+	for {
+		typ, payload, err := readMessage(c)
+		if err != nil {
+			return
+		}
+		var reply interface{}
+		t.mu.Lock()
+		switch typ {
+		case runCommand:
+			cmd := string(payload)
+			result := commandResult{Success: true}
+			if err := t.onCommand(cmd); err != nil {
+				result = commandResult{Error: err.Error()}
+			}
+			select {
+			case t.commands <- cmd:
+			default:
+				l.Log("i3ipc: test server dropping command %q", cmd)
+			}
+			reply = []commandResult{result}
+		case getWorkspaces:
+			reply = t.workspaces
+		case getOutputs:
+			reply = t.outputs
+		case getTree:
+			reply = t.tree
+		case getBarConfig:
+			reply = t.barConfig(string(payload))
+		case getBindingState:
+			reply = map[string]string{"name": t.mode}
+		case getInputs:
+			reply = t.inputs
+		case subscribe:
+			var events []EventType
+			result := commandResult{Success: true}
+			if json.Unmarshal(payload, &events) != nil {
+				result.Success = false
+			}
+			for _, e := range events {
+				c.events[e] = true
+			}
+			reply = result
+		default:
+			reply = commandResult{Error: "unsupported message type"}
+		}
+		t.mu.Unlock()
+		if err := c.write(typ, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (t *TestServer) barConfig(id string) interface{} {
+	if id == "" {
+		ids := []string{}
+		for _, b := range t.bars {
+			ids = append(ids, b.ID)
+		}
+		return ids
+	}
+	for _, b := range t.bars {
+		if b.ID == id {
+			return b
+		}
+	}
+	return commandResult{Error: "No bar with the specified id"}
+}
+
+// SetWorkspaces sets the workspaces returned by GET_WORKSPACES.
+func (t *TestServer) SetWorkspaces(workspaces ...Workspace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.workspaces = append([]Workspace{}, workspaces...)
+}
+
+// SetOutputs sets the outputs returned by GET_OUTPUTS.
+func (t *TestServer) SetOutputs(outputs ...Output) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outputs = append([]Output{}, outputs...)
+}
+
+// SetTree sets the tree returned by GET_TREE.
+func (t *TestServer) SetTree(root Node) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tree = root
+}
+
+// SetBarConfigs sets the bars returned by GET_BAR_CONFIG.
+func (t *TestServer) SetBarConfigs(bars ...BarConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bars = append([]BarConfig{}, bars...)
+}
+
+// SetBindingState sets the binding mode returned by GET_BINDING_STATE.
+func (t *TestServer) SetBindingState(mode string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mode = mode
+}
+
+// SetInputs sets the input devices returned by GET_INPUTS.
+func (t *TestServer) SetInputs(inputs ...Input) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inputs = append([]Input{}, inputs...)
+}
+
+// OnCommand sets a function to handle commands from RUN_COMMAND. Any
+// non-nil error returned from the function is sent as a failure.
+func (t *TestServer) OnCommand(fn func(string) error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onCommand = fn
+}
+
+// Commands returns a channel that receives all commands run.
+func (t *TestServer) Commands() <-chan string {
+	return t.commands
+}
+
+// AssertCommand asserts that the next command run is the expected command.
+func (t *TestServer) AssertCommand(tt require.TestingT, expected string, msgAndArgs ...interface{}) {
+	if h, ok := tt.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	select {
+	case cmd := <-t.commands:
+		require.Equal(tt, expected, cmd, msgAndArgs...)
+	case <-time.After(time.Second):
+		require.Fail(tt, "no command received", msgAndArgs...)
+	}
+}
+
+// AssertNoCommand asserts that no command is run.
+func (t *TestServer) AssertNoCommand(tt require.TestingT, msgAndArgs ...interface{}) {
+	if h, ok := tt.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	select {
+	case cmd := <-t.commands:
+		require.Fail(tt, "unexpected command "+cmd, msgAndArgs...)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+// Emit sends an event to all connections subscribed to its type.
+func (t *TestServer) Emit(event EventType, payload interface{}) {
+	var typ messageType
+	for mt, et := range eventTypes {
+		if et == event {
+			typ = mt | eventMask
+		}
+	}
+	t.mu.Lock()
+	var conns []*testConn
+	for c := range t.conns {
+		if c.events[event] {
+			conns = append(conns, c)
+		}
+	}
+	t.mu.Unlock()
+	for _, c := range conns {
+		c.write(typ, payload)
+	}
+}
+
+// Restart closes all connections, simulating an i3 restart.
+func (t *TestServer) Restart() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for c := range t.conns {
+		c.Close()
+	}
+}
+
+// Close stops the test server.
+func (t *TestServer) Close() {
+	t.listener.Close()
+	t.Restart()
+	os.RemoveAll(t.dir)
+}
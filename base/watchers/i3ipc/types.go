@@ -0,0 +1,151 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3ipc
+
+// Rect represents the position and size of a workspace, output, or node.
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Workspace represents a single workspace, as returned by GET_WORKSPACES.
+type Workspace struct {
+	ID      int64  `json:"id"`
+	Num     int    `json:"num"`
+	Name    string `json:"name"`
+	Visible bool   `json:"visible"`
+	Focused bool   `json:"focused"`
+	Urgent  bool   `json:"urgent"`
+	Rect    Rect   `json:"rect"`
+	Output  string `json:"output"`
+}
+
+// Output represents a single output, as returned by GET_OUTPUTS.
+type Output struct {
+	Name             string `json:"name"`
+	Active           bool   `json:"active"`
+	Primary          bool   `json:"primary"`
+	CurrentWorkspace string `json:"current_workspace"`
+	Rect             Rect   `json:"rect"`
+}
+
+// WindowProperties contains the X11 properties of a window.
+type WindowProperties struct {
+	Class        string `json:"class"`
+	Instance     string `json:"instance"`
+	Title        string `json:"title"`
+	Role         string `json:"window_role"`
+	TransientFor int64  `json:"transient_for"`
+}
+
+// Node represents a single node in the layout tree, as returned by GET_TREE.
+type Node struct {
+	ID                 int64    `json:"id"`
+	Name               string   `json:"name"`
+	Type               string   `json:"type"`
+	Layout             string   `json:"layout"`
+	Orientation        string   `json:"orientation"`
+	Border             string   `json:"border"`
+	CurrentBorderWidth int      `json:"current_border_width"`
+	Percent            float64  `json:"percent"`
+	Rect               Rect     `json:"rect"`
+	WindowRect         Rect     `json:"window_rect"`
+	DecoRect           Rect     `json:"deco_rect"`
+	Geometry           Rect     `json:"geometry"`
+	Urgent             bool     `json:"urgent"`
+	Focused            bool     `json:"focused"`
+	Focus              []int64  `json:"focus"`
+	Marks              []string `json:"marks"`
+
+	// FullscreenMode is 0 for none, 1 for fullscreen on the output,
+	// and 2 for global fullscreen.
+	FullscreenMode int `json:"fullscreen_mode"`
+	// Floating is one of "auto_off", "auto_on", "user_off", "user_on"
+	// in i3. Sway uses a different node type for floating containers,
+	// see IsFloating.
+	Floating string `json:"floating"`
+
+	// Window is the X11 window ID, or 0 for Wayland windows.
+	Window           int64             `json:"window"`
+	WindowProperties *WindowProperties `json:"window_properties,omitempty"`
+	// AppID is the Wayland app_id of a window (sway only).
+	AppID string `json:"app_id"`
+	// PID is the process ID of the window's client (sway only).
+	PID int `json:"pid"`
+
+	Nodes         []*Node `json:"nodes"`
+	FloatingNodes []*Node `json:"floating_nodes"`
+}
+
+// Find returns the first node (depth-first, including this node) that
+// matches the given function, or nil if no node matches.
+func (n *Node) Find(match func(*Node) bool) *Node {
+	if n == nil {
+		return nil
+	}
+	if match(n) {
+		return n
+	}
+	for _, children := range [][]*Node{n.Nodes, n.FloatingNodes} {
+		for _, c := range children {
+			if found := c.Find(match); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// FocusedNode returns the focused node in the tree, or nil if there is none.
+func (n *Node) FocusedNode() *Node {
+	return n.Find(func(c *Node) bool { return c.Focused })
+}
+
+// IsFloating returns true if the node is a floating container.
+func (n *Node) IsFloating() bool {
+	return n.Type == "floating_con" ||
+		n.Floating == "auto_on" || n.Floating == "user_on"
+}
+
+// BarConfig represents the configuration of a bar, as returned by
+// GET_BAR_CONFIG and the barconfig_update event.
+type BarConfig struct {
+	ID                   string            `json:"id"`
+	Mode                 string            `json:"mode"`
+	HiddenState          string            `json:"hidden_state"`
+	Position             string            `json:"position"`
+	StatusCommand        string            `json:"status_command"`
+	Font                 string            `json:"font"`
+	Outputs              []string          `json:"outputs"`
+	WorkspaceButtons     bool              `json:"workspace_buttons"`
+	BindingModeIndicator bool              `json:"binding_mode_indicator"`
+	Verbose              bool              `json:"verbose"`
+	Colors               map[string]string `json:"colors"`
+}
+
+// Input represents an input device, as returned by GET_INPUTS (sway only).
+type Input struct {
+	Identifier string `json:"identifier"`
+	Name       string `json:"name"`
+	Vendor     int    `json:"vendor"`
+	Product    int    `json:"product"`
+	Type       string `json:"type"`
+	// Keyboard layouts, only set for keyboards.
+	XkbActiveLayoutName  string   `json:"xkb_active_layout_name"`
+	XkbLayoutNames       []string `json:"xkb_layout_names"`
+	XkbActiveLayoutIndex int      `json:"xkb_active_layout_index"`
+}
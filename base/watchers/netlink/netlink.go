@@ -49,6 +49,24 @@ type Link struct {
 	State        OperState
 	HardwareAddr net.HardwareAddr
 	IPs          []net.IP
+	// DefaultRoutes are the default routes through this link, in order of
+	// preference (lowest metric first).
+	DefaultRoutes []Route
+	// Default is true if this link carries the preferred default route
+	// across all links, i.e. it is the link used to reach the internet.
+	Default bool
+	// SearchDomains are the DNS search domains advertised on this link by
+	// IPv6 router advertisements.
+	SearchDomains []string
+}
+
+// Gateway returns the gateway of the preferred default route through this
+// link, or nil if there is none.
+func (l Link) Gateway() net.IP {
+	if len(l.DefaultRoutes) == 0 {
+		return nil
+	}
+	return l.DefaultRoutes[0].Gateway
 }
 
 var (
@@ -80,12 +98,19 @@ func addLink(index LinkIndex, link Link) {
 			return
 		}
 		l.Fine("Updating link %s@%d", link.Name, index)
-		// addLink does not have address information
+		// addLink does not have address or route information
 		link.IPs = oldLink.IPs
+		link.DefaultRoutes = oldLink.DefaultRoutes
+		link.Default = oldLink.Default
+		link.SearchDomains = oldLink.SearchDomains
 	} else {
 		l.Fine("Adding link %s@%d", link.Name, index)
 	}
 	links[index] = link
+	if !ok {
+		// Routes may have been seen before the link.
+		updateRoutesLocked()
+	}
 	notifyChanged(names...)
 }
 
@@ -161,6 +186,9 @@ func delLink(index LinkIndex) {
 	}
 	l.Fine("Deleting link %s@%d", link.Name, index)
 	delete(links, index)
+	delete(routes, index)
+	delete(searchDomains, index)
+	updateRoutesLocked()
 	notifyChanged(link.Name)
 }
 
@@ -191,13 +219,15 @@ func delIP(index LinkIndex, addr net.IP) {
 }
 
 func nlInit() {
-	initialData, err := getInitialData()
+	initialData, initialRoutes, err := getInitialData()
 	if err != nil {
 		l.Log("Failed to populate initial data: %s", err)
 		return
 	}
 	linksMu.Lock()
 	links = initialData
+	routes = initialRoutes
+	updateRoutesLocked()
 	sorted := sortedLinks()
 	linksMu.Unlock()
 	msub.Set(sorted)
@@ -253,6 +283,8 @@ func sortedLinks() []Link {
 			return true
 		case a.State < b.State:
 			return false
+		case a.Default != b.Default:
+			return a.Default
 		default:
 			return a.Name < b.Name
 		}
@@ -320,7 +352,8 @@ func WithPrefix(prefix string) *Subscription {
 // The status order is Up > Dormant > Testing > LowerLayerDown
 // > Down > NotPresent > Unknown. (A 'virtual' link with status
 // Gone may be returned if no links are available).
-// If multiple links have the same status, they are ordered
+// If multiple links have the same status, the link that carries the
+// preferred default route is first, followed by the others ordered
 // alphabetically by their name.
 func Any() *Subscription {
 	return subscribe(new(Subscription))
@@ -366,6 +399,9 @@ type Tester interface {
 	RemoveLink(LinkIndex)
 	AddIP(LinkIndex, net.IP)
 	RemoveIP(LinkIndex, net.IP)
+	AddRoute(LinkIndex, Route)
+	RemoveRoute(LinkIndex, Route)
+	SetSearchDomains(LinkIndex, ...string)
 }
 
 type tester struct{ lastIdx LinkIndex }
@@ -402,12 +438,26 @@ func (t *tester) RemoveIP(index LinkIndex, addr net.IP) {
 	delIP(index, addr)
 }
 
+func (t *tester) AddRoute(index LinkIndex, route Route) {
+	addRoute(index, route)
+}
+
+func (t *tester) RemoveRoute(index LinkIndex, route Route) {
+	delRoute(index, route)
+}
+
+func (t *tester) SetSearchDomains(index LinkIndex, domains ...string) {
+	setSearchDomains(index, domains)
+}
+
 // TestMode puts the netlink watcher in test mode, and resets the
 // link and subscriber states.
 func TestMode() Tester {
 	once.Do(func() {}) // Prevent real subscription.
 	linksMu.Lock()
 	links = map[LinkIndex]Link{}
+	routes = map[LinkIndex][]Route{}
+	searchDomains = map[LinkIndex][]string{}
 	linksMu.Unlock()
 	subsMu.Lock()
 	subs = nil
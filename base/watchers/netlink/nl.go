@@ -84,8 +84,9 @@ var nlSubscribe = func(protocol int, groups ...uint) (nlReceiver, error) {
 
 var nlMu sync.RWMutex
 
-func getInitialData() (map[LinkIndex]Link, error) {
+func getInitialData() (map[LinkIndex]Link, map[LinkIndex][]Route, error) {
 	links := map[LinkIndex]Link{}
+	routes := map[LinkIndex][]Route{}
 	nlMu.RLock()
 	defer nlMu.RUnlock()
 
@@ -93,7 +94,7 @@ func getInitialData() (map[LinkIndex]Link, error) {
 	req.AddData(nl.NewIfInfomsg(unix.AF_UNSPEC))
 	msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWLINK)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for _, msg := range msgs {
 		idx, link := linkFromMsg(msg)
@@ -105,7 +106,7 @@ func getInitialData() (map[LinkIndex]Link, error) {
 	req.AddData(nl.NewIfInfomsg(unix.AF_UNSPEC))
 	msgs, err = req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWADDR)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for _, msg := range msgs {
 		idx, addr := addrFromMsg(msg)
@@ -119,7 +120,22 @@ func getInitialData() (map[LinkIndex]Link, error) {
 		links[idx] = link
 	}
 
-	return links, nil
+	req = newNlRequest(unix.RTM_GETROUTE, unix.NLM_F_DUMP)
+	req.AddData(nl.NewRtMsg())
+	msgs, err = req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWROUTE)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, msg := range msgs {
+		idx, route, ok := defaultRouteFromMsg(msg)
+		if !ok {
+			continue
+		}
+		l.Fine("Got default route %v for %d", route, idx)
+		routes[idx] = append(routes[idx], route)
+	}
+
+	return links, routes, nil
 }
 
 func nlListen() {
@@ -129,6 +145,9 @@ func nlListen() {
 		unix.RTNLGRP_LINK,
 		unix.RTNLGRP_IPV4_IFADDR,
 		unix.RTNLGRP_IPV6_IFADDR,
+		unix.RTNLGRP_IPV4_ROUTE,
+		unix.RTNLGRP_IPV6_ROUTE,
+		unix.RTNLGRP_ND_USEROPT,
 	)
 	nlMu.RUnlock()
 	if err != nil {
@@ -152,6 +171,18 @@ func nlListen() {
 				addIP(addrFromMsg(msg.Data))
 			case unix.RTM_DELADDR:
 				delIP(addrFromMsg(msg.Data))
+			case unix.RTM_NEWROUTE:
+				if idx, route, ok := defaultRouteFromMsg(msg.Data); ok {
+					addRoute(idx, route)
+				}
+			case unix.RTM_DELROUTE:
+				if idx, route, ok := defaultRouteFromMsg(msg.Data); ok {
+					delRoute(idx, route)
+				}
+			case unix.RTM_NEWNDUSEROPT:
+				if idx, domains, ok := searchDomainsFromMsg(msg.Data); ok {
+					setSearchDomains(idx, domains)
+				}
 			}
 		}
 	}
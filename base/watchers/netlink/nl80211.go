@@ -0,0 +1,198 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlink
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/martinlindhe/unit"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// WirelessInfo represents the state of a wireless link, as reported by the
+// nl80211 generic netlink family.
+type WirelessInfo struct {
+	SSID string
+	// BSSID is the MAC address of the access point.
+	BSSID     net.HardwareAddr
+	Frequency unit.Frequency
+	// Signal strength in dBm, e.g. -55.
+	Signal    int
+	TxBitrate unit.Datarate
+	RxBitrate unit.Datarate
+}
+
+// Connected returns true if the wireless link is associated with an
+// access point.
+func (w WirelessInfo) Connected() bool {
+	return len(w.BSSID) > 0
+}
+
+// ErrNotWireless is returned by Wireless for links that are not managed
+// by nl80211.
+var ErrNotWireless = errors.New("not a wireless link")
+
+var (
+	nl80211ID   uint16
+	nl80211IDMu sync.Mutex
+)
+
+// nl80211Family returns the generic netlink family ID for nl80211, which is
+// assigned dynamically by the kernel.
+func nl80211Family() (uint16, error) {
+	nl80211IDMu.Lock()
+	defer nl80211IDMu.Unlock()
+	if nl80211ID != 0 {
+		return nl80211ID, nil
+	}
+	msgs, err := genlRequest(nl.GENL_ID_CTRL, nl.GENL_CTRL_CMD_GETFAMILY,
+		nl.GENL_CTRL_VERSION, 0,
+		nl.NewRtAttr(nl.GENL_CTRL_ATTR_FAMILY_NAME, nl.ZeroTerminated("nl80211")))
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range msgs {
+		for _, attr := range genlAttrs(msg) {
+			if attrType(attr) == nl.GENL_CTRL_ATTR_FAMILY_ID {
+				nl80211ID = native.Uint16(attr.Value)
+				return nl80211ID, nil
+			}
+		}
+	}
+	return 0, errors.New("nl80211 family not found")
+}
+
+// genlRequest sends a generic netlink request, and returns the payloads of
+// all replies, without the netlink headers.
+func genlRequest(family uint16, cmd, version uint8, flags int, attrs ...*nl.RtAttr) ([][]byte, error) {
+	nlMu.RLock()
+	req := newNlRequest(int(family), flags)
+	nlMu.RUnlock()
+	req.AddData(&nl.Genlmsg{Command: cmd, Version: version})
+	for _, attr := range attrs {
+		req.AddData(attr)
+	}
+	return req.Execute(unix.NETLINK_GENERIC, family)
+}
+
+// genlAttrs parses the attributes of a generic netlink message.
+func genlAttrs(msg []byte) []syscall.NetlinkRouteAttr {
+	if len(msg) < nl.SizeofGenlmsg {
+		return nil
+	}
+	attrs, _ := nl.ParseRouteAttr(msg[nl.SizeofGenlmsg:])
+	return attrs
+}
+
+func attrType(attr syscall.NetlinkRouteAttr) uint16 {
+	return attr.Attr.Type &^ (unix.NLA_F_NESTED | unix.NLA_F_NET_BYTEORDER)
+}
+
+// Wireless returns information about the named wireless link, using
+// nl80211. It does not require any privileges or external commands.
+func Wireless(name string) (WirelessInfo, error) {
+	info := WirelessInfo{}
+	family, err := nl80211Family()
+	if err != nil {
+		return info, err
+	}
+	msgs, err := genlRequest(family, unix.NL80211_CMD_GET_INTERFACE, 0, unix.NLM_F_DUMP)
+	if err != nil {
+		return info, err
+	}
+	var ifindex []byte
+	for _, msg := range msgs {
+		ifname, i := "", WirelessInfo{}
+		var idx []byte
+		for _, attr := range genlAttrs(msg) {
+			switch attrType(attr) {
+			case unix.NL80211_ATTR_IFNAME:
+				ifname = string(trimNull(attr.Value))
+			case unix.NL80211_ATTR_IFINDEX:
+				idx = attr.Value
+			case unix.NL80211_ATTR_SSID:
+				i.SSID = string(attr.Value)
+			case unix.NL80211_ATTR_WIPHY_FREQ:
+				i.Frequency = unit.Frequency(native.Uint32(attr.Value)) * unit.Megahertz
+			}
+		}
+		if ifname == name {
+			info, ifindex = i, idx
+			break
+		}
+	}
+	if ifindex == nil {
+		return info, ErrNotWireless
+	}
+	msgs, err = genlRequest(family, unix.NL80211_CMD_GET_STATION, 0, unix.NLM_F_DUMP,
+		nl.NewRtAttr(unix.NL80211_ATTR_IFINDEX, ifindex))
+	if err != nil {
+		return info, err
+	}
+	// Stations are the peers of the link, i.e. just the access point while
+	// in managed mode.
+	if len(msgs) > 0 {
+		for _, attr := range genlAttrs(msgs[0]) {
+			switch attrType(attr) {
+			case unix.NL80211_ATTR_MAC:
+				info.BSSID = net.HardwareAddr(attr.Value)
+			case unix.NL80211_ATTR_STA_INFO:
+				parseStationInfo(attr.Value, &info)
+			}
+		}
+	}
+	return info, nil
+}
+
+func parseStationInfo(data []byte, info *WirelessInfo) {
+	attrs, _ := nl.ParseRouteAttr(data)
+	for _, attr := range attrs {
+		switch attrType(attr) {
+		case unix.NL80211_STA_INFO_SIGNAL:
+			info.Signal = int(int8(attr.Value[0]))
+		case unix.NL80211_STA_INFO_TX_BITRATE:
+			info.TxBitrate = parseBitrate(attr.Value)
+		case unix.NL80211_STA_INFO_RX_BITRATE:
+			info.RxBitrate = parseBitrate(attr.Value)
+		}
+	}
+}
+
+// parseBitrate parses nested rate info, which is in units of 100 kbit/s.
+func parseBitrate(data []byte) unit.Datarate {
+	attrs, _ := nl.ParseRouteAttr(data)
+	var rate uint32
+	for _, attr := range attrs {
+		switch attrType(attr) {
+		case unix.NL80211_RATE_INFO_BITRATE32:
+			// Prefer the 32-bit rate, which is needed for high rates.
+			return unit.Datarate(native.Uint32(attr.Value)) * 100 * unit.KilobitPerSecond
+		case unix.NL80211_RATE_INFO_BITRATE:
+			rate = uint32(native.Uint16(attr.Value))
+		}
+	}
+	return unit.Datarate(rate) * 100 * unit.KilobitPerSecond
+}
+
+func trimNull(b []byte) []byte {
+	if len(b) > 0 && b[len(b)-1] == 0 {
+		return b[:len(b)-1]
+	}
+	return b
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlink
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+const testFamilyID = 28
+
+// testGenlRequest replies to generic netlink requests using a function of
+// the family, command, and attributes.
+type testGenlRequest struct {
+	family  int
+	cmd     uint8
+	attrs   map[uint16][]byte
+	handler func(family int, cmd uint8, attrs map[uint16][]byte) ([][]byte, error)
+}
+
+func (t *testGenlRequest) AddData(data nl.NetlinkRequestData) {
+	switch d := data.(type) {
+	case *nl.Genlmsg:
+		t.cmd = d.Command
+	case *nl.RtAttr:
+		t.attrs[d.Type] = d.Data
+	}
+}
+
+func (t *testGenlRequest) Execute(sockType int, resType uint16) ([][]byte, error) {
+	if sockType != unix.NETLINK_GENERIC || int(resType) != t.family {
+		return nil, errors.New("unexpected request")
+	}
+	return t.handler(t.family, t.cmd, t.attrs)
+}
+
+func genlMsg(cmd uint8, attrs ...*nl.RtAttr) []byte {
+	msg := (&nl.Genlmsg{Command: cmd}).Serialize()
+	for _, attr := range attrs {
+		msg = append(msg, attr.Serialize()...)
+	}
+	return msg
+}
+
+func u16(v uint16) []byte {
+	b := make([]byte, 2)
+	native.PutUint16(b, v)
+	return b
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	native.PutUint32(b, v)
+	return b
+}
+
+func setupNl80211(t *testing.T, stations map[uint32][]byte) {
+	nlMu.Lock()
+	oldRequest := newNlRequest
+	newNlRequest = func(proto, flags int) nlRequest {
+		return &testGenlRequest{
+			family: proto,
+			attrs:  map[uint16][]byte{},
+			handler: func(family int, cmd uint8, attrs map[uint16][]byte) ([][]byte, error) {
+				switch {
+				case family == nl.GENL_ID_CTRL && cmd == nl.GENL_CTRL_CMD_GETFAMILY:
+					require.Equal(t, "nl80211\x00", string(attrs[nl.GENL_CTRL_ATTR_FAMILY_NAME]))
+					return [][]byte{genlMsg(1,
+						nl.NewRtAttr(nl.GENL_CTRL_ATTR_FAMILY_NAME, nl.ZeroTerminated("nl80211")),
+						nl.NewRtAttr(nl.GENL_CTRL_ATTR_FAMILY_ID, u16(testFamilyID)),
+					)}, nil
+				case family == testFamilyID && cmd == unix.NL80211_CMD_GET_INTERFACE:
+					return [][]byte{
+						genlMsg(unix.NL80211_CMD_NEW_INTERFACE,
+							nl.NewRtAttr(unix.NL80211_ATTR_IFINDEX, u32(3)),
+							nl.NewRtAttr(unix.NL80211_ATTR_IFNAME, nl.ZeroTerminated("wlan0")),
+							nl.NewRtAttr(unix.NL80211_ATTR_SSID, []byte("HomeNet")),
+							nl.NewRtAttr(unix.NL80211_ATTR_WIPHY_FREQ, u32(5180)),
+						),
+						genlMsg(unix.NL80211_CMD_NEW_INTERFACE,
+							nl.NewRtAttr(unix.NL80211_ATTR_IFINDEX, u32(4)),
+							nl.NewRtAttr(unix.NL80211_ATTR_IFNAME, nl.ZeroTerminated("wlan1")),
+						),
+					}, nil
+				case family == testFamilyID && cmd == unix.NL80211_CMD_GET_STATION:
+					idx := native.Uint32(attrs[unix.NL80211_ATTR_IFINDEX])
+					if station, ok := stations[idx]; ok {
+						return [][]byte{station}, nil
+					}
+					return nil, nil
+				}
+				return nil, errors.New("unexpected command")
+			},
+		}
+	}
+	nlMu.Unlock()
+	nl80211IDMu.Lock()
+	nl80211ID = 0
+	nl80211IDMu.Unlock()
+	t.Cleanup(func() {
+		nlMu.Lock()
+		newNlRequest = oldRequest
+		nlMu.Unlock()
+	})
+}
+
+func TestWireless(t *testing.T) {
+	staInfo := nl.NewRtAttr(unix.NL80211_ATTR_STA_INFO, nil)
+	staInfo.AddRtAttr(unix.NL80211_STA_INFO_SIGNAL, []byte{byte(0xc9)}) // -55
+	tx := staInfo.AddRtAttr(unix.NL80211_STA_INFO_TX_BITRATE, nil)
+	tx.AddRtAttr(unix.NL80211_RATE_INFO_BITRATE, u16(8667))
+	tx.AddRtAttr(unix.NL80211_RATE_INFO_BITRATE32, u32(8667))
+	rx := staInfo.AddRtAttr(unix.NL80211_STA_INFO_RX_BITRATE, nil)
+	rx.AddRtAttr(unix.NL80211_RATE_INFO_BITRATE, u16(540))
+
+	setupNl80211(t, map[uint32][]byte{
+		3: genlMsg(unix.NL80211_CMD_NEW_STATION,
+			nl.NewRtAttr(unix.NL80211_ATTR_IFINDEX, u32(3)),
+			nl.NewRtAttr(unix.NL80211_ATTR_MAC, []byte{0, 0x11, 0x22, 0x33, 0x44, 0x55}),
+			staInfo,
+		),
+	})
+
+	info, err := Wireless("wlan0")
+	require.NoError(t, err)
+	require.True(t, info.Connected())
+	require.Equal(t, "HomeNet", info.SSID)
+	require.Equal(t, net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}, info.BSSID)
+	require.InDelta(t, 5.18, info.Frequency.Gigahertz(), 1e-9)
+	require.Equal(t, -55, info.Signal)
+	require.InDelta(t, 866.7, info.TxBitrate.MegabitsPerSecond(), 1e-9)
+	require.InDelta(t, 54, info.RxBitrate.MegabitsPerSecond(), 1e-9)
+
+	info, err = Wireless("wlan1")
+	require.NoError(t, err)
+	require.False(t, info.Connected(), "without station")
+	require.Equal(t, "", info.SSID)
+
+	_, err = Wireless("eth0")
+	require.Equal(t, ErrNotWireless, err)
+}
+
+func TestWirelessErrors(t *testing.T) {
+	nlMu.Lock()
+	oldRequest := newNlRequest
+	newNlRequest = func(proto, flags int) nlRequest {
+		return testNlRequest{nil, errors.New("something went wrong")}
+	}
+	nlMu.Unlock()
+	defer func() {
+		nlMu.Lock()
+		newNlRequest = oldRequest
+		nlMu.Unlock()
+	}()
+	nl80211IDMu.Lock()
+	nl80211ID = 0
+	nl80211IDMu.Unlock()
+
+	_, err := Wireless("wlan0")
+	require.Error(t, err)
+}
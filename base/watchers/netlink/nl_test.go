@@ -15,8 +15,10 @@
 package netlink
 
 import (
+	"encoding/binary"
 	"errors"
 	"net"
+	"strings"
 	"syscall"
 
 	"github.com/vishvananda/netlink/nl"
@@ -69,7 +71,7 @@ func (t testNlRequest) Execute(int, uint16) ([][]byte, error) {
 	return data, t.err
 }
 
-func setInitialData(getLinks, getAddrs testNlRequest) {
+func setInitialData(getLinks, getAddrs testNlRequest, getRoutes ...testNlRequest) {
 	nlMu.Lock()
 	defer nlMu.Unlock()
 	newNlRequest = func(proto, flags int) nlRequest {
@@ -78,6 +80,11 @@ func setInitialData(getLinks, getAddrs testNlRequest) {
 			return getLinks
 		case unix.RTM_GETADDR:
 			return getAddrs
+		case unix.RTM_GETROUTE:
+			if len(getRoutes) > 0 {
+				return getRoutes[0]
+			}
+			return testNlRequest{}
 		default:
 			return testNlRequest{nil, errors.New("unexpected request")}
 		}
@@ -135,3 +142,60 @@ func msgDelAddrs(linkIdx int, addr, localAddr net.IP) syscall.NetlinkMessage {
 	m.Header.Type = unix.RTM_DELADDR
 	return m
 }
+
+func msgNewRoute(linkIdx int, table uint8, dstLen uint8, r Route) syscall.NetlinkMessage {
+	data := nl.NewRtMsg()
+	data.Family = unix.AF_INET
+	data.Table = table
+	data.Dst_len = dstLen
+	oif := make([]byte, 4)
+	native.PutUint32(oif, uint32(linkIdx))
+	metric := make([]byte, 4)
+	native.PutUint32(metric, r.Metric)
+	attrs := []*nl.RtAttr{
+		nl.NewRtAttr(unix.RTA_OIF, oif),
+		nl.NewRtAttr(unix.RTA_PRIORITY, metric),
+	}
+	if r.Gateway != nil {
+		gw := r.Gateway
+		if v4 := gw.To4(); v4 != nil {
+			gw = v4
+		} else {
+			data.Family = unix.AF_INET6
+		}
+		attrs = append(attrs, nl.NewRtAttr(unix.RTA_GATEWAY, gw))
+	}
+	return makeNetlinkMessage(unix.RTM_NEWROUTE, data, attrs...)
+}
+
+func msgDelRoute(linkIdx int, r Route) syscall.NetlinkMessage {
+	m := msgNewRoute(linkIdx, unix.RT_TABLE_MAIN, 0, r)
+	m.Header.Type = unix.RTM_DELROUTE
+	return m
+}
+
+// msgSearchDomains constructs an ND user option message with a DNSSL option.
+func msgSearchDomains(linkIdx int, lifetime uint32, domains ...string) syscall.NetlinkMessage {
+	opt := []byte{ndOptDNSSL, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(opt[4:8], lifetime)
+	for _, d := range domains {
+		for _, label := range strings.Split(d, ".") {
+			opt = append(opt, byte(len(label)))
+			opt = append(opt, label...)
+		}
+		opt = append(opt, 0)
+	}
+	for len(opt)%8 != 0 {
+		opt = append(opt, 0)
+	}
+	opt[1] = byte(len(opt) / 8)
+	header := make([]byte, ndUserOptHeaderLen)
+	header[0] = unix.AF_INET6
+	native.PutUint16(header[2:4], uint16(len(opt)))
+	native.PutUint32(header[4:8], uint32(linkIdx))
+	header[8] = 134 // Router advertisement.
+	m := syscall.NetlinkMessage{}
+	m.Header.Type = unix.RTM_NEWNDUSEROPT
+	m.Data = append(header, opt...)
+	return m
+}
@@ -0,0 +1,234 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlink
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"strings"
+
+	l "github.com/leosunmo/barista/logging"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// Route represents a default route through a link.
+type Route struct {
+	// Gateway is nil for routes that don't use a gateway, e.g. point-to-point
+	// links like VPN tunnels.
+	Gateway net.IP
+	// Metric is the priority of the route, lower values are preferred.
+	Metric uint32
+}
+
+func (r Route) equal(other Route) bool {
+	return r.Metric == other.Metric && r.Gateway.Equal(other.Gateway)
+}
+
+var (
+	// Default routes and search domains by link, protected by linksMu. These
+	// are kept separately since route messages can arrive for links that
+	// are not yet known.
+	routes        = map[LinkIndex][]Route{}
+	searchDomains = map[LinkIndex][]string{}
+)
+
+// defaultRouteFromMsg returns the default route in a route message, or false
+// if the message is not for a default route in the main table.
+func defaultRouteFromMsg(msg []byte) (LinkIndex, Route, bool) {
+	if len(msg) < unix.SizeofRtMsg {
+		return 0, Route{}, false
+	}
+	rtmsg := nl.DeserializeRtMsg(msg)
+	if rtmsg.Dst_len != 0 || rtmsg.Type != unix.RTN_UNICAST {
+		return 0, Route{}, false
+	}
+	table := uint32(rtmsg.Table)
+	var linkIndex LinkIndex
+	var route Route
+	attrs, _ := nl.ParseRouteAttr(msg[unix.SizeofRtMsg:])
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case unix.RTA_TABLE:
+			table = native.Uint32(attr.Value)
+		case unix.RTA_OIF:
+			linkIndex = LinkIndex(native.Uint32(attr.Value))
+		case unix.RTA_GATEWAY:
+			route.Gateway = net.IP(attr.Value)
+		case unix.RTA_PRIORITY:
+			route.Metric = native.Uint32(attr.Value)
+		}
+	}
+	if table != unix.RT_TABLE_MAIN || linkIndex == 0 {
+		return 0, Route{}, false
+	}
+	return linkIndex, route, true
+}
+
+// ndUserOptHeaderLen is the size of struct nduseroptmsg.
+const ndUserOptHeaderLen = 16
+
+// DNS Search List option, from RFC 8106.
+const ndOptDNSSL = 31
+
+// searchDomainsFromMsg returns the DNS search domains in a router
+// advertisement forwarded by the kernel, or false if there are none.
+// A lifetime of zero removes the search domains, which is returned as an
+// empty list.
+func searchDomainsFromMsg(msg []byte) (LinkIndex, []string, bool) {
+	if len(msg) < ndUserOptHeaderLen {
+		return 0, nil, false
+	}
+	optsLen := int(native.Uint16(msg[2:4]))
+	linkIndex := LinkIndex(int32(native.Uint32(msg[4:8])))
+	opts := msg[ndUserOptHeaderLen:]
+	if optsLen < len(opts) {
+		opts = opts[:optsLen]
+	}
+	for len(opts) >= 8 {
+		typ, length := opts[0], int(opts[1])*8
+		if length == 0 || length > len(opts) {
+			break
+		}
+		if typ == ndOptDNSSL && length >= 8 {
+			lifetime := binary.BigEndian.Uint32(opts[4:8])
+			if lifetime == 0 {
+				return linkIndex, []string{}, true
+			}
+			return linkIndex, parseDomainNames(opts[8:length]), true
+		}
+		opts = opts[length:]
+	}
+	return 0, nil, false
+}
+
+// parseDomainNames parses a sequence of domain names in DNS wire format,
+// ignoring any trailing padding.
+func parseDomainNames(data []byte) []string {
+	domains := []string{}
+	var labels []string
+	for len(data) > 0 {
+		n := int(data[0])
+		data = data[1:]
+		if n == 0 {
+			if len(labels) == 0 {
+				// Padding.
+				continue
+			}
+			domains = append(domains, strings.Join(labels, "."))
+			labels = nil
+			continue
+		}
+		if n > len(data) {
+			break
+		}
+		labels = append(labels, string(data[:n]))
+		data = data[n:]
+	}
+	return domains
+}
+
+func addRoute(index LinkIndex, route Route) {
+	linksMu.Lock()
+	defer linksMu.Unlock()
+	for _, r := range routes[index] {
+		if r.equal(route) {
+			l.Fine("Route %v for %d already present, skipping add", route, index)
+			return
+		}
+	}
+	l.Fine("Adding route %v for %d", route, index)
+	routes[index] = append(routes[index], route)
+	updateRoutesLocked()
+}
+
+func delRoute(index LinkIndex, route Route) {
+	linksMu.Lock()
+	defer linksMu.Unlock()
+	for i, r := range routes[index] {
+		if r.equal(route) {
+			l.Fine("Deleting route %v for %d", route, index)
+			routes[index] = append(routes[index][:i], routes[index][i+1:]...)
+			updateRoutesLocked()
+			return
+		}
+	}
+	l.Fine("Route %v for %d not present, skipping delete", route, index)
+}
+
+func setSearchDomains(index LinkIndex, domains []string) {
+	linksMu.Lock()
+	defer linksMu.Unlock()
+	if len(domains) == 0 {
+		delete(searchDomains, index)
+	} else {
+		searchDomains[index] = domains
+	}
+	updateRoutesLocked()
+}
+
+// updateRoutesLocked updates the default routes and search domains of all
+// links, and notifies subscribers of any changed links. Must be called with
+// linksMu held.
+func updateRoutesLocked() {
+	var best LinkIndex
+	var bestMetric uint32
+	for index, rs := range routes {
+		if _, ok := links[index]; !ok {
+			continue
+		}
+		for _, r := range rs {
+			if best == 0 || r.Metric < bestMetric ||
+				(r.Metric == bestMetric && index < best) {
+				best, bestMetric = index, r.Metric
+			}
+		}
+	}
+	var changed []string
+	for index, link := range links {
+		rs := append([]Route(nil), routes[index]...)
+		sort.SliceStable(rs, func(a, b int) bool { return rs[a].Metric < rs[b].Metric })
+		if len(rs) == 0 {
+			rs = nil
+		}
+		domains := searchDomains[index]
+		if !routesEqual(rs, link.DefaultRoutes) ||
+			(index == best) != link.Default ||
+			strings.Join(domains, " ") != strings.Join(link.SearchDomains, " ") {
+			link.DefaultRoutes = rs
+			link.Default = index == best
+			link.SearchDomains = domains
+			links[index] = link
+			changed = append(changed, link.Name)
+		}
+	}
+	if len(changed) > 0 {
+		notifyChanged(changed...)
+	}
+}
+
+func routesEqual(a, b []Route) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlink
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/leosunmo/barista/testing/notifier"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestInitialRoutes(t *testing.T) {
+	reset()
+	gw := net.IPv4(192, 168, 0, 1).To4()
+	setInitialData(testNlRequest{
+		msgs: []syscall.NetlinkMessage{
+			msgNewLink(1, Link{Name: "eth0", State: Up, HardwareAddr: hwA[1]}),
+			msgNewLink(2, Link{Name: "wlan0", State: Up, HardwareAddr: hwA[2]}),
+		},
+	}, testNlRequest{}, testNlRequest{
+		msgs: []syscall.NetlinkMessage{
+			// Non-default route.
+			msgNewRoute(1, unix.RT_TABLE_MAIN, 24, Route{Metric: 100}),
+			// Default route in a different table.
+			msgNewRoute(2, unix.RT_TABLE_LOCAL, 0, Route{Gateway: gw}),
+			msgNewRoute(2, unix.RT_TABLE_MAIN, 0, Route{Gateway: gw, Metric: 600}),
+		},
+	})
+
+	links := All().Get()
+	require.Equal(t, []Link{
+		{
+			Name:          "wlan0",
+			State:         Up,
+			HardwareAddr:  hwA[2],
+			DefaultRoutes: []Route{{Gateway: gw, Metric: 600}},
+			Default:       true,
+		},
+		{Name: "eth0", State: Up, HardwareAddr: hwA[1]},
+	}, links, "default route link is preferred over name")
+	require.Equal(t, gw, links[0].Gateway())
+	require.Nil(t, links[1].Gateway())
+}
+
+func TestRouteUpdates(t *testing.T) {
+	reset()
+	setInitialData(testNlRequest{
+		msgs: []syscall.NetlinkMessage{
+			msgNewLink(1, Link{Name: "eth0", State: Up}),
+			msgNewLink(2, Link{Name: "wlan0", State: Up}),
+			msgNewLink(3, Link{Name: "tun0", State: Unknown}),
+		},
+	}, testNlRequest{})
+	msgCh, _ := returnTestSubscriber()
+
+	subAny := Any()
+	nextAny := subAny.Next()
+	subWlan := ByName("wlan0")
+	nextWlan := subWlan.Next()
+	require.Equal(t, "eth0", subAny.Get().Name)
+
+	wifiGw := net.IPv4(192, 168, 1, 1).To4()
+	msgCh <- msgNewRoute(2, unix.RT_TABLE_MAIN, 0, Route{Gateway: wifiGw, Metric: 600})
+	nextAny = assertUpdated(t, nextAny, subAny, "on default route")
+	nextWlan = assertUpdated(t, nextWlan, subWlan, "on default route")
+	require.Equal(t, "wlan0", subAny.Get().Name, "link with default route preferred")
+	require.True(t, subWlan.Get().Default)
+	require.Equal(t, wifiGw, subWlan.Get().Gateway())
+
+	ethGw := net.IPv4(10, 0, 0, 1).To4()
+	msgCh <- msgNewRoute(1, unix.RT_TABLE_MAIN, 0, Route{Gateway: ethGw, Metric: 100})
+	nextAny = assertUpdated(t, nextAny, subAny, "on better default route")
+	nextWlan = assertUpdated(t, nextWlan, subWlan, "on losing default")
+	require.Equal(t, "eth0", subAny.Get().Name, "lower metric preferred")
+	require.Equal(t, ethGw, subAny.Get().Gateway())
+	require.False(t, subWlan.Get().Default)
+	require.Equal(t, wifiGw, subWlan.Get().Gateway(), "gateway is kept")
+
+	msgCh <- msgNewRoute(1, unix.RT_TABLE_MAIN, 0, Route{Gateway: ethGw, Metric: 100})
+	notifier.AssertNoUpdate(t, nextAny, "on duplicate route")
+
+	msgCh <- msgNewRoute(1, unix.RT_TABLE_MAIN, 16, Route{Gateway: ethGw, Metric: 10})
+	notifier.AssertNoUpdate(t, nextAny, "on non-default route")
+
+	msgCh <- msgNewRoute(3, unix.RT_TABLE_MAIN, 0, Route{Metric: 50})
+	nextAny = assertUpdated(t, nextAny, subAny, "on vpn route")
+	require.Equal(t, "eth0", subAny.Get().Name, "link state is still preferred")
+	tun0 := All().Get()[2]
+	require.Equal(t, "tun0", tun0.Name)
+	require.True(t, tun0.Default)
+	require.Nil(t, tun0.Gateway(), "no gateway for point-to-point route")
+
+	msgCh <- msgDelRoute(3, Route{Metric: 50})
+	nextAny = assertUpdated(t, nextAny, subAny, "on vpn route removed")
+	require.True(t, subAny.Get().Default)
+
+	msgCh <- msgDelRoute(1, Route{Gateway: ethGw, Metric: 100})
+	nextAny = assertUpdated(t, nextAny, subAny, "on route removed")
+	nextWlan = assertUpdated(t, nextWlan, subWlan, "on regaining default")
+	require.Equal(t, "wlan0", subAny.Get().Name)
+
+	msgCh <- msgDelRoute(1, Route{Gateway: ethGw, Metric: 100})
+	notifier.AssertNoUpdate(t, nextAny, "on removing non-existent route")
+
+	msgCh <- msgSearchDomains(2, 600, "corp.example.com", "example.com")
+	nextAny = assertUpdated(t, nextAny, subAny, "on search domains")
+	nextWlan = assertUpdated(t, nextWlan, subWlan, "on search domains")
+	require.Equal(t, []string{"corp.example.com", "example.com"}, subWlan.Get().SearchDomains)
+
+	msgCh <- msgSearchDomains(2, 0, "corp.example.com")
+	nextAny = assertUpdated(t, nextAny, subAny, "on search domains expired")
+	assertUpdated(t, nextWlan, subWlan, "on search domains expired")
+	require.Empty(t, subWlan.Get().SearchDomains)
+
+	msgCh <- msgDelLink(2, Link{})
+	assertUpdated(t, nextAny, subAny, "on link removed")
+	require.Equal(t, "eth0", subAny.Get().Name)
+	require.False(t, subAny.Get().Default)
+}
+
+func TestRoutesTestMode(t *testing.T) {
+	nlt := TestMode()
+	nlt.AddLink(Link{Name: "eth0", State: Up})
+	wlan := nlt.AddLink(Link{Name: "wlan0", State: Up})
+	sub := Any()
+	next := sub.Next()
+	require.Equal(t, "eth0", sub.Get().Name)
+
+	gw := net.IPv4(192, 168, 1, 1)
+	nlt.AddRoute(wlan, Route{Gateway: gw, Metric: 600})
+	next = assertUpdated(t, next, sub)
+	require.Equal(t, "wlan0", sub.Get().Name)
+
+	nlt.UpdateLink(wlan, Link{HardwareAddr: hwA[3]})
+	next = assertUpdated(t, next, sub)
+	require.True(t, sub.Get().Default, "route info is kept on link update")
+
+	nlt.SetSearchDomains(wlan, "example.com")
+	next = assertUpdated(t, next, sub)
+	require.Equal(t, []string{"example.com"}, sub.Get().SearchDomains)
+
+	nlt.RemoveRoute(wlan, Route{Gateway: gw, Metric: 600})
+	assertUpdated(t, next, sub)
+	require.Equal(t, "eth0", sub.Get().Name)
+}
+
+func TestSearchDomainParsing(t *testing.T) {
+	idx, domains, ok := searchDomainsFromMsg(msgSearchDomains(4, 30, "a.example", "b").Data)
+	require.True(t, ok)
+	require.Equal(t, LinkIndex(4), idx)
+	require.Equal(t, []string{"a.example", "b"}, domains)
+
+	_, _, ok = searchDomainsFromMsg([]byte{1, 2, 3})
+	require.False(t, ok, "truncated message")
+
+	msg := msgSearchDomains(4, 30, "example")
+	msg.Data[ndUserOptHeaderLen] = 25 // RDNSS
+	_, _, ok = searchDomainsFromMsg(msg.Data)
+	require.False(t, ok, "without DNSSL option")
+}
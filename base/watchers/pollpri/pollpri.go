@@ -0,0 +1,107 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pollpri watches files that signal changes using POLLPRI, such as
+// /proc/self/mountinfo or PSI triggers in /proc/pressure.
+package pollpri
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// ErrClosed is returned by Wait once the watcher is closed.
+	ErrClosed = errors.New("watcher closed")
+	// ErrPoll is returned by Wait if the file signals POLLERR, and POLLERR
+	// is not one of the events being watched.
+	ErrPoll = errors.New("file signalled POLLERR")
+)
+
+// Watcher waits for events on a file descriptor. A pipe is used to interrupt
+// poll on close.
+type Watcher struct {
+	events int16
+	mu     sync.Mutex
+	fd     int
+	wake   [2]int
+	closed bool
+}
+
+// New creates a watcher that waits for any of the given events (e.g.
+// unix.POLLPRI) on fd, taking ownership of fd. Some files signal changes
+// using POLLERR as well (e.g. mountinfo), while others use it for failures
+// (e.g. removed pressure files), so POLLERR is only treated as a change if
+// included in events.
+func New(fd int, events int16) (*Watcher, error) {
+	w := &Watcher{events: events, fd: fd}
+	if err := unix.Pipe2(w.wake[:], unix.O_NONBLOCK|unix.O_CLOEXEC); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return w, nil
+}
+
+// Wait blocks until any of the watched events occur. It returns ErrClosed
+// once the watcher is closed, and the watcher cannot be used after Wait
+// returns any error.
+func (w *Watcher) Wait() error {
+	for {
+		fds := []unix.PollFd{
+			{Fd: int32(w.fd), Events: w.events},
+			{Fd: int32(w.wake[0]), Events: unix.POLLIN},
+		}
+		// The kernel clears POLLPRI events when polled, so there's no need
+		// to read the file here.
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		switch {
+		case err != nil:
+		case fds[1].Revents != 0:
+			err = ErrClosed
+		case fds[0].Revents&unix.POLLERR != 0 && w.events&unix.POLLERR == 0:
+			err = ErrPoll
+		case fds[0].Revents&w.events != 0:
+			return nil
+		default:
+			continue
+		}
+		w.release()
+		return err
+	}
+}
+
+// Close stops watching, and unblocks any pending Wait.
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		unix.Write(w.wake[1], []byte{0})
+	}
+}
+
+// release closes all file descriptors. Must only be called by Wait, so that
+// the descriptors are not closed (and potentially reused) while polling.
+func (w *Watcher) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	unix.Close(w.fd)
+	unix.Close(w.wake[0])
+	unix.Close(w.wake[1])
+}
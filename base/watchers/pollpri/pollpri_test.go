@@ -0,0 +1,67 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pollpri
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// brokenPipe returns the write end of a pipe whose read end is closed, which
+// signals POLLERR.
+func brokenPipe(t *testing.T) int {
+	var p [2]int
+	require.NoError(t, unix.Pipe2(p[:], unix.O_NONBLOCK|unix.O_CLOEXEC))
+	unix.Close(p[0])
+	return p[1]
+}
+
+func wait(w *Watcher) <-chan error {
+	errs := make(chan error, 1)
+	go func() { errs <- w.Wait() }()
+	return errs
+}
+
+func TestClose(t *testing.T) {
+	fd, err := unix.Open("/proc/self/mountinfo", unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	require.NoError(t, err)
+	w, err := New(fd, unix.POLLPRI)
+	require.NoError(t, err)
+	errs := wait(w)
+	select {
+	case err := <-errs:
+		require.Fail(t, "unexpected event", "%v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	w.Close()
+	require.Equal(t, ErrClosed, <-errs)
+	w.Close() // no-op once released.
+}
+
+func TestPollErr(t *testing.T) {
+	w, err := New(brokenPipe(t), unix.POLLPRI)
+	require.NoError(t, err)
+	require.Equal(t, ErrPoll, <-wait(w), "POLLERR not watched")
+
+	w, err = New(brokenPipe(t), unix.POLLPRI|unix.POLLERR)
+	require.NoError(t, err)
+	require.NoError(t, <-wait(w), "POLLERR watched")
+	require.NoError(t, <-wait(w), "still watching")
+	w.Close()
+	require.Equal(t, ErrClosed, <-wait(w))
+}
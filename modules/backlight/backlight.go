@@ -0,0 +1,254 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backlight provides an i3bar module that shows and controls screen
+// brightness, using /sys/class/backlight and systemd-logind.
+package backlight
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/value"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	"github.com/leosunmo/barista/base/watchers/file"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+
+	"github.com/spf13/afero"
+)
+
+const backlightDir = "/sys/class/backlight"
+
+// Info represents the brightness of a backlight device.
+type Info struct {
+	// Device is the name of the device in /sys/class/backlight.
+	Device string
+	// Brightness is the raw brightness, from 0 to Max.
+	Brightness, Max int
+	// gamma maps perceived brightness to raw brightness.
+	gamma float64
+}
+
+// Frac returns the raw brightness as a fraction of the maximum.
+func (i Info) Frac() float64 {
+	if i.Max == 0 {
+		return 0
+	}
+	return float64(i.Brightness) / float64(i.Max)
+}
+
+// Pct returns the perceived brightness in the range 0-100, using the curve
+// configured on the module.
+func (i Info) Pct() int {
+	return int(math.Pow(i.Frac(), 1/i.gamma)*100 + 0.5)
+}
+
+// SetPct sets the perceived brightness, in the range 0-100.
+func (i Info) SetPct(pct int) error {
+	if pct < 0 {
+		pct = 0
+	}
+	if pct > 100 {
+		pct = 100
+	}
+	raw := int(math.Round(math.Pow(float64(pct)/100, i.gamma) * float64(i.Max)))
+	// Keep changes visible at the low end, where the curve is flat.
+	if raw == i.Brightness && pct != i.Pct() {
+		if pct > i.Pct() {
+			raw++
+		} else {
+			raw--
+		}
+	}
+	return i.SetBrightness(raw)
+}
+
+// SetBrightness sets the raw brightness, in the range 0-Max.
+func (i Info) SetBrightness(brightness int) error {
+	if brightness < 0 {
+		brightness = 0
+	}
+	if brightness > i.Max {
+		brightness = i.Max
+	}
+	if brightness == i.Brightness {
+		return nil
+	}
+	return setBrightness(i.Device, brightness)
+}
+
+// Module represents a backlight bar module.
+type Module struct {
+	device     string
+	outputFunc value.Value // of func(Info) bar.Output
+	step       value.Value // of int
+	gamma      value.Value // of float64
+}
+
+// New creates a module for the named backlight device, e.g. "intel_backlight".
+func New(device string) *Module {
+	m := &Module{device: device}
+	l.Label(m, device)
+	l.Register(m, "outputFunc", "step", "gamma")
+	m.step.Set(5)
+	m.gamma.Set(2.0)
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d%%", i.Pct())
+	})
+	return m
+}
+
+// Default creates a module for the first backlight device found, which is
+// usually the only one on laptops.
+func Default() *Module {
+	files, _ := afero.ReadDir(fs, backlightDir)
+	if len(files) == 0 {
+		return New("")
+	}
+	return New(files[0].Name())
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Step sets the amount, in perceived percent, by which the brightness is
+// changed when scrolling.
+func (m *Module) Step(pct int) *Module {
+	m.step.Set(pct)
+	return m
+}
+
+// Curve sets the exponent used to map perceived brightness to raw brightness.
+// Backlights are roughly linear in luminance, which appears to change much
+// faster at low levels, so the default of 2 makes each scroll step look
+// similar. Use 1 for a linear scale.
+func (m *Module) Curve(exponent float64) *Module {
+	if exponent <= 0 {
+		exponent = 1
+	}
+	m.gamma.Set(exponent)
+	return m
+}
+
+// defaultClickHandler adjusts the brightness by one step on scroll.
+func defaultClickHandler(i Info, step int) func(bar.Event) {
+	return func(e bar.Event) {
+		var err error
+		switch e.Button {
+		case bar.ScrollUp, bar.ScrollRight:
+			err = i.SetPct(i.Pct() + step)
+		case bar.ScrollDown, bar.ScrollLeft:
+			err = i.SetPct(i.Pct() - step)
+		}
+		if err != nil {
+			l.Log("Error updating brightness: %v", err)
+		}
+	}
+}
+
+// watchFile watches a file for changes. To allow tests to watch a file in
+// a temporary directory.
+var watchFile = file.Watch
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	if m.device == "" {
+		s.Error(errors.New("no backlight device found"))
+		return
+	}
+	dir := path.Join(backlightDir, m.device)
+	w := watchFile(path.Join(dir, "brightness"))
+	defer w.Unsubscribe()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	step := m.step.Get().(int)
+	nextStep, done := m.step.Subscribe()
+	defer done()
+	gamma := m.gamma.Get().(float64)
+	nextGamma, done := m.gamma.Subscribe()
+	defer done()
+
+	info, err := read(m.device)
+	for {
+		if s.Error(err) {
+			return
+		}
+		info.gamma = gamma
+		s.Output(outputs.Group(outputFunc(info)).
+			OnClick(defaultClickHandler(info, step)))
+		select {
+		case <-w.Updates:
+			info, err = read(m.device)
+		case err = <-w.Errors:
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextStep:
+			step = m.step.Get().(int)
+		case <-nextGamma:
+			gamma = m.gamma.Get().(float64)
+		}
+	}
+}
+
+var fs = afero.NewOsFs()
+
+func read(device string) (Info, error) {
+	i := Info{Device: device}
+	dir := path.Join(backlightDir, device)
+	var err error
+	if i.Max, err = readInt(path.Join(dir, "max_brightness")); err != nil {
+		return i, err
+	}
+	i.Brightness, err = readInt(path.Join(dir, "brightness"))
+	return i, err
+}
+
+func readInt(file string) (int, error) {
+	bytes, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(bytes)))
+}
+
+var busType = dbus.System
+
+const (
+	logindService = "org.freedesktop.login1"
+	logindSession = "/org/freedesktop/login1/session/auto"
+	sessionIface  = "org.freedesktop.login1.Session"
+)
+
+// setBrightness sets the brightness through systemd-logind, which allows
+// the user of the current session to change it without root.
+func setBrightness(device string, brightness int) error {
+	w := dbus.WatchProperties(busType, logindService, logindSession, sessionIface)
+	defer w.Unsubscribe()
+	_, err := w.Call("SetBrightness", "backlight", device, uint32(brightness))
+	if err != nil {
+		return fmt.Errorf("SetBrightness(%s, %d): %w", device, brightness, err)
+	}
+	return nil
+}
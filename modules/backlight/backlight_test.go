@@ -0,0 +1,157 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backlight
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	"github.com/leosunmo/barista/base/watchers/file"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain uses a temporary directory for sysfs, since changes are detected
+// using inotify. It is shared by all tests, since modules keep reading it
+// after each test ends, so each test uses different devices.
+func TestMain(m *testing.M) {
+	busType = dbus.Test
+	dir, err := os.MkdirTemp("", "backlight")
+	if err != nil {
+		panic(err)
+	}
+	fs = afero.NewBasePathFs(afero.NewOsFs(), dir)
+	watchFile = func(name string) *file.Watcher {
+		return file.Watch(filepath.Join(dir, name))
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func writeFile(t *testing.T, device, name string, value int) {
+	t.Helper()
+	dir := filepath.Join(backlightDir, device)
+	require.NoError(t, fs.MkdirAll(dir, 0755))
+	// Replace the file atomically, since sysfs files are never seen empty.
+	tmp := filepath.Join(dir, "."+name)
+	require.NoError(t, afero.WriteFile(fs, tmp, []byte(fmt.Sprintf("%d\n", value)), 0644))
+	require.NoError(t, fs.Rename(tmp, filepath.Join(dir, name)))
+}
+
+func setupLogind(t *testing.T) *dbus.TestBusObject {
+	bus := dbus.SetupTestBus()
+	session := bus.RegisterService(logindService).Object(logindSession, sessionIface)
+	session.On("SetBrightness", func(args ...interface{}) ([]interface{}, error) {
+		if args[0] != "backlight" {
+			return nil, errors.New("unsupported subsystem")
+		}
+		if _, err := fs.Stat(filepath.Join(backlightDir, args[1].(string))); err != nil {
+			return nil, err
+		}
+		writeFile(t, args[1].(string), "brightness", int(args[2].(uint32)))
+		return nil, nil
+	})
+	return session
+}
+
+func TestBacklight(t *testing.T) {
+	writeFile(t, "intel_backlight", "max_brightness", 1000)
+	writeFile(t, "intel_backlight", "brightness", 250)
+	setupLogind(t)
+	testBar.New(t)
+
+	m := New("intel_backlight")
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"50%"}, "perceived brightness")
+
+	writeFile(t, "intel_backlight", "brightness", 1000)
+	testBar.Drain(50*time.Millisecond, "on external change").AssertText([]string{"100%"})
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %d/%d %.2f", i.Device, i.Brightness, i.Max, i.Frac())
+	})
+	out = testBar.NextOutput("on output change")
+	out.AssertText([]string{"intel_backlight 1000/1000 1.00"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	// 95% perceived brightness with the default curve.
+	out = testBar.Drain(50*time.Millisecond, "on scroll down")
+	out.AssertText([]string{"intel_backlight 903/1000 0.90"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.Drain(50*time.Millisecond, "on scroll up")
+	out.AssertText([]string{"intel_backlight 1000/1000 1.00"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.AssertNoOutput("scroll up at max brightness")
+
+	m.Curve(1).Step(10)
+	testBar.NextOutput("on curve change")
+	out = testBar.NextOutput("on step change")
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.Drain(50*time.Millisecond, "on scroll down with linear curve")
+	out.AssertText([]string{"intel_backlight 900/1000 0.90"})
+
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("on left click")
+}
+
+func TestSetPct(t *testing.T) {
+	writeFile(t, "acpi_video0", "max_brightness", 15)
+	writeFile(t, "acpi_video0", "brightness", 0)
+	setupLogind(t)
+
+	read := func() int {
+		i, err := read("acpi_video0")
+		require.NoError(t, err)
+		return i.Brightness
+	}
+	i := Info{Device: "acpi_video0", Max: 15, gamma: 2}
+	require.NoError(t, i.SetPct(5))
+	require.Equal(t, 1, read(), "small changes still change brightness")
+
+	i.Brightness = 1
+	require.NoError(t, i.SetPct(200))
+	require.Equal(t, 15, read(), "clamped to max")
+
+	i.Brightness = 15
+	require.NoError(t, i.SetPct(-10))
+	require.Equal(t, 0, read(), "clamped to min")
+
+	i.Device = "other"
+	require.Error(t, i.SetBrightness(4), "error from logind")
+}
+
+func TestErrors(t *testing.T) {
+	testBar.New(t)
+
+	none := New("")
+	missing := New("missing")
+	testBar.Run(none, missing)
+	out := testBar.LatestOutput(0, 1)
+	out.At(0).AssertError("without any devices")
+	out.At(1).AssertError("without brightness files")
+}
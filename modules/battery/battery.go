@@ -18,6 +18,7 @@ package battery
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"math"
 	"strconv"
@@ -25,10 +26,11 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/helper"
+	"github.com/leosunmo/barista/base/sampler"
 	"github.com/leosunmo/barista/base/value"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/outputs"
-	"github.com/leosunmo/barista/timing"
 
 	"github.com/spf13/afero"
 )
@@ -70,6 +72,27 @@ type Info struct {
 	Status Status
 	// Technology of the battery, e.g. "Li-Ion", "Li-Poly", "Ni-MH".
 	Technology string
+	// Type of the device, e.g. "Battery", "Mouse", "Headset".
+	// Only set for UPower devices.
+	Type string
+	// Model of the device, e.g. the name of a peripheral.
+	// Only set for UPower devices.
+	Model string
+	// Estimated time until the battery is empty or full, if provided by
+	// the system (i.e. UPower).
+	TimeToEmpty time.Duration
+	TimeToFull  time.Duration
+	// Power averaged over the recent history, in W. Smooths out spikes in
+	// the current draw when estimating the remaining time. Only set for
+	// batteries read from sysfs, see Module.HistoryWindow.
+	AveragePower float64
+	// Number of charge cycles, if reported by the battery.
+	CycleCount int
+	// Charge thresholds in *percents*, if supported by the battery. Charging
+	// starts when the capacity drops below the start threshold, and stops
+	// when it reaches the end threshold. Zero if not supported.
+	ChargeStartThreshold int
+	ChargeEndThreshold   int
 }
 
 // Remaining returns the fraction of battery capacity remaining.
@@ -86,24 +109,49 @@ func (i Info) RemainingPct() int {
 }
 
 // RemainingTime returns the best guess for remaining time.
-// This is based on the current power draw and remaining capacity.
+// This is the estimate provided by the system if available, otherwise it's
+// based on the average power draw and remaining capacity.
 func (i Info) RemainingTime() time.Duration {
+	switch {
+	case i.Status == Discharging && i.TimeToEmpty > 0:
+		return i.TimeToEmpty
+	case i.Status == Charging && i.TimeToFull > 0:
+		return i.TimeToFull
+	}
+	power := i.AveragePower
+	if math.Nextafter(power, 0) == 0 {
+		power = i.Power
+	}
 	// Battery does not report current draw,
 	// cannot estimate remaining time.
-	if math.Nextafter(i.Power, 0) == 0 {
+	if math.Nextafter(power, 0) == 0 {
 		return 0
 	}
 	// According to ACPI spec, these calculations will return hours.
 	hours := 0.0
 	switch i.Status {
 	case Charging:
-		hours = (i.EnergyFull - i.EnergyNow) / i.Power
+		hours = (i.EnergyFull - i.EnergyNow) / power
 	case Discharging:
-		hours = i.EnergyNow / i.Power
+		hours = i.EnergyNow / power
 	}
 	return time.Duration(int(hours*3600)) * time.Second
 }
 
+// Health returns the capacity of the battery when full, as a fraction of its
+// design capacity, or zero if the design capacity is not known.
+func (i Info) Health() float64 {
+	if math.Nextafter(i.EnergyMax, 0) == 0 {
+		return 0
+	}
+	return i.EnergyFull / i.EnergyMax
+}
+
+// HealthPct returns the health of the battery as a percentage.
+func (i Info) HealthPct() int {
+	return int(math.Round(i.Health() * 100))
+}
+
 // Discharging returns true if the battery is being discharged.
 func (i Info) Discharging() bool {
 	return i.Status == Discharging
@@ -126,17 +174,24 @@ func (i Info) SignedPower() float64 {
 // Module represents a battery bar module. It supports setting the output
 // format, click handler, update frequency, and urgency/colour functions.
 type Module struct {
-	updateFunc func() Info
-	scheduler  *timing.Scheduler
+	sampler    *sampler.Sampler
 	outputFunc value.Value // of func(Info) bar.Output
+	helper     value.Value // of helper.Command
+	// Names of the sysfs batteries, for changing charge thresholds.
+	batteries     func() []string
+	historyWindow value.Value // of time.Duration
 }
 
-func newModule(updateFunc func() Info) *Module {
+func newModule(key string, updateFunc func() Info, batteries func() []string) *Module {
 	m := &Module{
-		updateFunc: updateFunc,
-		scheduler:  timing.NewScheduler(),
+		sampler: sampler.New(key, func() (interface{}, error) {
+			return updateFunc(), nil
+		}),
+		batteries: batteries,
 	}
-	l.Register(m, "scheduler", "format")
+	l.Register(m, "sampler", "format", "helper", "historyWindow")
+	m.helper.Set(helper.Command(nil))
+	m.historyWindow.Set(5 * time.Minute)
 	m.RefreshInterval(3 * time.Second)
 	// Construct a simple template that's just the available battery percent.
 	m.Output(func(i Info) bar.Output {
@@ -147,14 +202,16 @@ func newModule(updateFunc func() Info) *Module {
 
 // Named constructs an instance of the battery module for the given battery name.
 func Named(name string) *Module {
-	m := newModule(func() Info { return batteryInfo(name) })
+	m := newModule(batteryPath(name),
+		func() Info { return batteryInfo(name) },
+		func() []string { return []string{name} })
 	l.Label(m, name)
 	return m
 }
 
 // All constructs a battery module that aggregates all detected batteries.
 func All() *Module {
-	return newModule(allBatteriesInfo)
+	return newModule("/sys/class/power_supply", allBatteriesInfo, batteryNames)
 }
 
 // Output configures a module to display the output of a user-defined function.
@@ -165,21 +222,74 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 
 // RefreshInterval configures the polling frequency for battery info.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
-	m.scheduler.Every(interval)
+	m.sampler.Every(interval)
 	return m
 }
 
+// Helper configures the command used to change charge thresholds, which
+// usually requires root. The command is run with the battery name and the
+// new start and end thresholds appended to the given arguments, e.g.
+// Helper("pkexec", "/usr/local/bin/battery-helper") runs
+// `pkexec /usr/local/bin/battery-helper BAT0 40 80`.
+func (m *Module) Helper(cmd string, args ...string) *Module {
+	m.helper.Set(helper.New(cmd, args...))
+	return m
+}
+
+// HistoryWindow configures the duration over which the power draw is averaged
+// for Info.AveragePower, and the remaining time estimate. Longer windows give
+// more stable estimates, but take longer to reflect changes in load. A zero
+// window disables averaging.
+func (m *Module) HistoryWindow(window time.Duration) *Module {
+	m.historyWindow.Set(window)
+	return m
+}
+
+// SetChargeThresholds sets the charge start and end thresholds, in percent,
+// of the battery (or all batteries) using the helper.
+func (m *Module) SetChargeThresholds(start, end int) error {
+	if start < 0 || end > 100 || start >= end {
+		return fmt.Errorf("invalid charge thresholds %d-%d", start, end)
+	}
+	cmd := m.helper.Get().(helper.Command)
+	if len(cmd) == 0 {
+		return helper.ErrNotConfigured
+	}
+	batteries := m.batteries()
+	if len(batteries) == 0 {
+		return errors.New("no battery found")
+	}
+	for _, name := range batteries {
+		if err := cmd.Run(name, strconv.Itoa(start), strconv.Itoa(end)); err != nil {
+			return err
+		}
+	}
+	m.sampler.Refresh()
+	return nil
+}
+
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	info := m.updateFunc()
+	m.sampler.Refresh()
+	info, _ := m.sampler.Get()
+	nextInfo, done := m.sampler.Subscribe()
+	defer done()
 	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
+	var h history
+	withAverage := func(info interface{}) Info {
+		i := info.(Info)
+		i.AveragePower = h.add(i, m.historyWindow.Get().(time.Duration))
+		return i
+	}
+	i := withAverage(info)
 	for {
-		s.Output(outputFunc(info))
+		s.Output(outputFunc(i))
 		select {
-		case <-m.scheduler.C:
-			info = m.updateFunc()
+		case <-nextInfo:
+			info, _ = m.sampler.Get()
+			i = withAverage(info)
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
 		}
@@ -236,8 +346,12 @@ func fromStatusStr(str string) Status {
 
 var fs = afero.NewOsFs()
 
+func batteryPath(name string) string {
+	return fmt.Sprintf("/sys/class/power_supply/%s/uevent", name)
+}
+
 func batteryInfo(name string) Info {
-	batteryPath := fmt.Sprintf("/sys/class/power_supply/%s/uevent", name)
+	batteryPath := batteryPath(name)
 	l.Fine("Reading from %s", batteryPath)
 	f, err := fs.Open(batteryPath)
 	if err != nil {
@@ -248,7 +362,7 @@ func batteryInfo(name string) Info {
 	s := bufio.NewScanner(f)
 	s.Split(bufio.ScanLines)
 
-	var info Info
+	info := Info{}
 	var energyNow, powerNow, energyFull, energyMax electricValue
 	var energyNowProvided = false
 	for s.Scan() {
@@ -289,6 +403,12 @@ func batteryInfo(name string) Info {
 			info.Technology = value
 		case "CAPACITY":
 			info.Capacity, _ = strconv.Atoi(value)
+		case "CYCLE_COUNT":
+			info.CycleCount, _ = strconv.Atoi(value)
+		case "CHARGE_CONTROL_START_THRESHOLD":
+			info.ChargeStartThreshold, _ = strconv.Atoi(value)
+		case "CHARGE_CONTROL_END_THRESHOLD":
+			info.ChargeEndThreshold, _ = strconv.Atoi(value)
 		}
 	}
 
@@ -307,6 +427,29 @@ func batteryInfo(name string) Info {
 	return info
 }
 
+// isBattery returns true if the named power supply is a battery, as opposed
+// to e.g. the AC adapter.
+func isBattery(name string) bool {
+	powerSupplyTypePath := fmt.Sprintf("/sys/class/power_supply/%s/type", name)
+	powerSupplyType, err := afero.ReadFile(fs, powerSupplyTypePath)
+	return err == nil && bytes.Equal([]byte("Battery\n"), powerSupplyType)
+}
+
+// batteryNames returns the names of all batteries.
+func batteryNames() []string {
+	files, err := afero.ReadDir(fs, "/sys/class/power_supply")
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, f := range files {
+		if isBattery(f.Name()) {
+			names = append(names, f.Name())
+		}
+	}
+	return names
+}
+
 func allBatteriesInfo() Info {
 	dir, err := fs.Open("/sys/class/power_supply")
 	if err != nil {
@@ -320,15 +463,9 @@ func allBatteriesInfo() Info {
 	}
 	var infos []Info
 	for _, batt := range batts {
-		powerSupplyTypePath := fmt.Sprintf("/sys/class/power_supply/%s/type", batt)
-		powerSupplyType, err := afero.ReadFile(fs, powerSupplyTypePath)
-		if err != nil {
-			continue
+		if isBattery(batt) {
+			infos = append(infos, batteryInfo(batt))
 		}
-		if !bytes.Equal([]byte("Battery\n"), powerSupplyType) {
-			continue
-		}
-		infos = append(infos, batteryInfo(batt))
 	}
 	if len(infos) == 0 {
 		return Info{Status: Disconnected}
@@ -343,6 +480,14 @@ func allBatteriesInfo() Info {
 		if info.Technology != "" {
 			techs = append(techs, info.Technology)
 		}
+		if info.CycleCount > allInfo.CycleCount {
+			allInfo.CycleCount = info.CycleCount
+		}
+		// Thresholds are usually the same for all batteries.
+		if allInfo.ChargeEndThreshold == 0 {
+			allInfo.ChargeStartThreshold = info.ChargeStartThreshold
+			allInfo.ChargeEndThreshold = info.ChargeEndThreshold
+		}
 		voltEnergySum += info.Voltage * info.EnergyNow
 		signedPower := allInfo.SignedPower() + info.SignedPower()
 		allInfo.Power = math.Abs(signedPower)
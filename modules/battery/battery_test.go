@@ -22,8 +22,11 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/helper"
+	"github.com/leosunmo/barista/base/watchers/dbus"
 	"github.com/leosunmo/barista/outputs"
 	testBar "github.com/leosunmo/barista/testing/bar"
+	"github.com/leosunmo/barista/timing"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
@@ -376,3 +379,210 @@ func TestCombined(t *testing.T) {
 	testBar.NextOutput().AssertText([]string{
 		"Discharging - 50/5h0m0s"})
 }
+
+func TestSavePower(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	dbus.SetupTestBus()
+	defer SavePower(nil)
+
+	bat0 := battery{
+		"NAME":        "BAT0",
+		"STATUS":      "Charging",
+		"PRESENT":     1,
+		"VOLTAGE_NOW": 20 * micros,
+		"POWER_NOW":   10 * micros,
+		"ENERGY_FULL": 50 * micros,
+		"ENERGY_NOW":  25 * micros,
+	}
+	write(t, bat0)
+
+	SavePower(timing.Stretch(4))
+	require.Equal(t, time.Second, timing.AdjustInterval(time.Second),
+		"intervals unchanged on AC power")
+
+	bat0["STATUS"] = "Discharging"
+	write(t, bat0)
+	timing.NextTick()
+	require.Eventually(t, func() bool {
+		return timing.AdjustInterval(time.Second) == 4*time.Second
+	}, time.Second, time.Millisecond, "intervals stretched on battery")
+
+	SavePower(timing.Stretch(2))
+	require.Equal(t, 2*time.Second, timing.AdjustInterval(time.Second),
+		"new policy applied immediately on battery")
+
+	bat0["STATUS"] = "Full"
+	write(t, bat0)
+	timing.NextTick()
+	require.Eventually(t, func() bool {
+		return timing.AdjustInterval(time.Second) == time.Second
+	}, time.Second, time.Millisecond, "intervals restored on AC power")
+
+	bat0["STATUS"] = "Discharging"
+	write(t, bat0)
+	SavePower(timing.Stretch(3))
+	require.Equal(t, 3*time.Second, timing.AdjustInterval(time.Second),
+		"checks power immediately")
+	SavePower(nil)
+	require.Equal(t, time.Second, timing.AdjustInterval(time.Second),
+		"intervals restored when disabled")
+}
+
+func TestSavePowerUPower(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	bus := dbus.SetupTestBus()
+	upower := bus.RegisterService(upowerService).Object(upowerPath, upowerIface)
+	upower.SetProperties(map[string]interface{}{"OnBattery": true}, dbus.SignalTypeNone)
+	defer SavePower(nil)
+
+	SavePower(timing.Stretch(4))
+	require.Equal(t, 4*time.Second, timing.AdjustInterval(time.Second),
+		"intervals stretched on battery")
+
+	upower.SetProperties(map[string]interface{}{"OnBattery": false}, dbus.SignalTypeChanged)
+	require.Eventually(t, func() bool {
+		return timing.AdjustInterval(time.Second) == time.Second
+	}, time.Second, time.Millisecond, "intervals restored on AC power without polling")
+
+	upower.SetProperties(map[string]interface{}{"OnBattery": true}, dbus.SignalTypeChanged)
+	require.Eventually(t, func() bool {
+		return timing.AdjustInterval(time.Second) == 4*time.Second
+	}, time.Second, time.Millisecond, "intervals stretched on battery")
+	require.Equal(t, timing.Now(), timing.NextTick(), "batteries not polled")
+}
+
+func TestHistoryAndHealth(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+
+	bat5 := battery{
+		"NAME":               "BAT5",
+		"STATUS":             "Discharging",
+		"VOLTAGE_NOW":        12 * micros,
+		"POWER_NOW":          10 * micros,
+		"ENERGY_FULL_DESIGN": 75 * micros,
+		"ENERGY_FULL":        60 * micros,
+		"ENERGY_NOW":         30 * micros,
+		"CYCLE_COUNT":        123,
+	}
+	write(t, bat5)
+
+	infos := make(chan Info, 10)
+	m := Named("BAT5").Output(func(i Info) bar.Output {
+		infos <- i
+		return outputs.Textf("%v", i.RemainingTime())
+	})
+	testBar.Run(m)
+	testBar.LatestOutput().AssertText([]string{"3h0m0s"}, "on start")
+	info := <-infos
+	require.Equal(t, 0.8, info.Health())
+	require.Equal(t, 80, info.HealthPct())
+	require.Equal(t, 123, info.CycleCount)
+	require.Zero(t, info.ChargeEndThreshold, "without thresholds")
+
+	bat5["POWER_NOW"] = 20 * micros
+	write(t, bat5)
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{"2h0m0s"}, "averages power draw")
+
+	bat5["POWER_NOW"] = 30 * micros
+	write(t, bat5)
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{"1h30m0s"})
+
+	m.HistoryWindow(5 * time.Second)
+	bat5["POWER_NOW"] = 40 * micros
+	write(t, bat5)
+	testBar.Tick()
+	// Only 25W (between 20W and 30W) for 2s and 35W for 3s are within the
+	// window, for an average of 31W.
+	testBar.LatestOutput().AssertText([]string{"58m3s"}, "drops old readings")
+
+	bat5["POWER_NOW"] = 100 * micros
+	write(t, bat5)
+	m.sampler.Refresh()
+	testBar.LatestOutput().AssertText([]string{"58m3s"},
+		"extra reading not weighted until time passes")
+
+	bat5["STATUS"] = "Charging"
+	bat5["POWER_NOW"] = 10 * micros
+	write(t, bat5)
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{"3h0m0s"}, "resets on status change")
+
+	delete(bat5, "POWER_NOW")
+	write(t, bat5)
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{"3h0m0s"}, "ignores missing power")
+
+	require.Zero(t, Info{}.Health(), "without design capacity")
+}
+
+type call struct {
+	cmd  string
+	args []string
+}
+
+func TestChargeThresholds(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+
+	bat6 := battery{
+		"NAME":                           "BAT6",
+		"STATUS":                         "Not charging",
+		"CAPACITY":                       60,
+		"CHARGE_CONTROL_START_THRESHOLD": 40,
+		"CHARGE_CONTROL_END_THRESHOLD":   60,
+	}
+	write(t, bat6)
+	bat7 := battery{
+		"NAME":                           "BAT7",
+		"CHARGE_CONTROL_START_THRESHOLD": 40,
+		"CHARGE_CONTROL_END_THRESHOLD":   60,
+		"CYCLE_COUNT":                    80,
+	}
+	write(t, bat7)
+
+	calls := make(chan call, 10)
+	helper.SetRunnerForTest(func(name string, args ...string) error {
+		calls <- call{name, args}
+		bat6["CHARGE_CONTROL_START_THRESHOLD"] = args[len(args)-2]
+		bat6["CHARGE_CONTROL_END_THRESHOLD"] = args[len(args)-1]
+		write(t, bat6)
+		return nil
+	})
+	defer helper.SetRunnerForTest(nil)
+
+	m := Named("BAT6").Output(func(i Info) bar.Output {
+		return outputs.Textf("%d-%d", i.ChargeStartThreshold, i.ChargeEndThreshold)
+	})
+	testBar.Run(m)
+	testBar.LatestOutput().AssertText([]string{"40-60"}, "on start")
+	require.Error(t, m.SetChargeThresholds(50, 90), "without helper")
+
+	m.Helper("pkexec", "/usr/bin/battery-helper")
+	require.Error(t, m.SetChargeThresholds(90, 50))
+	require.Error(t, m.SetChargeThresholds(0, 101))
+	require.Empty(t, calls, "invalid thresholds")
+
+	require.NoError(t, m.SetChargeThresholds(50, 90))
+	require.Equal(t, call{"pkexec", []string{"/usr/bin/battery-helper", "BAT6", "50", "90"}}, <-calls)
+	testBar.NextOutput("on refresh").AssertText([]string{"50-90"})
+
+	all := All().Helper("helper")
+	require.NoError(t, all.SetChargeThresholds(40, 80))
+	require.ElementsMatch(t, []string{"BAT6", "BAT7"},
+		[]string{(<-calls).args[0], (<-calls).args[0]}, "sets all batteries")
+
+	info := allBatteriesInfo()
+	require.Equal(t, 80, info.CycleCount)
+	require.Contains(t, [][2]int{{40, 80}, {40, 60}},
+		[2]int{info.ChargeStartThreshold, info.ChargeEndThreshold},
+		"thresholds from one of the batteries")
+
+	fs = afero.NewMemMapFs()
+	require.Error(t, all.SetChargeThresholds(40, 80), "without batteries")
+	require.Empty(t, calls)
+}
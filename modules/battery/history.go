@@ -0,0 +1,73 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package battery
+
+import (
+	"time"
+
+	"github.com/leosunmo/barista/timing"
+)
+
+type powerSample struct {
+	at    time.Time
+	power float64
+}
+
+// history keeps recent power readings of a battery, discarding them whenever
+// the battery status changes (e.g. when plugged in).
+type history struct {
+	status  Status
+	samples []powerSample
+}
+
+// add records the power reading from info, and returns the average power
+// over the given window. Readings are weighted by the time between them, so
+// that extra readings (e.g. on click) don't skew the average.
+func (h *history) add(i Info, window time.Duration) float64 {
+	if i.Status != h.status {
+		h.status = i.Status
+		h.samples = nil
+	}
+	now := timing.Now()
+	// Batteries that don't report power draw would otherwise drag the
+	// average towards zero.
+	if i.Power > 0 {
+		h.samples = append(h.samples, powerSample{now, i.Power})
+	}
+	// The last reading before the window is kept for the interval that
+	// spans the start of the window.
+	start := now.Add(-window)
+	for len(h.samples) > 1 && !h.samples[1].at.After(start) {
+		h.samples = h.samples[1:]
+	}
+	if len(h.samples) == 0 {
+		return 0
+	}
+	energy, duration := 0.0, time.Duration(0)
+	for idx := 1; idx < len(h.samples); idx++ {
+		prev, cur := h.samples[idx-1], h.samples[idx]
+		from := prev.at
+		if from.Before(start) {
+			from = start
+		}
+		// Power is assumed to change linearly between readings.
+		energy += (prev.power + cur.power) / 2 * cur.at.Sub(from).Seconds()
+		duration += cur.at.Sub(from)
+	}
+	if duration <= 0 {
+		return h.samples[len(h.samples)-1].power
+	}
+	return energy / duration.Seconds()
+}
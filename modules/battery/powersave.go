@@ -0,0 +1,143 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package battery
+
+import (
+	"sync"
+	"time"
+
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/timing"
+)
+
+// powerCheckInterval is how often the batteries are checked while saving
+// power if UPower is not available. It's aligned to the wall clock, so that
+// it's not itself affected by the interval policy, and AC power is detected
+// promptly.
+const powerCheckInterval = 5 * time.Second
+
+var powerSave struct {
+	sync.Mutex
+	policy    timing.IntervalPolicy
+	watcher   *dbus.PropertiesWatcher
+	scheduler *timing.Scheduler
+	stop      chan struct{}
+	onBattery bool
+}
+
+// SavePower applies the given interval policy to all repeating schedulers
+// (e.g. the refresh intervals of cpuload, netspeed, or shell modules) while
+// the system is running on battery, and restores the requested intervals as
+// soon as it's plugged in again. For example:
+//
+//	battery.SavePower(timing.Stretch(3))
+//
+// or, to only relax frequent updates:
+//
+//	battery.SavePower(timing.IntervalTable(map[time.Duration]time.Duration{
+//		time.Second:     5 * time.Second,
+//		3 * time.Second: 10 * time.Second,
+//	}))
+//
+// Power changes are detected using UPower's OnBattery property if UPower is
+// running, and by polling the batteries in sysfs otherwise.
+//
+// Passing nil stops saving power, and restores the requested intervals.
+func SavePower(policy timing.IntervalPolicy) {
+	powerSave.Lock()
+	powerSave.policy = policy
+	if policy == nil {
+		defer powerSave.Unlock()
+		if powerSave.stop != nil {
+			close(powerSave.stop)
+			powerSave.stop = nil
+			powerSave.watcher = nil
+			powerSave.scheduler = nil
+		}
+		if powerSave.onBattery {
+			powerSave.onBattery = false
+			timing.SetIntervalPolicy(nil)
+		}
+		return
+	}
+	if powerSave.onBattery {
+		timing.SetIntervalPolicy(policy)
+	}
+	if powerSave.stop == nil {
+		powerSave.stop = make(chan struct{})
+		powerSave.watcher = dbus.WatchProperties(
+			busType, upowerService, upowerPath, upowerIface).Add("OnBattery")
+		powerSave.scheduler = timing.NewScheduler()
+		l.Attach(nil, powerSave.scheduler, "battery.powerSave")
+		go watchPower(powerSave.watcher, powerSave.scheduler, powerSave.stop)
+	}
+	powerSave.Unlock()
+	checkPower()
+}
+
+// watchPower checks for power changes on each UPower update or polling
+// tick, until stop is closed.
+func watchPower(w *dbus.PropertiesWatcher, sch *timing.Scheduler, stop <-chan struct{}) {
+	defer w.Unsubscribe()
+	defer sch.Close()
+	for {
+		select {
+		case <-w.Updates:
+		case <-sch.C:
+		case <-stop:
+			return
+		}
+		checkPower()
+	}
+}
+
+// checkPower updates the interval policy if the system switched between
+// battery and AC power. It uses UPower's OnBattery property if available,
+// and otherwise reads the batteries, polling until UPower becomes available.
+func checkPower() {
+	powerSave.Lock()
+	w := powerSave.watcher
+	powerSave.Unlock()
+	if w == nil {
+		return
+	}
+	onBattery, ok := w.Get()["OnBattery"].(bool)
+	if !ok {
+		onBattery = allBatteriesInfo().Discharging()
+	}
+	powerSave.Lock()
+	defer powerSave.Unlock()
+	if powerSave.watcher != w {
+		// Stopped or restarted while checking.
+		return
+	}
+	if ok {
+		powerSave.scheduler.Stop()
+	} else {
+		powerSave.scheduler.EveryAlign(powerCheckInterval, 0)
+	}
+	if onBattery == powerSave.onBattery {
+		return
+	}
+	powerSave.onBattery = onBattery
+	if onBattery {
+		l.Log("On battery, applying power saving interval policy")
+		timing.SetIntervalPolicy(powerSave.policy)
+	} else {
+		l.Log("On AC power, restoring refresh intervals")
+		timing.SetIntervalPolicy(nil)
+	}
+}
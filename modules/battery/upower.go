@@ -0,0 +1,173 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package battery
+
+import (
+	"math"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/value"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+)
+
+const (
+	upowerService       = "org.freedesktop.UPower"
+	upowerPath          = "/org/freedesktop/UPower"
+	upowerIface         = "org.freedesktop.UPower"
+	upowerDeviceIface   = "org.freedesktop.UPower.Device"
+	upowerDevicesPath   = "/org/freedesktop/UPower/devices/"
+	upowerDisplayDevice = "DisplayDevice"
+)
+
+var busType = dbus.System
+
+// UPower device states, from the UPower documentation.
+var upowerStates = map[uint32]Status{
+	1: Charging,
+	2: Discharging,
+	3: Discharging, // Empty
+	4: Full,
+	5: NotCharging, // Pending charge
+	6: Discharging, // Pending discharge
+}
+
+// UPower device types, from the UPower documentation.
+var upowerTypes = []string{
+	"Unknown", "Line Power", "Battery", "Ups", "Monitor", "Mouse",
+	"Keyboard", "Pda", "Phone", "Media Player", "Tablet", "Computer",
+	"Gaming Input", "Pen", "Touchpad", "Modem", "Network", "Headset",
+	"Speakers", "Headphones", "Video", "Other Audio", "Remote Control",
+	"Printer", "Scanner", "Camera", "Wearable", "Toy", "Bluetooth Generic",
+}
+
+// UPower battery technologies, named to match the sysfs values.
+var upowerTechnologies = map[uint32]string{
+	1: "Li-ion",
+	2: "Li-poly",
+	3: "LiFe",
+	4: "Lead acid",
+	5: "NiCd",
+	6: "NiMH",
+}
+
+// UPowerModule represents a battery bar module that uses UPower over D-Bus.
+// Unlike Module, it does not poll, and updates as soon as UPower reports any
+// change, e.g. when the AC adapter is plugged in.
+type UPowerModule struct {
+	path       string
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newUPowerModule(device string) *UPowerModule {
+	m := &UPowerModule{path: upowerDevicesPath + device}
+	l.Label(m, device)
+	l.Register(m, "outputFunc")
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("BATT %d%%", i.RemainingPct())
+	})
+	return m
+}
+
+// UPower constructs a battery module for UPower's display device, which
+// aggregates all batteries that power the system.
+func UPower() *UPowerModule {
+	return newUPowerModule(upowerDisplayDevice)
+}
+
+// UPowerDevice constructs a battery module for the UPower device with the
+// given name, e.g. "battery_BAT0", or a peripheral such as
+// "mouse_hidpp_battery_0". Device names are listed by `upower -e`.
+func UPowerDevice(name string) *UPowerModule {
+	return newUPowerModule(name)
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *UPowerModule) Output(outputFunc func(Info) bar.Output) *UPowerModule {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *UPowerModule) Stream(s bar.Sink) {
+	w := dbus.WatchProperties(busType, upowerService, m.path, upowerDeviceIface).
+		Add("Type", "Model", "IsPresent", "State", "Percentage",
+			"Energy", "EnergyFull", "EnergyFullDesign", "EnergyRate",
+			"Voltage", "Technology", "TimeToEmpty", "TimeToFull",
+			"ChargeCycles", "ChargeThresholdSupported",
+			"ChargeStartThreshold", "ChargeEndThreshold")
+	defer w.Unsubscribe()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	info := upowerInfo(w.Get())
+	for {
+		s.Output(outputFunc(info))
+		select {
+		case <-w.Updates:
+			info = upowerInfo(w.Get())
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func upowerInfo(props map[string]interface{}) Info {
+	if present, _ := props["IsPresent"].(bool); !present {
+		return Info{Status: Disconnected}
+	}
+	info := Info{}
+	state, _ := props["State"].(uint32)
+	info.Status = upowerStates[state]
+	if typ, ok := props["Type"].(uint32); ok && int(typ) < len(upowerTypes) {
+		info.Type = upowerTypes[typ]
+	}
+	tech, _ := props["Technology"].(uint32)
+	info.Technology = upowerTechnologies[tech]
+	info.Model, _ = props["Model"].(string)
+	pct, _ := props["Percentage"].(float64)
+	info.Capacity = int(pct)
+	info.EnergyNow, _ = props["Energy"].(float64)
+	info.EnergyFull, _ = props["EnergyFull"].(float64)
+	info.EnergyMax, _ = props["EnergyFullDesign"].(float64)
+	info.Power, _ = props["EnergyRate"].(float64)
+	info.Voltage, _ = props["Voltage"].(float64)
+	if math.Nextafter(info.EnergyFull, 0) == 0 {
+		// Some devices (e.g. peripherals) only report a percentage, so use
+		// a nominal 1Wh battery to keep Remaining consistent with it.
+		info.EnergyFull = 1
+		info.EnergyNow = pct / 100
+	}
+	if secs, ok := props["TimeToEmpty"].(int64); ok {
+		info.TimeToEmpty = time.Duration(secs) * time.Second
+	}
+	if secs, ok := props["TimeToFull"].(int64); ok {
+		info.TimeToFull = time.Duration(secs) * time.Second
+	}
+	// -1 if not supported by the battery.
+	if cycles, ok := props["ChargeCycles"].(int32); ok && cycles > 0 {
+		info.CycleCount = int(cycles)
+	}
+	if supported, _ := props["ChargeThresholdSupported"].(bool); supported {
+		start, _ := props["ChargeStartThreshold"].(uint32)
+		end, _ := props["ChargeEndThreshold"].(uint32)
+		info.ChargeStartThreshold, info.ChargeEndThreshold = int(start), int(end)
+	}
+	return info
+}
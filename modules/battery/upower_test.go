@@ -0,0 +1,132 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package battery
+
+import (
+	"testing"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+)
+
+func init() {
+	busType = dbus.Test
+}
+
+func setupUPowerDevice(name string) *dbus.TestBusObject {
+	bus := dbus.SetupTestBus()
+	upower := bus.RegisterService(upowerService)
+	return upower.Object(godbus.ObjectPath(upowerDevicesPath+name), upowerDeviceIface)
+}
+
+func TestUPower(t *testing.T) {
+	testBar.New(t)
+	dev := setupUPowerDevice("DisplayDevice")
+	dev.SetProperties(map[string]interface{}{
+		"Type":             uint32(2),
+		"IsPresent":        true,
+		"State":            uint32(2),
+		"Percentage":       float64(75),
+		"Energy":           float64(37.5),
+		"EnergyFull":       float64(50),
+		"EnergyFullDesign": float64(60),
+		"EnergyRate":       float64(10),
+		"Voltage":          float64(12.1),
+		"Technology":       uint32(1),
+		"TimeToEmpty":      int64(9000),
+		"TimeToFull":       int64(0),
+		"ChargeCycles":     int32(-1),
+
+		"ChargeThresholdSupported": true,
+		"ChargeStartThreshold":     uint32(75),
+		"ChargeEndThreshold":       uint32(80),
+	}, dbus.SignalTypeNone)
+
+	var info Info
+	m := UPower().Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("%s %d%% %v", i.Status, i.RemainingPct(), i.RemainingTime())
+	})
+	testBar.Run(m)
+
+	testBar.NextOutput("on start").AssertText([]string{"Discharging 75% 2h30m0s"})
+	require.Equal(t, "Battery", info.Type)
+	require.Equal(t, "Li-ion", info.Technology)
+	require.InDelta(t, 60.0, info.EnergyMax, 0.001)
+	require.InDelta(t, 10.0, info.Power, 0.001)
+	require.InDelta(t, 12.1, info.Voltage, 0.001)
+	require.Equal(t, 83, info.HealthPct())
+	require.Zero(t, info.CycleCount, "unknown cycle count")
+	require.Equal(t, 75, info.ChargeStartThreshold)
+	require.Equal(t, 80, info.ChargeEndThreshold)
+
+	dev.SetProperties(map[string]interface{}{
+		"State":       uint32(1),
+		"TimeToEmpty": int64(0),
+		"TimeToFull":  int64(1800),
+	}, dbus.SignalTypeChanged)
+	testBar.NextOutput("on plug").AssertText([]string{"Charging 75% 30m0s"})
+
+	dev.SetPropertyForTest("State", uint32(4), dbus.SignalTypeChanged)
+	testBar.LatestOutput().AssertText([]string{"Full 75% 0s"})
+
+	dev.SetPropertyForTest("IsPresent", false, dbus.SignalTypeChanged)
+	testBar.NextOutput("on removal").AssertText([]string{"Disconnected 0% 0s"})
+}
+
+func TestUPowerPeripheral(t *testing.T) {
+	testBar.New(t)
+	dev := setupUPowerDevice("mouse_hidpp_battery_0")
+	dev.SetProperties(map[string]interface{}{
+		"Type":       uint32(5),
+		"Model":      "MX Master 3",
+		"IsPresent":  true,
+		"State":      uint32(2),
+		"Percentage": float64(55),
+	}, dbus.SignalTypeNone)
+
+	m := UPowerDevice("mouse_hidpp_battery_0").Output(func(i Info) bar.Output {
+		return outputs.Textf("%s (%s): %d%%", i.Model, i.Type, i.RemainingPct())
+	})
+	testBar.Run(m)
+
+	testBar.NextOutput("on start").AssertText([]string{"MX Master 3 (Mouse): 55%"})
+
+	dev.SetPropertyForTest("Percentage", float64(50), dbus.SignalTypeChanged)
+	testBar.NextOutput("on change").AssertText([]string{"MX Master 3 (Mouse): 50%"})
+
+	dev.SetPropertyForTest("Model", "MX Master 3S", dbus.SignalTypeNone)
+	testBar.AssertNoOutput("without property change signal")
+
+	require.Zero(t, Info{Capacity: 50}.Remaining(),
+		"percentage only used for UPower devices")
+}
+
+func TestUPowerDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	dev := setupUPowerDevice("battery_BAT0")
+	dev.SetProperties(map[string]interface{}{
+		"IsPresent":  true,
+		"State":      uint32(1),
+		"Percentage": float64(42),
+	}, dbus.SignalTypeNone)
+	testBar.Run(UPowerDevice("battery_BAT0"))
+	testBar.NextOutput().AssertText([]string{"BATT 42%"})
+}
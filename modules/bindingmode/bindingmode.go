@@ -0,0 +1,129 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bindingmode provides an i3bar module that displays the current
+// i3/sway binding mode, as a replacement for i3bar's binding_mode_indicator.
+package bindingmode
+
+import (
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/value"
+	"github.com/leosunmo/barista/base/watchers/i3ipc"
+	"github.com/leosunmo/barista/colors"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+)
+
+// defaultMode is the name of the mode that's active without any mode
+// switches.
+const defaultMode = "default"
+
+// Mode represents an i3/sway binding mode.
+type Mode struct {
+	Name string
+	// PangoMarkup is true if the mode name should be rendered as pango
+	// markup, i.e. the mode was declared with --pango_markup.
+	PangoMarkup bool
+}
+
+// Default returns true for the default binding mode.
+func (m Mode) Default() bool {
+	return m.Name == defaultMode
+}
+
+// Reset switches back to the default binding mode.
+func (m Mode) Reset() {
+	if err := i3ipc.RunCommand("mode " + defaultMode); err != nil {
+		l.Log("Failed to reset binding mode: %v", err)
+	}
+}
+
+// Module represents a binding mode bar module.
+type Module struct {
+	outputFunc value.Value // of func(Mode) bar.Output
+}
+
+// New constructs a binding mode module. By default it displays the name of
+// the current mode, and nothing in the default mode.
+func New() *Module {
+	m := new(Module)
+	l.Register(m, "outputFunc")
+	m.Output(func(mode Mode) bar.Output {
+		if mode.Default() {
+			return nil
+		}
+		out := bar.TextSegment(mode.Name)
+		if mode.PangoMarkup {
+			out = bar.PangoSegment(mode.Name)
+		}
+		// Use the same colours as the i3bar binding mode indicator, which
+		// are set by colors.LoadBarConfig.
+		return out.
+			Color(colors.Scheme("binding_mode_text")).
+			Background(colors.Scheme("binding_mode_bg")).
+			Border(colors.Scheme("binding_mode_border"))
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Mode) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	sub := i3ipc.Subscribe(i3ipc.ModeEvents)
+	defer sub.Unsubscribe()
+	outputFunc := m.outputFunc.Get().(func(Mode) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	mode, err := currentMode()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputs.Group(outputFunc(mode)).OnClick(func(e bar.Event) {
+			if e.Button == bar.ButtonLeft {
+				mode.Reset()
+			}
+		}))
+		select {
+		case <-sub.C:
+			mode, err = modeFromEvent(sub.Get())
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Mode) bar.Output)
+		}
+	}
+}
+
+func currentMode() (Mode, error) {
+	name, err := i3ipc.BindingState()
+	return Mode{Name: name}, err
+}
+
+// modeFromEvent returns the mode from a mode event, or fetches the current
+// mode if the event has no payload (e.g. after reconnecting to i3).
+func modeFromEvent(e i3ipc.Event) (Mode, error) {
+	if len(e.Payload) == 0 {
+		return currentMode()
+	}
+	var ev i3ipc.ModeEvent
+	if err := e.Decode(&ev); err != nil {
+		return Mode{}, err
+	}
+	return Mode{Name: ev.Change, PangoMarkup: ev.PangoMarkup}, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bindingmode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/i3ipc"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindingMode(t *testing.T) {
+	srv := i3ipc.SetupTestServer()
+	srv.SetBindingState("resize")
+
+	testBar.New(t)
+	m := New()
+	testBar.Run(m)
+	out := testBar.NextOutput("initial mode")
+	out.AssertText([]string{"resize"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out.At(0).LeftClick()
+	select {
+	case cmd := <-srv.Commands():
+		require.Equal(t, "mode default", cmd, "resets on left click only")
+	case <-time.After(time.Second):
+		require.Fail(t, "no command on click")
+	}
+
+	srv.Emit(i3ipc.ModeEvents, i3ipc.ModeEvent{Change: "default"})
+	testBar.NextOutput("on mode event").AssertEmpty("in default mode")
+
+	srv.Emit(i3ipc.ModeEvents, i3ipc.ModeEvent{
+		Change: "<b>launch</b>", PangoMarkup: true})
+	out = testBar.NextOutput("on mode event")
+	text, isPango := out.At(0).Segment().Content()
+	require.Equal(t, "<b>launch</b>", text)
+	require.True(t, isPango, "pango markup")
+
+	m.Output(func(mode Mode) bar.Output {
+		return outputs.Textf("mode: %s", mode.Name)
+	})
+	testBar.NextOutput("on output func change").AssertText(
+		[]string{"mode: <b>launch</b>"})
+
+	srv.SetBindingState("passthrough")
+	srv.Restart()
+	testBar.NextOutput("on reconnection").AssertText(
+		[]string{"mode: passthrough"})
+}
@@ -0,0 +1,307 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cpufreq provides an i3bar module that shows CPU frequencies and
+// the scaling governor, and can switch governors using a helper command.
+package cpufreq
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/helper"
+	"github.com/leosunmo/barista/base/sampler"
+	"github.com/leosunmo/barista/base/value"
+	"github.com/leosunmo/barista/format"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+)
+
+const cpuDir = "/sys/devices/system/cpu"
+
+// Core represents the frequency scaling state of a single CPU core.
+type Core struct {
+	CPU                int
+	Current, Min, Max  unit.Frequency
+	Governor           string
+	AvailableGovernors []string
+	// EPP is the energy performance preference, e.g. "balance_power", and is
+	// empty if not supported by the scaling driver.
+	EPP          string
+	AvailableEPP []string
+}
+
+// Info represents the frequency scaling state of all CPU cores.
+type Info struct {
+	Cores []Core
+}
+
+// Average returns the average current frequency across all cores.
+func (i Info) Average() unit.Frequency {
+	if len(i.Cores) == 0 {
+		return 0
+	}
+	var total unit.Frequency
+	for _, c := range i.Cores {
+		total += c.Current
+	}
+	return total / unit.Frequency(len(i.Cores))
+}
+
+// Max returns the highest current frequency of any core.
+func (i Info) Max() unit.Frequency {
+	var max unit.Frequency
+	for _, c := range i.Cores {
+		if c.Current > max {
+			max = c.Current
+		}
+	}
+	return max
+}
+
+// Governor returns the scaling governor of the first core, which is usually
+// the same for all cores.
+func (i Info) Governor() string {
+	if len(i.Cores) == 0 {
+		return ""
+	}
+	return i.Cores[0].Governor
+}
+
+// EPP returns the energy performance preference of the first core.
+func (i Info) EPP() string {
+	if len(i.Cores) == 0 {
+		return ""
+	}
+	return i.Cores[0].EPP
+}
+
+// next returns the item after current in items, wrapping around.
+func next(items []string, current string) string {
+	for idx, item := range items {
+		if item == current {
+			return items[(idx+1)%len(items)]
+		}
+	}
+	if len(items) > 0 {
+		return items[0]
+	}
+	return current
+}
+
+// Module represents a cpufreq bar module.
+type Module struct {
+	sampler    *sampler.Sampler
+	outputFunc value.Value // of func(Info) bar.Output
+	helper     value.Value // of helper.Command
+}
+
+// New constructs an instance of the cpufreq module.
+func New() *Module {
+	m := &Module{sampler: sampler.New(cpuDir+"#cpufreq", read)}
+	l.Register(m, "sampler", "outputFunc", "helper")
+	m.helper.Set(helper.Command(nil))
+	m.RefreshInterval(3 * time.Second)
+	// Default output is the average frequency and governor.
+	m.Output(func(i Info) bar.Output {
+		freq, _ := format.Unit(i.Average())
+		return outputs.Textf("%s %s", freq, i.Governor())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for cpufreq.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.sampler.Every(interval)
+	return m
+}
+
+// Helper configures the command used to change settings, which usually
+// requires root. The command is run with the setting ("governor" or "epp")
+// and the new value appended to the given arguments, e.g.
+// Helper("pkexec", "/usr/local/bin/cpufreq-helper") runs
+// `pkexec /usr/local/bin/cpufreq-helper governor performance`.
+func (m *Module) Helper(cmd string, args ...string) *Module {
+	m.helper.Set(helper.New(cmd, args...))
+	return m
+}
+
+// SetGovernor sets the scaling governor of all cores using the helper.
+func (m *Module) SetGovernor(governor string) error {
+	return m.runHelper("governor", governor)
+}
+
+// SetEPP sets the energy performance preference of all cores using the helper.
+func (m *Module) SetEPP(epp string) error {
+	return m.runHelper("epp", epp)
+}
+
+// CycleGovernor switches to the next available scaling governor.
+func (m *Module) CycleGovernor() error {
+	i, err := m.info()
+	if err != nil {
+		return err
+	}
+	if len(i.Cores) == 0 {
+		return errors.New("no cpufreq information")
+	}
+	return m.SetGovernor(next(i.Cores[0].AvailableGovernors, i.Governor()))
+}
+
+// CycleEPP switches to the next available energy performance preference.
+func (m *Module) CycleEPP() error {
+	i, err := m.info()
+	if err != nil {
+		return err
+	}
+	if len(i.Cores) == 0 || len(i.Cores[0].AvailableEPP) == 0 {
+		return errors.New("energy performance preference not supported")
+	}
+	return m.SetEPP(next(i.Cores[0].AvailableEPP, i.EPP()))
+}
+
+func (m *Module) info() (Info, error) {
+	info, err := m.sampler.Get()
+	if err != nil {
+		return Info{}, err
+	}
+	return info.(Info), nil
+}
+
+func (m *Module) runHelper(setting, val string) error {
+	cmd := m.helper.Get().(helper.Command)
+	if err := cmd.Run(setting, val); err != nil {
+		return err
+	}
+	m.sampler.Refresh()
+	return nil
+}
+
+// defaultClickHandler cycles the governor on left click, and the energy
+// performance preference on right click.
+func (m *Module) defaultClickHandler(e bar.Event) {
+	var err error
+	switch e.Button {
+	case bar.ButtonLeft:
+		err = m.CycleGovernor()
+	case bar.ButtonRight:
+		err = m.CycleEPP()
+	}
+	if err != nil {
+		l.Log("Error updating cpufreq: %v", err)
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.sampler.Get()
+	nextInfo, done := m.sampler.Subscribe()
+	defer done()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputs.Group(outputFunc(info.(Info))).OnClick(m.defaultClickHandler))
+		select {
+		case <-nextInfo:
+			info, err = m.sampler.Get()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+var fs = afero.NewOsFs()
+
+func read() (interface{}, error) {
+	files, err := afero.ReadDir(fs, cpuDir)
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	for _, f := range files {
+		cpu, err := strconv.Atoi(strings.TrimPrefix(f.Name(), "cpu"))
+		if err != nil || !strings.HasPrefix(f.Name(), "cpu") {
+			continue
+		}
+		core, err := readCore(cpu, path.Join(cpuDir, f.Name(), "cpufreq"))
+		if os.IsNotExist(err) {
+			// Offline, or without frequency scaling.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		info.Cores = append(info.Cores, core)
+	}
+	if len(info.Cores) == 0 {
+		return nil, errors.New("cpufreq not available")
+	}
+	sort.Slice(info.Cores, func(a, b int) bool {
+		return info.Cores[a].CPU < info.Cores[b].CPU
+	})
+	return info, nil
+}
+
+func readCore(cpu int, dir string) (c Core, err error) {
+	c.CPU = cpu
+	for name, freq := range map[string]*unit.Frequency{
+		"scaling_cur_freq": &c.Current,
+		"scaling_min_freq": &c.Min,
+		"scaling_max_freq": &c.Max,
+	} {
+		str, err := readString(path.Join(dir, name))
+		if err != nil {
+			return c, err
+		}
+		khz, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return c, err
+		}
+		*freq = unit.Frequency(khz) * unit.Kilohertz
+	}
+	if c.Governor, err = readString(path.Join(dir, "scaling_governor")); err != nil {
+		return c, err
+	}
+	// Optional attributes, depending on the scaling driver.
+	governors, _ := readString(path.Join(dir, "scaling_available_governors"))
+	c.AvailableGovernors = strings.Fields(governors)
+	c.EPP, _ = readString(path.Join(dir, "energy_performance_preference"))
+	epps, _ := readString(path.Join(dir, "energy_performance_available_preferences"))
+	c.AvailableEPP = strings.Fields(epps)
+	return c, nil
+}
+
+func readString(file string) (string, error) {
+	bytes, err := afero.ReadFile(fs, file)
+	return strings.TrimSpace(string(bytes)), err
+}
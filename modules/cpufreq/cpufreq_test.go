@@ -0,0 +1,199 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpufreq
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/helper"
+	"github.com/leosunmo/barista/format"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAttr(t *testing.T, cpu int, name, value string) {
+	t.Helper()
+	file := path.Join(cpuDir, fmt.Sprintf("cpu%d", cpu), "cpufreq", name)
+	require.NoError(t, afero.WriteFile(fs, file, []byte(value+"\n"), 0644))
+}
+
+func setupCore(t *testing.T, cpu int, curKHz int, governor, epp string) {
+	t.Helper()
+	writeAttr(t, cpu, "scaling_cur_freq", fmt.Sprintf("%d", curKHz))
+	writeAttr(t, cpu, "scaling_min_freq", "400000")
+	writeAttr(t, cpu, "scaling_max_freq", "4800000")
+	writeAttr(t, cpu, "scaling_governor", governor)
+	writeAttr(t, cpu, "scaling_available_governors", "performance powersave")
+	if epp != "" {
+		writeAttr(t, cpu, "energy_performance_preference", epp)
+		writeAttr(t, cpu, "energy_performance_available_preferences",
+			"default performance balance_performance balance_power power")
+	}
+}
+
+type call struct {
+	cmd  string
+	args []string
+}
+
+func mockHelper(t *testing.T) <-chan call {
+	calls := make(chan call, 10)
+	helper.SetRunnerForTest(func(name string, args ...string) error {
+		calls <- call{name, args}
+		if args[len(args)-1] == "invalid" {
+			return errors.New("helper failed")
+		}
+		if args[len(args)-2] == "governor" {
+			for cpu := 0; cpu < 2; cpu++ {
+				writeAttr(t, cpu, "scaling_governor", args[len(args)-1])
+			}
+		}
+		return nil
+	})
+	t.Cleanup(func() { helper.SetRunnerForTest(nil) })
+	return calls
+}
+
+func describe(i Info) bar.Output {
+	var freqs []string
+	for _, c := range i.Cores {
+		f, _ := format.Unit(c.Current)
+		freqs = append(freqs, fmt.Sprintf("%d:%s", c.CPU, f))
+	}
+	return outputs.Textf("%s %s/%s", strings.Join(freqs, " "), i.Governor(), i.EPP())
+}
+
+func TestCPUFreq(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	setupCore(t, 0, 2400000, "powersave", "balance_power")
+	setupCore(t, 1, 800000, "powersave", "balance_power")
+	setupCore(t, 10, 1000000, "powersave", "balance_power")
+	// Offline core.
+	require.NoError(t, fs.MkdirAll(path.Join(cpuDir, "cpu2"), 0755))
+	require.NoError(t, fs.MkdirAll(path.Join(cpuDir, "cpufreq"), 0755))
+	testBar.New(t)
+
+	def := New()
+	cores := New().Output(describe)
+	testBar.Run(def, cores)
+	testBar.LatestOutput().AssertText([]string{
+		"1.40GHz powersave",
+		"0:2.40GHz 1: 800MHz 10:1.00GHz powersave/balance_power",
+	}, "on start")
+
+	writeAttr(t, 1, "scaling_cur_freq", "4800000")
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{
+		"2.73GHz powersave",
+		"0:2.40GHz 1:4.80GHz 10:1.00GHz powersave/balance_power",
+	}, "on tick")
+
+	var info Info
+	def.Output(func(i Info) bar.Output {
+		info = i
+		f, _ := format.Unit(i.Max())
+		return outputs.Text(f.String())
+	})
+	testBar.LatestOutput(0).AssertText([]string{
+		"4.80GHz",
+		"0:2.40GHz 1:4.80GHz 10:1.00GHz powersave/balance_power",
+	}, "on output change")
+	require.Equal(t, Core{
+		CPU:                1,
+		Current:            info.Cores[1].Current,
+		Min:                info.Cores[1].Min,
+		Max:                info.Cores[1].Max,
+		Governor:           "powersave",
+		AvailableGovernors: []string{"performance", "powersave"},
+		EPP:                "balance_power",
+		AvailableEPP: []string{
+			"default", "performance", "balance_performance", "balance_power", "power"},
+	}, info.Cores[1])
+	require.InDelta(t, 0.4, info.Cores[1].Min.Gigahertz(), 1e-9)
+	require.InDelta(t, 4.8, info.Cores[1].Max.Gigahertz(), 1e-9)
+}
+
+func TestHelper(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	setupCore(t, 0, 2400000, "powersave", "balance_power")
+	setupCore(t, 1, 800000, "powersave", "balance_power")
+	calls := mockHelper(t)
+	testBar.New(t)
+
+	m := New()
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"1.60GHz powersave"})
+
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("without helper")
+	require.Empty(t, calls, "without helper")
+
+	m.Helper("pkexec", "/usr/bin/cpufreq-helper")
+	testBar.AssertNoOutput("on helper change")
+
+	out.At(0).LeftClick()
+	require.Equal(t, call{"pkexec", []string{"/usr/bin/cpufreq-helper", "governor", "performance"}}, <-calls)
+	out = testBar.NextOutput("on governor change")
+	out.AssertText([]string{"1.60GHz performance"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	require.Equal(t, call{"pkexec", []string{"/usr/bin/cpufreq-helper", "epp", "power"}}, <-calls)
+	testBar.NextOutput("on refresh after epp change")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Text("x").OnClick(func(bar.Event) {
+			require.Error(t, m.SetGovernor("invalid"))
+			require.NoError(t, m.CycleGovernor())
+		})
+	})
+	out = testBar.NextOutput("on output change")
+	out.At(0).LeftClick()
+	require.Equal(t, "invalid", (<-calls).args[2])
+	require.Equal(t, "powersave", (<-calls).args[2], "cycle wraps around")
+	testBar.NextOutput("on governor change")
+}
+
+func TestErrors(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	require.NoError(t, fs.MkdirAll(path.Join(cpuDir, "cpu0"), 0755))
+	m := New()
+	testBar.Run(m)
+	testBar.LatestOutput().AssertError("without cpufreq")
+	require.Error(t, m.CycleGovernor())
+	require.Error(t, m.CycleEPP())
+
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	writeAttr(t, 0, "scaling_cur_freq", "fast")
+	testBar.Run(New())
+	testBar.LatestOutput().AssertError("with non-numeric frequency")
+
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	setupCore(t, 0, 2400000, "powersave", "")
+	m = New()
+	require.Error(t, m.CycleEPP(), "without epp")
+	require.Error(t, m.SetGovernor("performance"), "without helper")
+}
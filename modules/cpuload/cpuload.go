@@ -23,10 +23,10 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
 	"github.com/leosunmo/barista/base/value"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/outputs"
-	"github.com/leosunmo/barista/timing"
 )
 
 // LoadAvg represents the CPU load average for the past 1, 5, and 15 minutes.
@@ -50,14 +50,14 @@ func (l LoadAvg) Min15() float64 {
 // Module represents a cpuload bar module. It supports setting the output
 // format, click handler, update frequency, and urgency/colour functions.
 type Module struct {
-	scheduler  *timing.Scheduler
+	sampler    *sampler.Sampler
 	outputFunc value.Value // of func(LoadAvg) bar.Output
 }
 
 // New constructs an instance of the cpuload module.
 func New() *Module {
-	m := &Module{scheduler: timing.NewScheduler()}
-	l.Register(m, "scheduler", "format")
+	m := &Module{sampler: sampler.New("getloadavg", readLoadAvg)}
+	l.Register(m, "sampler", "format")
 	m.RefreshInterval(3 * time.Second)
 	// Construct a simple output that's just 2 decimals of the 1-minute load average.
 	m.Output(func(l LoadAvg) bar.Output {
@@ -74,14 +74,16 @@ func (m *Module) Output(outputFunc func(LoadAvg) bar.Output) *Module {
 
 // RefreshInterval configures the polling frequency for getloadavg.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
-	m.scheduler.Every(interval)
+	m.sampler.Every(interval)
 	return m
 }
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	var loads LoadAvg
-	count, err := getloadavg(&loads, 3)
+	m.sampler.Refresh()
+	loads, err := m.sampler.Get()
+	nextLoads, done := m.sampler.Subscribe()
+	defer done()
 	outputFunc := m.outputFunc.Get().(func(LoadAvg) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
@@ -89,20 +91,28 @@ func (m *Module) Stream(s bar.Sink) {
 		if s.Error(err) {
 			return
 		}
-		if count != 3 {
-			s.Error(fmt.Errorf("getloadavg: %d", count))
-			return
-		}
-		s.Output(outputFunc(loads))
+		s.Output(outputFunc(loads.(LoadAvg)))
 		select {
-		case <-m.scheduler.C:
-			count, err = getloadavg(&loads, 3)
+		case <-nextLoads:
+			loads, err = m.sampler.Get()
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(LoadAvg) bar.Output)
 		}
 	}
 }
 
+func readLoadAvg() (interface{}, error) {
+	var loads LoadAvg
+	count, err := getloadavg(&loads, 3)
+	if err != nil {
+		return nil, err
+	}
+	if count != 3 {
+		return nil, fmt.Errorf("getloadavg: %d", count)
+	}
+	return loads, nil
+}
+
 // To allow tests to mock out getloadavg.
 var getloadavg = func(out *LoadAvg, count int) (int, error) {
 	read, err := C.getloadavg((*C.double)(&out[0]), (C.int)(count))
@@ -104,6 +104,11 @@ func TestCpuload(t *testing.T) {
 	testBar.NextOutput().Expect("on next tick")
 	require.Equal(time.Minute, afterTick.Sub(beforeTick))
 
+	afterTick = timing.NextTick()
+	testBar.NextOutput().Expect("on aligned tick")
+	require.Equal(afterTick.Truncate(time.Minute), afterTick,
+		"later updates aligned to refresh interval")
+
 	testBar.AssertNoOutput("until next tick")
 }
 
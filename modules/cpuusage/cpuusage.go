@@ -0,0 +1,257 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cpuusage provides an i3bar module that shows CPU utilisation,
+// computed from the change in /proc/stat between samples.
+package cpuusage
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
+	"github.com/leosunmo/barista/base/value"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+
+	"github.com/spf13/afero"
+)
+
+// Usage represents the utilisation of a CPU (or all CPUs) since the previous
+// sample. Each field is a fraction of the total time, from 0 to 1.
+type Usage struct {
+	User    float64
+	Nice    float64
+	System  float64
+	Idle    float64
+	IOWait  float64
+	IRQ     float64
+	SoftIRQ float64
+	// Steal is the time taken by the hypervisor for other virtual machines.
+	Steal float64
+}
+
+// Busy returns the fraction of time the CPU was not idle or waiting for IO.
+func (u Usage) Busy() float64 {
+	busy := 1 - u.Idle - u.IOWait
+	if busy < 0 {
+		return 0
+	}
+	return busy
+}
+
+// BusyPct returns the percentage of time the CPU was busy.
+func (u Usage) BusyPct() int {
+	return int(u.Busy()*100 + 0.5)
+}
+
+// Info represents CPU utilisation since the previous sample.
+type Info struct {
+	// Total is the utilisation across all CPUs.
+	Total Usage
+	// Cores is the utilisation of each CPU, in order of CPU number.
+	Cores []Usage
+	// History is the busy fraction of Total for recent samples, oldest first
+	// and ending with the current sample, e.g. for drawing a sparkline.
+	History []float64
+}
+
+// times are the cumulative times from a line of /proc/stat, in order:
+// user, nice, system, idle, iowait, irq, softirq, steal.
+type times [8]uint64
+
+func (t times) usage(prev times) Usage {
+	var delta [8]float64
+	total := 0.0
+	for i := range t {
+		if t[i] > prev[i] {
+			delta[i] = float64(t[i] - prev[i])
+		}
+		total += delta[i]
+	}
+	if total == 0 {
+		return Usage{Idle: 1}
+	}
+	return Usage{
+		User:    delta[0] / total,
+		Nice:    delta[1] / total,
+		System:  delta[2] / total,
+		Idle:    delta[3] / total,
+		IOWait:  delta[4] / total,
+		IRQ:     delta[5] / total,
+		SoftIRQ: delta[6] / total,
+		Steal:   delta[7] / total,
+	}
+}
+
+var (
+	once    sync.Once
+	updater *sampler.Sampler
+
+	// State carried between samples, protected by stateMu.
+	stateMu     sync.Mutex
+	prevTotal   times
+	prevCores   []times
+	history     []float64
+	historySize = 60
+)
+
+// construct initialises cpuusage's global updating. All cpuusage modules are
+// updated with just one read of /proc/stat, so that they all see the same
+// deltas.
+func construct() {
+	once.Do(func() {
+		updater = sampler.New("/proc/stat#cpuusage", read)
+		l.Attach(nil, updater, "cpuusage.updater")
+		updater.Every(3 * time.Second)
+	})
+}
+
+// RefreshInterval configures the polling frequency. Usage is always
+// computed over the time between samples.
+func RefreshInterval(interval time.Duration) {
+	construct()
+	updater.Every(interval)
+}
+
+// HistorySize configures the number of samples kept in Info.History.
+func HistorySize(size int) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	historySize = size
+	if len(history) > size {
+		history = append([]float64(nil), history[len(history)-size:]...)
+	}
+}
+
+// Module represents a bar.Module that displays CPU utilisation.
+type Module struct {
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a new cpuusage module.
+func New() *Module {
+	construct()
+	m := new(Module)
+	l.Register(m, "outputFunc")
+	// Default output is the total utilisation.
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("CPU: %d%%", i.Total.BusyPct())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream subscribes to /proc/stat and updates the module's output accordingly.
+func (m *Module) Stream(s bar.Sink) {
+	i, err := updater.Get()
+	nextInfo, done := updater.Subscribe()
+	defer done()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if err != nil {
+			s.Error(err)
+		} else if info, ok := i.(Info); ok {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextInfo:
+			i, err = updater.Get()
+		}
+	}
+}
+
+var fs = afero.NewOsFs()
+
+func read() (interface{}, error) {
+	total, cores, err := readStat()
+	if err != nil {
+		return nil, err
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	info := Info{Total: total.usage(prevTotal)}
+	for i, core := range cores {
+		var prev times
+		if i < len(prevCores) {
+			prev = prevCores[i]
+		}
+		info.Cores = append(info.Cores, core.usage(prev))
+	}
+	prevTotal, prevCores = total, cores
+	history = append(history, info.Total.Busy())
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+	info.History = append([]float64(nil), history...)
+	return info, nil
+}
+
+// readStat reads the cumulative times for all CPUs, and for each core.
+func readStat() (total times, cores []times, err error) {
+	f, err := fs.Open("/proc/stat")
+	if err != nil {
+		return total, nil, err
+	}
+	defer f.Close()
+	found := false
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		var t times
+		for i := range t {
+			if i+1 < len(fields) {
+				t[i], _ = strconv.ParseUint(fields[i+1], 10, 64)
+			}
+		}
+		if fields[0] == "cpu" {
+			total, found = t, true
+			continue
+		}
+		// Cores can be offline, which removes their line, so use the CPU
+		// number to keep positions stable.
+		idx, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+		if err != nil {
+			continue
+		}
+		for len(cores) <= idx {
+			cores = append(cores, times{})
+		}
+		cores[idx] = t
+	}
+	if err := s.Err(); err != nil {
+		return total, nil, err
+	}
+	if !found {
+		return total, nil, errors.New("no cpu line in /proc/stat")
+	}
+	return total, cores, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuusage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+	"github.com/leosunmo/barista/timing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func shouldReturn(t *testing.T, stat string) {
+	t.Helper()
+	err := afero.WriteFile(fs, "/proc/stat", []byte(stat), 0644)
+	require.NoError(t, err, "afero.WriteFile failed")
+}
+
+func resetForTest() {
+	stateMu.Lock()
+	prevTotal, prevCores, history, historySize = times{}, nil, nil, 60
+	stateMu.Unlock()
+	once = sync.Once{}
+	construct()
+}
+
+func coresText(i Info) bar.Output {
+	var pcts []string
+	for _, c := range i.Cores {
+		pcts = append(pcts, fmt.Sprintf("%d", c.BusyPct()))
+	}
+	return outputs.Text(strings.Join(pcts, "/"))
+}
+
+func TestCPUUsage(t *testing.T) {
+	require := require.New(t)
+	fs = afero.NewMemMapFs()
+	// user nice system idle iowait irq softirq steal guest guest_nice
+	shouldReturn(t, `cpu  100 0 100 700 100 0 0 0 0 0
+cpu0 50 0 50 350 50 0 0 0 0 0
+cpu1 50 0 50 350 50 0 0 0 0 0
+intr 12345 0 0
+ctxt 67890
+`)
+	testBar.New(t)
+	resetForTest()
+
+	def := New()
+	cores := New().Output(coresText)
+	testBar.Run(def, cores)
+	testBar.LatestOutput().AssertText(
+		[]string{"CPU: 20%", "20/20"}, "on start, since boot")
+
+	shouldReturn(t, `cpu  200 0 150 750 100 0 0 100 0 0
+cpu0 150 0 50 350 50 0 0 0 0 0
+cpu1 50 0 100 400 50 0 0 100 0 0
+`)
+	testBar.Tick()
+	testBar.LatestOutput().AssertText(
+		[]string{"CPU: 83%", "100/75"}, "on tick")
+
+	var info Info
+	def.Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("steal %.2f", i.Total.Steal)
+	})
+	testBar.LatestOutput(0).AssertText([]string{"steal 0.33", "100/75"})
+	require.InDelta(1.0/3, info.Total.User, 1e-9)
+	require.InDelta(1.0/6, info.Total.System, 1e-9)
+	require.InDelta(0.25, info.Cores[1].System, 1e-9)
+	require.InDelta(0.5, info.Cores[1].Steal, 1e-9)
+	require.InDeltaSlice([]float64{0.2, 5.0 / 6}, info.History, 1e-9)
+
+	// No change in counters.
+	testBar.Tick()
+	testBar.LatestOutput(0, 1).AssertText([]string{"steal 0.00", "0/0"})
+	require.Equal(1.0, info.Total.Idle)
+	require.Len(info.History, 3)
+
+	HistorySize(2)
+	testBar.Tick()
+	testBar.LatestOutput().Expect("on tick")
+	require.InDeltaSlice([]float64{0, 0}, info.History, 1e-9)
+
+	beforeTick := timing.Now()
+	RefreshInterval(time.Minute)
+	testBar.Tick()
+	require.Equal(time.Minute, timing.Now().Sub(beforeTick), "RefreshInterval change")
+	testBar.LatestOutput().Expect("on tick after refresh interval change")
+
+	testBar.Tick()
+	now := timing.Now()
+	require.Equal(now.Truncate(time.Minute), now, "later ticks aligned to interval")
+	testBar.LatestOutput().Expect("on aligned tick")
+}
+
+func TestOfflineCores(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	shouldReturn(t, `cpu  100 0 0 100 0 0 0 0
+cpu0 50 0 0 50 0 0 0 0
+cpu2 50 0 0 50 0 0 0 0
+`)
+	testBar.New(t)
+	resetForTest()
+
+	m := New().Output(coresText)
+	testBar.Run(m)
+	testBar.LatestOutput().AssertText([]string{"50/0/50"},
+		"offline cores keep positions")
+}
+
+func TestErrors(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	resetForTest()
+
+	m := New()
+	testBar.Run(m)
+	testBar.LatestOutput().AssertError("on start if missing /proc/stat")
+
+	shouldReturn(t, "intr 12345 0 0\n")
+	testBar.Tick()
+	testBar.LatestOutput().AssertError("without cpu line")
+
+	shouldReturn(t, "cpu  10 0 10 80 0 0 0 0\n")
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{"CPU: 20%"},
+		"when /proc/stat is back to normal")
+}
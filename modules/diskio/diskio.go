@@ -12,17 +12,20 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package diskio implements an i3bar module to show disk IO rates.
+// Package diskio implements i3bar modules to show disk IO rates, request
+// rates, and latency, of a single disk or all disks.
 package diskio
 
 import (
 	"bufio"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
 	"github.com/leosunmo/barista/base/value"
 	"github.com/leosunmo/barista/format"
 	l "github.com/leosunmo/barista/logging"
@@ -36,6 +39,17 @@ import (
 // IO represents input and output rates for a disk.
 type IO struct {
 	Input, Output unit.Datarate
+	// Completed read and write requests per second.
+	ReadIOPS, WriteIOPS float64
+	// Average time for read and write requests to be served, including the
+	// time spent waiting in the queue.
+	ReadAwait, WriteAwait time.Duration
+	// Utilization is the fraction of time the disk was busy, from 0 to 1.
+	Utilization float64
+	// QueueDepth is the average number of requests in flight.
+	QueueDepth float64
+	// InFlight is the number of requests in flight when last sampled.
+	InFlight int
 	// Unexported fields used by module to control output.
 	shouldOutput bool
 	err          error
@@ -46,11 +60,37 @@ func (i IO) Total() unit.Datarate {
 	return i.Input + i.Output
 }
 
+// IOPS gets the total number of requests per second (reads + writes).
+func (i IO) IOPS() float64 {
+	return i.ReadIOPS + i.WriteIOPS
+}
+
+// Await gets the average time for any request to be served.
+func (i IO) Await() time.Duration {
+	if i.IOPS() == 0 {
+		return 0
+	}
+	return time.Duration((float64(i.ReadAwait)*i.ReadIOPS +
+		float64(i.WriteAwait)*i.WriteIOPS) / i.IOPS())
+}
+
+// UtilizationPct gets the utilization as a percentage.
+func (i IO) UtilizationPct() int {
+	return int(i.Utilization*100 + 0.5)
+}
+
+// counters are the cumulative values from one line of /proc/diskstats.
+// Times are in milliseconds.
+type counters struct {
+	reads, readSectors, readTicks    uint64
+	writes, writeSectors, writeTicks uint64
+	inFlight, ioTicks, queueTicks    uint64
+}
+
 type diskInfo struct {
 	ioChan     chan<- IO
 	lastIO     *IO
-	lastRead   uint64
-	lastWrite  uint64
+	last       counters
 	updateTime time.Time
 }
 
@@ -58,29 +98,34 @@ var once sync.Once
 
 var lock sync.Mutex
 var modules map[string]*diskInfo
-var updater *timing.Scheduler
+var updater *sampler.Sampler
+
+// Whether any All modules were constructed, and the latest IO of all disks.
+var watchAll bool
+var allDisks = new(value.ErrorValue) // of []Disk
 
 // construct initialises diskio's global updating. All diskio
 // modules are updated with just one read of /proc/diskstats.
 func construct() {
 	once.Do(func() {
 		modules = make(map[string]*diskInfo)
-		updater = timing.NewScheduler()
+		updater = sampler.New("/proc/diskstats", readDiskstats)
 		l.Attach(nil, updater, "diskio.updater")
 		updater.Every(3 * time.Second)
-		update()
-		go func(updater *timing.Scheduler) {
-			for range updater.C {
-				update()
+		update(updater.Get())
+		sub, _ := updater.Subscribe()
+		go func(updater *sampler.Sampler, sub <-chan struct{}) {
+			for range sub {
+				update(updater.Get())
 			}
-		}(updater)
+		}(updater, sub)
 	})
 }
 
 // RefreshInterval configures the polling frequency.
 func RefreshInterval(interval time.Duration) {
 	construct()
-	// Scheduler is goroutine safe, don't need to lock here.
+	// Sampler is goroutine safe, don't need to lock here.
 	updater.Every(interval)
 }
 
@@ -139,20 +184,117 @@ func (m *Module) Stream(s bar.Sink) {
 	}
 }
 
-// update updates the last read information, and returns
-// the delta read and written since the last update in bytes/sec.
-func (m *diskInfo) update(read, write uint64) (readRate, writeRate int) {
+// Disk represents the IO activity of a single disk.
+type Disk struct {
+	// Name of the disk, e.g. "sda" or "nvme0n1".
+	Name string
+	IO
+}
+
+// AllModule represents a bar.Module for the io activity of all disks.
+type AllModule struct {
+	disks      *value.ErrorValue // of []Disk
+	outputFunc value.Value       // of func([]Disk) bar.Output
+}
+
+// All creates a diskio module that displays io rates for all disks that are
+// backed by a device, i.e. excluding partitions, loop devices, and other
+// virtual devices. Disks are added and removed as they are plugged in.
+func All() *AllModule {
+	construct()
+	lock.Lock()
+	watchAll = true
+	m := &AllModule{disks: allDisks}
+	lock.Unlock()
+	l.Register(m, "disks", "outputFunc")
+	m.Output(func(disks []Disk) bar.Output {
+		var total unit.Datarate
+		for _, d := range disks {
+			total += d.Total()
+		}
+		return outputs.Textf("Disk: %s", format.IByterate(total))
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *AllModule) Output(outputFunc func([]Disk) bar.Output) *AllModule {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *AllModule) Stream(s bar.Sink) {
+	disks, err := m.disks.Get()
+	nextDisks, done := m.disks.Subscribe()
+	defer done()
+	outputFunc := m.outputFunc.Get().(func([]Disk) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if s.Error(err) {
+			return
+		}
+		if disks == nil {
+			// No rates until the next update.
+			s.Output(nil)
+		} else {
+			s.Output(outputFunc(disks.([]Disk)))
+		}
+		select {
+		case <-nextDisks:
+			disks, err = m.disks.Get()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func([]Disk) bar.Output)
+		}
+	}
+}
+
+// update updates the last read information, and returns the rates
+// since the last update.
+func (m *diskInfo) update(c counters) IO {
 	duration := timing.Now().Sub(m.updateTime).Seconds()
-	if read != m.lastRead {
-		readRate = int(float64(read-m.lastRead) / duration)
+	last := m.last
+	m.last = c
+	m.updateTime = timing.Now()
+	rate := func(cur, prev uint64) float64 {
+		return float64(delta(cur, prev)) / duration
 	}
-	if write != m.lastWrite {
-		writeRate = int(float64(write-m.lastWrite) / duration)
+	// Linux always considers sectors to be 512 bytes long
+	// independently of the devices real block size.
+	// (from linux/types.h)
+	readRate := int(rate(c.readSectors, last.readSectors))
+	writeRate := int(rate(c.writeSectors, last.writeSectors))
+	return IO{
+		Input:      unit.Datarate(readRate) * 512 * unit.BytePerSecond,
+		Output:     unit.Datarate(writeRate) * 512 * unit.BytePerSecond,
+		ReadIOPS:   rate(c.reads, last.reads),
+		WriteIOPS:  rate(c.writes, last.writes),
+		ReadAwait:  await(delta(c.readTicks, last.readTicks), delta(c.reads, last.reads)),
+		WriteAwait: await(delta(c.writeTicks, last.writeTicks), delta(c.writes, last.writes)),
+		// Ticks are in milliseconds, and can be slightly more than the
+		// elapsed time, since they're not sampled at exactly the same time.
+		Utilization: math.Min(1, rate(c.ioTicks, last.ioTicks)/1000),
+		QueueDepth:  rate(c.queueTicks, last.queueTicks) / 1000,
+		InFlight:    int(c.inFlight),
 	}
-	m.lastRead = read
-	m.lastWrite = write
-	m.updateTime = timing.Now()
-	return // readRate, writeRate
+}
+
+// delta returns the change in a counter, or 0 if the counter was reset.
+func delta(cur, prev uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+// await returns the average time per request, given the total time spent
+// in milliseconds.
+func await(ticks, requests uint64) time.Duration {
+	if requests == 0 || ticks > math.MaxInt64/uint64(time.Millisecond) {
+		return 0
+	}
+	return time.Duration(ticks) * time.Millisecond / time.Duration(requests)
 }
 
 func (m *diskInfo) Error(err error) bool {
@@ -182,25 +324,40 @@ func (m *diskInfo) makeChannel() <-chan IO {
 
 var fs = afero.NewOsFs()
 
-func update() {
+// readDiskstats reads /proc/diskstats, returning the fields of each line.
+func readDiskstats() (interface{}, error) {
+	f, err := fs.Open("/proc/diskstats")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var stats [][]string
+	s := bufio.NewScanner(f)
+	s.Split(bufio.ScanLines)
+	for s.Scan() {
+		stats = append(stats, strings.Fields(s.Text()))
+	}
+	return stats, nil
+}
+
+func update(sample interface{}, err error) {
 	lock.Lock()
 	defer lock.Unlock()
-	var err error
-	f, err := fs.Open("/proc/diskstats")
 	if err != nil {
 		for _, m := range modules {
 			m.Error(err)
 		}
+		if watchAll {
+			allDisks.Error(err)
+		}
 		return
 	}
-	defer f.Close()
-	s := bufio.NewScanner(f)
-	s.Split(bufio.ScanLines)
+	stats, _ := sample.([][]string)
 	// Keep track of which submodules were updated, so that any drives
 	// that were removed can be cleared instead of showing stale data.
 	updated := make(map[string]bool)
-	for s.Scan() {
-		info := strings.Fields(s.Text())
+	all := []Disk{}
+	for _, info := range stats {
 		if len(info) < 14 {
 			continue
 		}
@@ -212,31 +369,57 @@ func update() {
 			modules[disk] = module
 		}
 		updated[disk] = true
-		reads, err := strconv.ParseUint(info[5], 10, 64)
-		if module.Error(err) {
-			continue
-		}
-		writes, err := strconv.ParseUint(info[9], 10, 64)
+		c, err := parseCounters(info)
 		if module.Error(err) {
 			continue
 		}
 		shouldOutput := !module.updateTime.IsZero()
-		readRate, writeRate := module.update(reads, writes)
-		module.send(IO{
-			// Linux always considers sectors to be 512 bytes long
-			// independently of the devices real block size.
-			// (from linux/types.h)
-			Input:        unit.Datarate(readRate) * 512 * unit.BytePerSecond,
-			Output:       unit.Datarate(writeRate) * 512 * unit.BytePerSecond,
-			shouldOutput: shouldOutput,
-		})
+		io := module.update(c)
+		io.shouldOutput = shouldOutput
+		module.send(io)
+		if watchAll && shouldOutput && isPhysical(disk) {
+			all = append(all, Disk{Name: disk, IO: io})
+		}
 	}
 	for disk, module := range modules {
 		if !updated[disk] {
-			module.lastRead = 0
-			module.lastWrite = 0
+			module.last = counters{}
 			module.updateTime = time.Time{}
 			module.send(IO{})
 		}
 	}
+	if watchAll {
+		allDisks.Set(all)
+	}
+}
+
+// parseCounters parses the fields of a line in /proc/diskstats. Only the
+// sector counts are required, since other fields are not available on all
+// kernels.
+func parseCounters(info []string) (c counters, err error) {
+	if c.readSectors, err = strconv.ParseUint(info[5], 10, 64); err != nil {
+		return c, err
+	}
+	if c.writeSectors, err = strconv.ParseUint(info[9], 10, 64); err != nil {
+		return c, err
+	}
+	for idx, field := range map[int]*uint64{
+		3:  &c.reads,
+		6:  &c.readTicks,
+		7:  &c.writes,
+		10: &c.writeTicks,
+		11: &c.inFlight,
+		12: &c.ioTicks,
+		13: &c.queueTicks,
+	} {
+		*field, _ = strconv.ParseUint(info[idx], 10, 64)
+	}
+	return c, nil
+}
+
+// isPhysical returns true if the disk is backed by a device, and so excludes
+// partitions and virtual devices such as loop devices or dm volumes.
+func isPhysical(disk string) bool {
+	_, err := fs.Stat("/sys/block/" + disk + "/device")
+	return err == nil
 }
@@ -17,11 +17,13 @@ package diskio
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/value"
 	"github.com/leosunmo/barista/format"
 	"github.com/leosunmo/barista/outputs"
 	testBar "github.com/leosunmo/barista/testing/bar"
@@ -50,10 +52,22 @@ func shouldReturn(t *testing.T, stats diskstats) {
 
 // resetForTest resets diskio's shared state for testing purposes.
 func resetForTest() {
+	lock.Lock()
+	defer lock.Unlock()
 	fs = afero.NewMemMapFs()
 	modules = nil
 	updater = nil
 	once = sync.Once{}
+	watchAll = false
+	allDisks = new(value.ErrorValue)
+}
+
+func writeDiskstats(t *testing.T, lines ...string) {
+	t.Helper()
+	lock.Lock()
+	defer lock.Unlock()
+	err := afero.WriteFile(fs, "/proc/diskstats", []byte(strings.Join(lines, "\n")), 0644)
+	require.NoError(t, err, "afero.WriteFile failed")
 }
 
 func TestDiskIo(t *testing.T) {
@@ -184,3 +198,104 @@ a b sda2 0 0 100 0 0 0 b 0 0 0 0
 		"Disk: 100 KiB/s",
 		"ignores invalid lines in diskstats")
 }
+
+func TestMetrics(t *testing.T) {
+	resetForTest()
+	testBar.New(t)
+	writeDiskstats(t,
+		"259 0 nvme0n1 100 0 2000 500 50 0 1000 300 2 1000 2000 0 0 0 0")
+	construct()
+
+	ios := make(chan IO, 10)
+	testBar.Run(New("nvme0n1").Output(func(i IO) bar.Output {
+		ios <- i
+		return outputs.Textf("%.0f/%.0f", i.ReadIOPS, i.WriteIOPS)
+	}))
+	testBar.LatestOutput().AssertEmpty("on start")
+
+	writeDiskstats(t,
+		"259 0 nvme0n1 400 0 5000 2000 200 0 2500 3300 4 2500 8000 0 0 0 0")
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{"100/50"}, "on tick")
+	i := <-ios
+	require.InDelta(t, 512000, i.Input.BytesPerSecond(), 1e-9)
+	require.InDelta(t, 256000, i.Output.BytesPerSecond(), 1e-9)
+	require.InDelta(t, 150, i.IOPS(), 1e-9)
+	require.Equal(t, 5*time.Millisecond, i.ReadAwait)
+	require.Equal(t, 20*time.Millisecond, i.WriteAwait)
+	require.Equal(t, 10*time.Millisecond, i.Await())
+	require.InDelta(t, 0.5, i.Utilization, 1e-9)
+	require.Equal(t, 50, i.UtilizationPct())
+	require.InDelta(t, 2.0, i.QueueDepth, 1e-9)
+	require.Equal(t, 4, i.InFlight)
+
+	// Counters are reset when the device is re-added.
+	writeDiskstats(t,
+		"259 0 nvme0n1 10 0 100 20 5 0 50 10 0 30 40 0 0 0 0")
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{"0/0"}, "on counter reset")
+	i = <-ios
+	require.Zero(t, i.Await())
+	require.Zero(t, i.Utilization)
+	require.Zero(t, i.Total())
+}
+
+func TestAll(t *testing.T) {
+	resetForTest()
+	testBar.New(t)
+	for _, disk := range []string{"sda", "nvme0n1"} {
+		require.NoError(t, fs.MkdirAll("/sys/block/"+disk+"/device", 0755))
+	}
+	require.NoError(t, fs.MkdirAll("/sys/block/loop0", 0755))
+	writeDiskstats(t,
+		"8 0 sda 0 0 0 0 0 0 0 0 0 0 0",
+		"8 1 sda1 0 0 0 0 0 0 0 0 0 0 0",
+		"7 0 loop0 0 0 0 0 0 0 0 0 0 0 0",
+		"259 0 nvme0n1 0 0 0 0 0 0 0 0 0 0 0",
+		"253 0 dm-0 0 0 0 0 0 0 0 0 0 0 0",
+	)
+
+	def := All()
+	all := All().Output(func(disks []Disk) bar.Output {
+		var out []string
+		for _, d := range disks {
+			out = append(out, fmt.Sprintf("%s:%.0f", d.Name, d.IOPS()))
+		}
+		return outputs.Text(strings.Join(out, ","))
+	})
+	testBar.Run(def, all)
+	testBar.LatestOutput().AssertEmpty("on start")
+
+	writeDiskstats(t,
+		"8 0 sda 30 0 600 0 0 0 0 0 0 0 0",
+		"8 1 sda1 30 0 600 0 0 0 0 0 0 0 0",
+		"7 0 loop0 300 0 6000 0 0 0 0 0 0 0 0",
+		"259 0 nvme0n1 0 0 0 0 3 0 6 0 0 0 0",
+		"253 0 dm-0 300 0 6000 0 0 0 0 0 0 0 0",
+	)
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{
+		"Disk: 101 KiB/s", "sda:10,nvme0n1:1"}, "on tick")
+
+	require.NoError(t, fs.MkdirAll("/sys/block/sdb/device", 0755))
+	writeDiskstats(t,
+		"8 0 sda 30 0 600 0 0 0 0 0 0 0 0",
+		"8 16 sdb 0 0 0 0 0 0 0 0 0 0 0",
+		"259 0 nvme0n1 0 0 0 0 3 0 6 0 0 0 0",
+	)
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{
+		"Disk: 0 B/s", "sda:0,nvme0n1:0"}, "first tick after disk is added")
+
+	writeDiskstats(t,
+		"8 0 sda 30 0 600 0 0 0 0 0 0 0 0",
+		"8 16 sdb 0 0 0 0 6 0 3072 0 0 0 0",
+	)
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{
+		"Disk: 512 KiB/s", "sda:0,sdb:2"}, "on hotplug and removal")
+
+	require.NoError(t, fs.Remove("/proc/diskstats"))
+	testBar.Tick()
+	testBar.LatestOutput().AssertError("on error")
+}
@@ -0,0 +1,259 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskspace
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
+	"github.com/leosunmo/barista/base/value"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+
+	"github.com/spf13/afero"
+)
+
+const mountinfoFile = "/proc/self/mountinfo"
+
+// Disk represents the disk space of a mounted filesystem.
+type Disk struct {
+	Info
+	// Device is the mounted device, e.g. /dev/sdb1.
+	Device string
+	// MountPoint is where the filesystem is mounted, e.g. /run/media/user/USB.
+	MountPoint string
+	// FSType is the type of the filesystem, e.g. "ext4" or "vfat".
+	FSType string
+}
+
+// filter restricts the mounts shown by an AutoModule.
+type filter struct {
+	fsTypes []string
+	devices []string
+}
+
+func (f filter) matches(device, fsType string) bool {
+	if len(f.fsTypes) > 0 && !contains(f.fsTypes, fsType) {
+		return false
+	}
+	if len(f.devices) == 0 {
+		// By default, only show block devices, except loop devices, which
+		// are usually read-only images (e.g. snaps).
+		return strings.HasPrefix(device, "/dev/") &&
+			!strings.HasPrefix(device, "/dev/loop")
+	}
+	for _, prefix := range f.devices {
+		if strings.HasPrefix(device, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// AutoModule represents a bar.Module that displays the disk space of all
+// mounted filesystems that match its filters. Filesystems are added and
+// removed as soon as they are mounted or unmounted.
+type AutoModule struct {
+	sampler    *sampler.Sampler
+	filter     value.Value // of filter
+	outputFunc value.Value // of func([]Disk) bar.Output
+}
+
+// Auto constructs a diskspace module that discovers filesystems from the
+// mount table. By default, all mounted block devices are shown.
+func Auto() *AutoModule {
+	m := new(AutoModule)
+	m.sampler = sampler.New(fmt.Sprintf("%s#%p", mountinfoFile, m),
+		func() (interface{}, error) {
+			return readDisks(m.filter.Get().(filter))
+		})
+	l.Register(m, "sampler", "filter", "outputFunc")
+	m.filter.Set(filter{})
+	m.RefreshInterval(3 * time.Second)
+	// Default output is the used space of each filesystem, and middle click
+	// ejects the disk, e.g. to safely remove a USB drive. Disks that are not
+	// on a removable drive are never ejected (see Disk.Eject).
+	m.Output(func(disks []Disk) bar.Output {
+		out := outputs.Group()
+		for _, d := range disks {
+			d := d
+			out.Append(outputs.Textf("%s %.2f GB", d.MountPoint, d.Used().Gigabytes()).
+				OnClick(func(e bar.Event) {
+					if e.Button != bar.ButtonMiddle {
+						return
+					}
+					if err := d.Eject(); err != nil {
+						l.Log("Error ejecting %s: %v", d.Device, err)
+					}
+				}))
+		}
+		return out
+	})
+	return m
+}
+
+// FSTypes restricts the module to filesystems of the given types, e.g.
+// "ext4", "btrfs", or "vfat".
+func (m *AutoModule) FSTypes(types ...string) *AutoModule {
+	f := m.filter.Get().(filter)
+	f.fsTypes = types
+	m.filter.Set(f)
+	return m
+}
+
+// Devices restricts the module to devices that start with any of the given
+// prefixes, e.g. "/dev/sd" for SATA and USB disks, or "/dev/mapper/" for
+// encrypted volumes. This replaces the default of showing all block devices.
+func (m *AutoModule) Devices(prefixes ...string) *AutoModule {
+	f := m.filter.Get().(filter)
+	f.devices = prefixes
+	m.filter.Set(f)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *AutoModule) Output(outputFunc func([]Disk) bar.Output) *AutoModule {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for statfs. The mount
+// table is watched for changes, and does not depend on this interval.
+func (m *AutoModule) RefreshInterval(interval time.Duration) *AutoModule {
+	m.sampler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *AutoModule) Stream(s bar.Sink) {
+	if w, err := watchMounts(mountinfoFile); err != nil {
+		l.Log("Not watching mounts, will pick up changes on refresh: %v", err)
+	} else {
+		defer w.Close()
+		go func() {
+			for w.Wait() == nil {
+				m.sampler.Refresh()
+			}
+		}()
+	}
+	m.sampler.Refresh()
+	disks, err := m.sampler.Get()
+	nextDisks, done := m.sampler.Subscribe()
+	defer done()
+	outputFunc := m.outputFunc.Get().(func([]Disk) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextFilter, done := m.filter.Subscribe()
+	defer done()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputFunc(disks.([]Disk)))
+		select {
+		case <-nextDisks:
+			disks, err = m.sampler.Get()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func([]Disk) bar.Output)
+		case <-nextFilter:
+			disks, err = readDisks(m.filter.Get().(filter))
+		}
+	}
+}
+
+var fs = afero.NewOsFs()
+
+func readDisks(f filter) ([]Disk, error) {
+	mountinfo, err := afero.ReadFile(fs, mountinfoFile)
+	if err != nil {
+		return nil, err
+	}
+	disks := []Disk{}
+	seen := map[string]bool{}
+	s := bufio.NewScanner(bytes.NewReader(mountinfo))
+	for s.Scan() {
+		// See proc(5) for the format, e.g.
+		// 36 35 8:17 / /mnt/usb rw,nosuid - vfat /dev/sdb1 rw,uid=1000
+		fields := strings.Fields(s.Text())
+		sep := indexOf(fields, "-")
+		if sep < 5 || sep+2 >= len(fields) {
+			continue
+		}
+		d := Disk{
+			Device:     unescape(fields[sep+2]),
+			MountPoint: unescape(fields[4]),
+			FSType:     fields[sep+1],
+		}
+		// The same device can be mounted more than once, e.g. bind mounts
+		// or btrfs subvolumes, but only the first mount is shown.
+		devID := fields[2]
+		if seen[devID] || !f.matches(d.Device, d.FSType) {
+			continue
+		}
+		seen[devID] = true
+		d.Info, err = getStatFsInfo(d.MountPoint)
+		if err != nil {
+			// Most likely unmounted while reading.
+			l.Fine("Skipping %s: %v", d.MountPoint, err)
+			continue
+		}
+		disks = append(disks, d)
+	}
+	return disks, nil
+}
+
+func indexOf(items []string, item string) int {
+	for idx, i := range items {
+		if i == item {
+			return idx
+		}
+	}
+	return -1
+}
+
+// unescape replaces the octal escapes used in mountinfo for spaces, tabs,
+// newlines, and backslashes.
+func unescape(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var out strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+4 <= len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				out.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		out.WriteByte(field[i])
+	}
+	return out.String()
+}
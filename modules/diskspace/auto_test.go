@@ -0,0 +1,276 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskspace
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+)
+
+func init() {
+	busType = dbus.Test
+}
+
+type mockWatcher struct {
+	changes chan struct{}
+	closed  chan struct{}
+}
+
+func (w *mockWatcher) Wait() error {
+	select {
+	case <-w.changes:
+		return nil
+	case <-w.closed:
+		return errClosed
+	}
+}
+
+func (w *mockWatcher) Close() { close(w.closed) }
+
+// Mocked once, since modules from earlier tests can still be (re)started.
+var mockMounts struct {
+	sync.Mutex
+	watchers []*mockWatcher
+	err      error
+}
+
+func init() {
+	watchMounts = func(file string) (mountWatcher, error) {
+		mockMounts.Lock()
+		defer mockMounts.Unlock()
+		if mockMounts.err != nil {
+			return nil, mockMounts.err
+		}
+		w := &mockWatcher{make(chan struct{}, 1), make(chan struct{})}
+		mockMounts.watchers = append(mockMounts.watchers, w)
+		return w, nil
+	}
+}
+
+func resetMounts(err error) {
+	mockMounts.Lock()
+	defer mockMounts.Unlock()
+	mockMounts.watchers = nil
+	mockMounts.err = err
+}
+
+// mountsChanged signals a change to all mount watchers.
+func mountsChanged() {
+	mockMounts.Lock()
+	defer mockMounts.Unlock()
+	for _, w := range mockMounts.watchers {
+		w.changes <- struct{}{}
+	}
+}
+
+func writeMounts(t *testing.T, mounts ...string) {
+	t.Helper()
+	var lines []string
+	for idx, m := range mounts {
+		lines = append(lines, fmt.Sprintf("%d 1 %s", idx+20, m))
+	}
+	require.NoError(t, afero.WriteFile(fs, mountinfoFile,
+		[]byte(strings.Join(lines, "\n")+"\n"), 0444))
+}
+
+func gigabytes(total, used uint64) unix.Statfs_t {
+	return unix.Statfs_t{
+		Bsize:  1000 * 1000,
+		Blocks: total * 1000,
+		Bfree:  (total - used) * 1000,
+		Bavail: (total - used) * 1000,
+	}
+}
+
+const (
+	rootMount = "259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw"
+	procMount = "0:22 / /proc rw,nosuid shared:12 - proc proc rw"
+	homeMount = "259:2 /home /home rw,relatime shared:2 - ext4 /dev/nvme0n1p2 rw"
+	snapMount = "7:0 / /snap/core/1 ro,nodev shared:3 - squashfs /dev/loop0 ro"
+	bootMount = "259:1 / /boot rw,relatime shared:4 - vfat /dev/nvme0n1p1 rw"
+	usbMount  = `8:17 / /run/media/user/My\040Drive rw,nosuid shared:5 - exfat /dev/sdb1 rw`
+)
+
+func TestAuto(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	resetMounts(nil)
+	testBar.New(t)
+	statfs = mockStatfs
+
+	shouldReturn("/", gigabytes(100, 40))
+	shouldReturn("/boot", gigabytes(1, 0))
+	shouldReturn("/home", gigabytes(100, 40))
+	shouldReturn("/snap/core/1", gigabytes(1, 1))
+	shouldReturn("/run/media/user/My Drive", gigabytes(32, 8))
+	writeMounts(t, rootMount, procMount, homeMount, snapMount, bootMount)
+
+	def := Auto()
+	ext4 := Auto().FSTypes("ext4", "exfat").Output(func(disks []Disk) bar.Output {
+		var out []string
+		for _, d := range disks {
+			out = append(out, fmt.Sprintf("%s:%s:%d%%", d.Device, d.MountPoint, d.UsedPct()))
+		}
+		return outputs.Text(strings.Join(out, ","))
+	})
+	testBar.Run(def, ext4)
+	testBar.LatestOutput().AssertText([]string{
+		"/ 40.00 GB", "/boot 0.00 GB",
+		"/dev/nvme0n1p2:/:40%",
+	}, "on start")
+
+	writeMounts(t, rootMount, procMount, homeMount, snapMount, bootMount, usbMount)
+	mountsChanged()
+	testBar.LatestOutput().AssertText([]string{
+		"/ 40.00 GB", "/boot 0.00 GB", "/run/media/user/My Drive 8.00 GB",
+		"/dev/nvme0n1p2:/:40%,/dev/sdb1:/run/media/user/My Drive:25%",
+	}, "on mount")
+
+	shouldReturn("/", gigabytes(100, 50))
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{
+		"/ 50.00 GB", "/boot 0.00 GB", "/run/media/user/My Drive 8.00 GB",
+		"/dev/nvme0n1p2:/:50%,/dev/sdb1:/run/media/user/My Drive:25%",
+	}, "on tick")
+
+	def.Devices("/dev/sd", "/dev/loop")
+	testBar.LatestOutput(0).AssertText([]string{
+		"/snap/core/1 1.00 GB", "/run/media/user/My Drive 8.00 GB",
+		"/dev/nvme0n1p2:/:50%,/dev/sdb1:/run/media/user/My Drive:25%",
+	}, "on filter change")
+
+	// Unmounted while reading the mount table.
+	shouldError("/run/media/user/My Drive", unix.ENOENT)
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{
+		"/snap/core/1 1.00 GB",
+		"/dev/nvme0n1p2:/:50%",
+	}, "on unmount")
+}
+
+func TestAutoEject(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	resetMounts(nil)
+	testBar.New(t)
+	statfs = mockStatfs
+
+	bus := dbus.SetupTestBus()
+	udisks := bus.RegisterService(udisksService)
+	calls := make(chan string, 10)
+	record := func(name string) func(...interface{}) ([]interface{}, error) {
+		return func(args ...interface{}) ([]interface{}, error) {
+			calls <- name
+			return nil, nil
+		}
+	}
+	block := udisks.Object(udisksBlockDevices+"sdb1", udisksFilesystem)
+	block.On("Unmount", record("unmount sdb1"))
+	udisks.Object(udisksBlockDevices+"sdb1", udisksBlock).SetProperties(map[string]interface{}{
+		"Drive": godbus.ObjectPath("/org/freedesktop/UDisks2/drives/USB_Drive"),
+	}, dbus.SignalTypeNone)
+	drive := udisks.Object("/org/freedesktop/UDisks2/drives/USB_Drive", udisksDrive)
+	drive.SetProperties(map[string]interface{}{
+		"Removable":   true,
+		"CanPowerOff": true,
+	}, dbus.SignalTypeNone)
+	drive.On("PowerOff", record("power off"))
+	drive.On("Eject", record("eject"))
+	udisks.Object(udisksBlockDevices+"sr0", udisksFilesystem).On("Unmount", record("unmount sr0"))
+	udisks.Object(udisksBlockDevices+"sr0", udisksBlock).SetProperties(map[string]interface{}{
+		"Drive": godbus.ObjectPath("/org/freedesktop/UDisks2/drives/DVD"),
+	}, dbus.SignalTypeNone)
+	dvd := udisks.Object("/org/freedesktop/UDisks2/drives/DVD", udisksDrive)
+	dvd.SetPropertyForTest("Ejectable", true, dbus.SignalTypeNone)
+	dvd.On("Eject", record("eject dvd"))
+	udisks.Object(udisksBlockDevices+"nvme0n1p1", udisksFilesystem).On("Unmount", record("unmount nvme0n1p1"))
+	udisks.Object(udisksBlockDevices+"nvme0n1p1", udisksBlock).SetProperties(map[string]interface{}{
+		"Drive": godbus.ObjectPath("/org/freedesktop/UDisks2/drives/SSD"),
+	}, dbus.SignalTypeNone)
+	ssd := udisks.Object("/org/freedesktop/UDisks2/drives/SSD", udisksDrive)
+	ssd.SetPropertyForTest("CanPowerOff", true, dbus.SignalTypeNone)
+	ssd.On("PowerOff", record("power off ssd"))
+	udisks.Object(udisksBlockDevices+"loop0", udisksFilesystem).On("Unmount", record("unmount loop0"))
+	udisks.Object(udisksBlockDevices+"loop0", udisksBlock).
+		SetPropertyForTest("Drive", godbus.ObjectPath("/"), dbus.SignalTypeNone)
+
+	shouldReturn("/run/media/user/My Drive", gigabytes(32, 8))
+	shouldReturn("/run/media/user/DVD", gigabytes(4, 4))
+	shouldReturn("/boot", gigabytes(1, 0))
+	writeMounts(t, usbMount,
+		"11:0 / /run/media/user/DVD ro shared:6 - udf /dev/sr0 ro", bootMount)
+
+	m := Auto()
+	testBar.Run(m)
+	out := testBar.LatestOutput()
+	out.AssertText([]string{
+		"/run/media/user/My Drive 8.00 GB", "/run/media/user/DVD 4.00 GB", "/boot 0.00 GB"})
+
+	out.At(0).LeftClick()
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	require.Equal(t, "unmount sdb1", <-calls)
+	require.Equal(t, "power off", <-calls)
+
+	out.At(1).Click(bar.Event{Button: bar.ButtonMiddle})
+	require.Equal(t, "unmount sr0", <-calls)
+	require.Equal(t, "eject dvd", <-calls)
+
+	out.At(2).Click(bar.Event{Button: bar.ButtonMiddle})
+	require.Error(t, Disk{Device: "/dev/nvme0n1p1"}.Eject(), "internal drive")
+	require.Error(t, Disk{Device: "/dev/loop0"}.Eject(), "without drive")
+	require.Empty(t, calls, "only removable drives are unmounted")
+
+	block.On("Unmount", func(...interface{}) ([]interface{}, error) {
+		return nil, errors.New("target is busy")
+	})
+	require.Error(t, Disk{Device: "/dev/sdb1"}.Eject())
+	require.Error(t, Disk{Device: "/dev/sdc1"}.Unmount(), "unknown device")
+	require.Empty(t, calls)
+
+	require.Equal(t, udisksBlockDevices+"dm_2d0", blockObject("/dev/dm-0"))
+}
+
+func TestAutoErrors(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	resetMounts(errors.New("not supported"))
+	testBar.New(t)
+	statfs = mockStatfs
+
+	m := Auto()
+	testBar.Run(m)
+	testBar.NextOutput().AssertError("without mountinfo")
+	out := testBar.NextOutput("sets restart click handler")
+
+	writeMounts(t, bootMount, "garbage", "1 2 3 4 5 - 6")
+	shouldReturn("/boot", gigabytes(1, 0))
+	out.At(0).LeftClick()
+	testBar.NextOutput().Expect("on restart, clears error segment")
+	testBar.NextOutput().AssertText([]string{"/boot 0.00 GB"},
+		"on restart, without watcher")
+
+	require.Equal(t, `/a b\c`, unescape(`/a\040b\134c`))
+	require.Equal(t, `/a\04`, unescape(`/a\04`))
+}
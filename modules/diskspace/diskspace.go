@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package diskspace provides an i3bar module for disk space usage.
+// Package diskspace provides i3bar modules for disk space usage, of a single
+// path or of all mounted filesystems.
 package diskspace
 
 import (
@@ -20,10 +21,10 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
 	"github.com/leosunmo/barista/base/value"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/outputs"
-	"github.com/leosunmo/barista/timing"
 
 	"github.com/martinlindhe/unit"
 	"golang.org/x/sys/unix"
@@ -65,18 +66,20 @@ func (i Info) AvailPct() int {
 // format, click handler, update frequency, and urgency/colour functions.
 type Module struct {
 	path       string
-	scheduler  *timing.Scheduler
+	sampler    *sampler.Sampler
 	outputFunc value.Value // of func(Info) bar.Output
 }
 
 // New constructs an instance of the diskusage module for the given disk path.
 func New(path string) *Module {
 	m := &Module{
-		path:      path,
-		scheduler: timing.NewScheduler(),
+		path: path,
+		sampler: sampler.New("statfs:"+path, func() (interface{}, error) {
+			return getStatFsInfo(path)
+		}),
 	}
 	l.Label(m, path)
-	l.Register(m, "scheduler", "format")
+	l.Register(m, "sampler", "format")
 	m.RefreshInterval(3 * time.Second)
 	// Construct a simple output that's just 2 decimals of the used disk space.
 	m.Output(func(i Info) bar.Output {
@@ -93,13 +96,16 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 
 // RefreshInterval configures the polling frequency for statfs.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
-	m.scheduler.Every(interval)
+	m.sampler.Every(interval)
 	return m
 }
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	info, err := getStatFsInfo(m.path)
+	m.sampler.Refresh()
+	info, err := m.sampler.Get()
+	nextInfo, done := m.sampler.Subscribe()
+	defer done()
 	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
@@ -113,11 +119,11 @@ func (m *Module) Stream(s bar.Sink) {
 			if s.Error(err) {
 				return
 			}
-			s.Output(outputFunc(info))
+			s.Output(outputFunc(info.(Info)))
 		}
 		select {
-		case <-m.scheduler.C:
-			info, err = getStatFsInfo(m.path)
+		case <-nextInfo:
+			info, err = m.sampler.Get()
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
 		}
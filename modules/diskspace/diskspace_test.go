@@ -66,8 +66,8 @@ var mockStatfs = func(path string, out *unix.Statfs_t) error {
 
 func TestDiskspace(t *testing.T) {
 	require := require.New(t)
-	statfs = mockStatfs
 	testBar.New(t)
+	statfs = mockStatfs
 
 	shouldReturn("/", unix.Statfs_t{
 		Bsize:  1000 * 1000,
@@ -126,6 +126,11 @@ func TestDiskspace(t *testing.T) {
 	testBar.NextOutput().Expect("on next tick")
 	require.Equal(time.Minute, afterTick.Sub(beforeTick))
 
+	afterTick = timing.NextTick()
+	testBar.NextOutput().Expect("on aligned tick")
+	require.Equal(afterTick.Truncate(time.Minute), afterTick,
+		"later updates aligned to refresh interval")
+
 	shouldError("/", os.ErrPermission)
 	testBar.Tick()
 	testBar.NextOutput().AssertError("on tick with error")
@@ -136,8 +141,8 @@ func TestDiskspace(t *testing.T) {
 
 func TestDiskspaceInfo(t *testing.T) {
 	require := require.New(t)
-	statfs = mockStatfs
 	testBar.New(t)
+	statfs = mockStatfs
 
 	infos := make(chan Info)
 
@@ -185,8 +190,8 @@ func TestDiskspaceInfo(t *testing.T) {
 }
 
 func TestNonexistentDiskspace(t *testing.T) {
-	statfs = mockStatfs
 	testBar.New(t)
+	statfs = mockStatfs
 
 	diskspace := New("/not/yet/mounted")
 	testBar.Run(diskspace)
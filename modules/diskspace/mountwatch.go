@@ -0,0 +1,50 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskspace
+
+import (
+	"golang.org/x/sys/unix"
+
+	"github.com/leosunmo/barista/base/watchers/pollpri"
+)
+
+// mountWatcher waits for changes to the mount table.
+type mountWatcher interface {
+	// Wait blocks until the mount table changes, and returns errClosed once
+	// the watcher is closed.
+	Wait() error
+	// Close stops watching, and unblocks any pending Wait.
+	Close()
+}
+
+var errClosed = pollpri.ErrClosed
+
+// watchMounts watches a mountinfo file. To allow tests to mock out the
+// watcher, which requires a real mountinfo file.
+var watchMounts = openPollWatcher
+
+// openPollWatcher watches a mountinfo file, which signals changes to the
+// mount table using both POLLPRI and POLLERR.
+func openPollWatcher(file string) (mountWatcher, error) {
+	fd, err := unix.Open(file, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	w, err := pollpri.New(fd, unix.POLLPRI|unix.POLLERR)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskspace
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	godbus "github.com/godbus/dbus/v5"
+
+	"github.com/leosunmo/barista/base/watchers/dbus"
+)
+
+var busType = dbus.System
+
+const (
+	udisksService      = "org.freedesktop.UDisks2"
+	udisksBlockDevices = "/org/freedesktop/UDisks2/block_devices/"
+	udisksBlock        = "org.freedesktop.UDisks2.Block"
+	udisksFilesystem   = "org.freedesktop.UDisks2.Filesystem"
+	udisksDrive        = "org.freedesktop.UDisks2.Drive"
+)
+
+// Unmount unmounts the filesystem using udisks2, which allows the user of
+// the current session to unmount removable drives without root.
+func (d Disk) Unmount() error {
+	w := dbus.WatchProperties(busType, udisksService, blockObject(d.Device), udisksFilesystem)
+	defer w.Unsubscribe()
+	if _, err := w.Call("Unmount", map[string]godbus.Variant{}); err != nil {
+		return fmt.Errorf("Unmount(%s): %w", d.Device, err)
+	}
+	return nil
+}
+
+// Eject unmounts the filesystem, and then powers off or ejects the drive
+// using udisks2, so that it can be safely removed. Only removable drives
+// (e.g. USB drives or optical discs) can be ejected, and other filesystems
+// are left mounted.
+func (d Disk) Eject() error {
+	block := dbus.WatchProperties(busType, udisksService, blockObject(d.Device), udisksBlock).
+		Add("Drive")
+	drivePath, _ := block.Get()["Drive"].(godbus.ObjectPath)
+	block.Unsubscribe()
+	// Devices without a drive (e.g. loop devices) use "/".
+	if drivePath == "" || drivePath == "/" {
+		return fmt.Errorf("no drive for %s", d.Device)
+	}
+	drive := dbus.WatchProperties(busType, udisksService, string(drivePath), udisksDrive).
+		Add("Removable", "Ejectable", "CanPowerOff")
+	defer drive.Unsubscribe()
+	props := drive.Get()
+	removable, _ := props["Removable"].(bool)
+	ejectable, _ := props["Ejectable"].(bool)
+	if !removable && !ejectable {
+		return fmt.Errorf("%s is not removable", d.Device)
+	}
+	if err := d.Unmount(); err != nil {
+		return err
+	}
+	method := "Eject"
+	if canPowerOff, _ := props["CanPowerOff"].(bool); canPowerOff {
+		method = "PowerOff"
+	}
+	if _, err := drive.Call(method, map[string]godbus.Variant{}); err != nil {
+		return fmt.Errorf("%s(%s): %w", method, drivePath, err)
+	}
+	return nil
+}
+
+// blockObject returns the udisks2 object path for a device, e.g.
+// /org/freedesktop/UDisks2/block_devices/sdb1 for /dev/sdb1.
+func blockObject(device string) string {
+	// Resolve symlinks such as /dev/mapper/luks-... to /dev/dm-0.
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	// udisks2 escapes characters that are not valid in object paths.
+	var path strings.Builder
+	path.WriteString(udisksBlockDevices)
+	for _, c := range []byte(filepath.Base(device)) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+			path.WriteByte(c)
+		default:
+			fmt.Fprintf(&path, "_%02x", c)
+		}
+	}
+	return path.String()
+}
@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package hwmon implements an i3bar module that shows the temperature from /sys/class/hwmon
+// Package hwmon implements i3bar modules that show the temperature, or all
+// sensor readings, from /sys/class/hwmon
 package hwmon
 
 import (
@@ -0,0 +1,358 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hwmon
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
+	"github.com/leosunmo/barista/base/value"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+)
+
+const hwmonDir = "/sys/class/hwmon"
+
+// Sensor identifies a single sensor of a hwmon chip.
+type Sensor struct {
+	// Name is the sysfs name of the sensor, e.g. "temp1" or "fan2".
+	Name string
+	// Label is the label provided by the driver, e.g. "Tctl" or "Package id 0",
+	// or the same as Name if the driver does not provide one.
+	Label string
+}
+
+// Temp is a temperature sensor. Thresholds are zero if not provided.
+type Temp struct {
+	Sensor
+	Input, Max, Crit unit.Temperature
+}
+
+// Fan is a fan speed sensor, in RPM. Thresholds are zero if not provided.
+type Fan struct {
+	Sensor
+	Input, Min, Max float64
+}
+
+// Voltage is a voltage sensor. Thresholds are zero if not provided.
+type Voltage struct {
+	Sensor
+	Input, Min, Max, Crit unit.Voltage
+}
+
+// Power is a power sensor. Thresholds are zero if not provided.
+type Power struct {
+	Sensor
+	Input, Max, Crit unit.Power
+}
+
+// Current is a current sensor. Thresholds are zero if not provided.
+type Current struct {
+	Sensor
+	Input, Max, Crit unit.ElectricCurrent
+}
+
+// Chip represents a single hwmon device, and all of its sensors.
+type Chip struct {
+	// Name is the name of the chip driver, e.g. "k10temp" or "nvme". Names are
+	// not unique, e.g. with multiple drives or batteries.
+	Name string
+	// Path is the sysfs directory of the chip, e.g. /sys/class/hwmon/hwmon3.
+	Path     string
+	Temps    []Temp
+	Fans     []Fan
+	Voltages []Voltage
+	Powers   []Power
+	Currents []Current
+}
+
+// Temp returns the temperature sensor with the given label.
+func (c Chip) Temp(label string) (Temp, bool) {
+	for _, t := range c.Temps {
+		if t.Label == label {
+			return t, true
+		}
+	}
+	return Temp{}, false
+}
+
+// Fan returns the fan sensor with the given label.
+func (c Chip) Fan(label string) (Fan, bool) {
+	for _, f := range c.Fans {
+		if f.Label == label {
+			return f, true
+		}
+	}
+	return Fan{}, false
+}
+
+// Voltage returns the voltage sensor with the given label.
+func (c Chip) Voltage(label string) (Voltage, bool) {
+	for _, v := range c.Voltages {
+		if v.Label == label {
+			return v, true
+		}
+	}
+	return Voltage{}, false
+}
+
+// Info represents all hwmon chips, in sysfs order.
+type Info []Chip
+
+// Chip returns the first chip with the given name.
+func (i Info) Chip(name string) (Chip, bool) {
+	for _, c := range i {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Chip{}, false
+}
+
+// MaxTemp returns the highest temperature reading across all chips.
+func (i Info) MaxTemp() (Temp, bool) {
+	var max Temp
+	found := false
+	for _, c := range i {
+		for _, t := range c.Temps {
+			if !found || t.Input > max.Input {
+				max, found = t, true
+			}
+		}
+	}
+	return max, found
+}
+
+// AllModule represents a bar.Module that displays readings from all hwmon
+// sensors.
+type AllModule struct {
+	sampler    *sampler.Sampler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// All constructs a module that reads every sensor of every hwmon chip.
+func All() *AllModule {
+	m := &AllModule{sampler: sampler.New(hwmonDir+"#all", readAll)}
+	l.Register(m, "sampler", "outputFunc")
+	m.RefreshInterval(3 * time.Second)
+	// Default output is the highest temperature.
+	m.Output(func(i Info) bar.Output {
+		t, ok := i.MaxTemp()
+		if !ok {
+			return nil
+		}
+		return outputs.Textf("%.1f℃", t.Input.Celsius())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *AllModule) Output(outputFunc func(Info) bar.Output) *AllModule {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for sensors.
+func (m *AllModule) RefreshInterval(interval time.Duration) *AllModule {
+	m.sampler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *AllModule) Stream(s bar.Sink) {
+	info, err := m.sampler.Get()
+	nextInfo, done := m.sampler.Subscribe()
+	defer done()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if err != nil {
+			s.Error(err)
+		} else {
+			s.Output(outputFunc(info.(Info)))
+		}
+		select {
+		case <-nextInfo:
+			info, err = m.sampler.Get()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+var fs = afero.NewOsFs()
+
+var inputRe = regexp.MustCompile(`^(temp|fan|in|power|curr)(\d+)_(input|average)$`)
+
+func readAll() (interface{}, error) {
+	dirs, err := afero.ReadDir(fs, hwmonDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, errors.New("no hwmon devices found")
+	}
+	info := Info{}
+	for _, d := range dirs {
+		chip, err := readChip(filepath.Join(hwmonDir, d.Name()))
+		if err != nil {
+			// Devices can be removed while being read, e.g. USB power supplies.
+			l.Fine("Skipping hwmon %s: %v", d.Name(), err)
+			continue
+		}
+		info = append(info, chip)
+	}
+	sort.SliceStable(info, func(a, b int) bool {
+		return naturalLess(info[a].Path, info[b].Path)
+	})
+	return info, nil
+}
+
+func readChip(dir string) (Chip, error) {
+	c := Chip{Path: dir}
+	name, err := afero.ReadFile(fs, filepath.Join(dir, "name"))
+	if err != nil {
+		return c, err
+	}
+	c.Name = strings.TrimSpace(string(name))
+	files, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return c, err
+	}
+	var sensors []string
+	seen := map[string]bool{}
+	for _, f := range files {
+		match := inputRe.FindStringSubmatch(f.Name())
+		if match == nil {
+			continue
+		}
+		sensor := match[1] + match[2]
+		// Prefer _input over _average for power sensors.
+		if match[3] == "average" && fileExists(filepath.Join(dir, sensor+"_input")) {
+			continue
+		}
+		if !seen[sensor] {
+			seen[sensor] = true
+			sensors = append(sensors, sensor)
+		}
+	}
+	sort.Slice(sensors, func(a, b int) bool { return naturalLess(sensors[a], sensors[b]) })
+	for _, name := range sensors {
+		// Sensors that can't be read (e.g. disconnected fan headers) return
+		// errors, and are skipped.
+		r := reader{dir: dir, name: name}
+		input := r.read("input")
+		if r.err != nil && strings.HasPrefix(name, "power") {
+			r.err = nil
+			input = r.read("average")
+		}
+		if r.err != nil {
+			l.Fine("Skipping %s/%s: %v", dir, name, r.err)
+			continue
+		}
+		s := Sensor{Name: name, Label: r.label()}
+		switch {
+		case strings.HasPrefix(name, "temp"):
+			c.Temps = append(c.Temps, Temp{s,
+				unit.FromCelsius(input / 1000),
+				threshold(r.read("max")), threshold(r.read("crit"))})
+		case strings.HasPrefix(name, "fan"):
+			c.Fans = append(c.Fans, Fan{s, input, r.read("min"), r.read("max")})
+		case strings.HasPrefix(name, "in"):
+			c.Voltages = append(c.Voltages, Voltage{s,
+				unit.Voltage(input) * unit.Millivolt,
+				unit.Voltage(r.read("min")) * unit.Millivolt,
+				unit.Voltage(r.read("max")) * unit.Millivolt,
+				unit.Voltage(r.read("crit")) * unit.Millivolt})
+		case strings.HasPrefix(name, "power"):
+			c.Powers = append(c.Powers, Power{s,
+				unit.Power(input) * unit.Microwatt,
+				unit.Power(r.read("max")) * unit.Microwatt,
+				unit.Power(r.read("crit")) * unit.Microwatt})
+		case strings.HasPrefix(name, "curr"):
+			c.Currents = append(c.Currents, Current{s,
+				unit.ElectricCurrent(input) * unit.Milliampere,
+				unit.ElectricCurrent(r.read("max")) * unit.Milliampere,
+				unit.ElectricCurrent(r.read("crit")) * unit.Milliampere})
+		}
+	}
+	return c, nil
+}
+
+// reader reads the attributes of a single sensor. Values are in the units
+// used by sysfs, e.g. millidegrees Celsius.
+type reader struct {
+	dir, name string
+	// err is the error from reading the most recent attribute.
+	err error
+}
+
+func (r *reader) read(attr string) float64 {
+	var bytes []byte
+	bytes, r.err = afero.ReadFile(fs, filepath.Join(r.dir, r.name+"_"+attr))
+	if r.err != nil {
+		return 0
+	}
+	var val float64
+	val, r.err = strconv.ParseFloat(strings.TrimSpace(string(bytes)), 64)
+	return val
+}
+
+func (r *reader) label() string {
+	bytes, err := afero.ReadFile(fs, filepath.Join(r.dir, r.name+"_label"))
+	if label := strings.TrimSpace(string(bytes)); err == nil && label != "" {
+		return label
+	}
+	return r.name
+}
+
+// threshold converts a temperature threshold in millidegrees Celsius, where
+// zero means the threshold is not provided.
+func threshold(v float64) unit.Temperature {
+	if v == 0 {
+		return 0
+	}
+	return unit.FromCelsius(v / 1000)
+}
+
+func fileExists(path string) bool {
+	_, err := fs.Stat(path)
+	return err == nil
+}
+
+var digitsRe = regexp.MustCompile(`\d+$`)
+
+// naturalLess compares strings with a numeric suffix numerically, so that
+// hwmon10 sorts after hwmon9.
+func naturalLess(a, b string) bool {
+	aPrefix, bPrefix := digitsRe.ReplaceAllString(a, ""), digitsRe.ReplaceAllString(b, "")
+	if aPrefix != bPrefix {
+		return aPrefix < bPrefix
+	}
+	aNum, _ := strconv.Atoi(a[len(aPrefix):])
+	bNum, _ := strconv.Atoi(b[len(bPrefix):])
+	return aNum < bNum
+}
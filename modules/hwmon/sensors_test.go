@@ -0,0 +1,155 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hwmon
+
+import (
+	"testing"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, data := range files {
+		err := afero.WriteFile(fs, "/sys/class/hwmon/"+path, []byte(data+"\n"), 0644)
+		require.NoError(t, err, "afero.WriteFile failed")
+	}
+}
+
+func TestAllSensors(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	writeFiles(t, map[string]string{
+		"hwmon0/name":        "k10temp",
+		"hwmon0/temp1_label": "Tctl",
+		"hwmon0/temp1_input": "63500",
+		"hwmon0/temp3_label": "Tccd1",
+		"hwmon0/temp3_input": "58250",
+
+		"hwmon2/name":         "nct6798",
+		"hwmon2/fan1_input":   "1200",
+		"hwmon2/fan1_min":     "300",
+		"hwmon2/fan2_input":   "0",
+		"hwmon2/fan10_input":  "800",
+		"hwmon2/in0_label":    "Vcore",
+		"hwmon2/in0_input":    "1104",
+		"hwmon2/in0_min":      "800",
+		"hwmon2/in0_max":      "1500",
+		"hwmon2/temp1_input":  "-5000",
+		"hwmon2/temp1_max":    "80000",
+		"hwmon2/temp1_crit":   "100000",
+		"hwmon2/curr1_input":  "1500",
+		"hwmon2/curr1_max":    "3000",
+		"hwmon2/power1_input": "15500000",
+		"hwmon2/pwm1":         "128",
+		"hwmon2/fan3_input":   "not a number",
+
+		"hwmon10/name":           "amdgpu",
+		"hwmon10/power1_average": "35000000",
+		"hwmon10/power1_cap":     "200000000",
+		"hwmon10/temp1_label":    "edge",
+		"hwmon10/temp1_input":    "70000",
+		"hwmon10/temp1_crit":     "100000",
+	})
+	// Removed during the scan.
+	require.NoError(t, fs.MkdirAll("/sys/class/hwmon/hwmon5", 0755))
+	testBar.New(t)
+
+	infos := make(chan Info, 10)
+	all := All().Output(func(i Info) bar.Output {
+		infos <- i
+		return nil
+	})
+	testBar.Run(All(), all)
+	testBar.LatestOutput().AssertText([]string{"70.0℃"}, "on start")
+	info := <-infos
+
+	require.Len(t, info, 3)
+	require.Equal(t, []string{"k10temp", "nct6798", "amdgpu"},
+		[]string{info[0].Name, info[1].Name, info[2].Name}, "sorted by hwmon index")
+
+	k10temp, ok := info.Chip("k10temp")
+	require.True(t, ok)
+	require.Equal(t, "/sys/class/hwmon/hwmon0", k10temp.Path)
+	tccd, ok := k10temp.Temp("Tccd1")
+	require.True(t, ok)
+	require.Equal(t, "temp3", tccd.Name)
+	require.InDelta(t, 58.25, tccd.Input.Celsius(), 1e-9)
+	require.Zero(t, tccd.Crit, "without crit threshold")
+	_, ok = k10temp.Temp("Tccd2")
+	require.False(t, ok)
+
+	nct, _ := info.Chip("nct6798")
+	require.Len(t, nct.Fans, 3, "unreadable fan skipped")
+	require.Equal(t, []string{"fan1", "fan2", "fan10"},
+		[]string{nct.Fans[0].Label, nct.Fans[1].Label, nct.Fans[2].Label})
+	fan, ok := nct.Fan("fan1")
+	require.True(t, ok)
+	require.Equal(t, Fan{Sensor{"fan1", "fan1"}, 1200, 300, 0}, fan)
+	vcore, ok := nct.Voltage("Vcore")
+	require.True(t, ok)
+	require.InDelta(t, 1.104, vcore.Input.Volts(), 1e-9)
+	require.InDelta(t, 0.8, vcore.Min.Volts(), 1e-9)
+	require.InDelta(t, 1.5, vcore.Max.Volts(), 1e-9)
+	require.InDelta(t, -5, nct.Temps[0].Input.Celsius(), 1e-9)
+	require.InDelta(t, 80, nct.Temps[0].Max.Celsius(), 1e-9)
+	require.InDelta(t, 100, nct.Temps[0].Crit.Celsius(), 1e-9)
+	require.InDelta(t, 1.5, nct.Currents[0].Input.Amperes(), 1e-9)
+	require.InDelta(t, 3, nct.Currents[0].Max.Amperes(), 1e-9)
+	require.InDelta(t, 15.5, nct.Powers[0].Input.Watts(), 1e-9)
+
+	gpu, _ := info.Chip("amdgpu")
+	require.Len(t, gpu.Powers, 1)
+	require.InDelta(t, 35, gpu.Powers[0].Input.Watts(), 1e-9, "from power1_average")
+
+	writeFiles(t, map[string]string{"hwmon0/temp1_input": "95000"})
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{"95.0℃"}, "on tick")
+	info = <-infos
+
+	max, ok := info.MaxTemp()
+	require.True(t, ok)
+	require.Equal(t, "Tctl", max.Label)
+	_, ok = info.Chip("coretemp")
+	require.False(t, ok)
+}
+
+func TestAllSensorsErrors(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	m := All()
+	testBar.Run(m)
+	testBar.LatestOutput().AssertError("without /sys/class/hwmon")
+
+	require.NoError(t, fs.MkdirAll("/sys/class/hwmon", 0755))
+	testBar.Tick()
+	testBar.LatestOutput().AssertError("without any devices")
+
+	writeFiles(t, map[string]string{
+		"hwmon0/name":       "acpi_fan",
+		"hwmon0/fan1_input": "2000",
+	})
+	testBar.Tick()
+	testBar.LatestOutput().AssertEmpty("without temperature sensors")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.0f", i[0].Fans[0].Input)
+	})
+	testBar.LatestOutput().AssertText([]string{"2000"})
+}
@@ -20,10 +20,10 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
 	"github.com/leosunmo/barista/base/value"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/outputs"
-	"github.com/leosunmo/barista/timing"
 
 	"github.com/martinlindhe/unit"
 	"github.com/spf13/afero"
@@ -33,17 +33,20 @@ import (
 // format, click handler, update frequency, and urgency/colour functions.
 type Module struct {
 	thermalFile string
-	scheduler   *timing.Scheduler
+	sampler     *sampler.Sampler
+	nextTemp    <-chan struct{}
 	outputFunc  value.Value // of func(unit.Temperature) bar.Output
 }
 
 func newModule(thermalFile string) *Module {
 	m := &Module{
 		thermalFile: thermalFile,
-		scheduler:   timing.NewScheduler(),
+		sampler: sampler.New(thermalFile, func() (interface{}, error) {
+			return getTemperature(thermalFile)
+		}),
 	}
 	l.Label(m, thermalFile)
-	l.Register(m, "scheduler", "format")
+	l.Register(m, "sampler", "format")
 	m.RefreshInterval(3 * time.Second)
 	// Default output, if no function is specified later.
 	m.Output(func(t unit.Temperature) bar.Output {
@@ -61,7 +64,7 @@ func (m *Module) Output(outputFunc func(unit.Temperature) bar.Output) *Module {
 // RefreshInterval configures the polling frequency for cpu temperatures.
 // Note: updates might still be less frequent if the temperature does not change.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
-	m.scheduler.Every(interval)
+	m.sampler.Every(interval)
 	return m
 }
 
@@ -69,7 +72,13 @@ var fs = afero.NewOsFs()
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	temp, err := getTemperature(m.thermalFile)
+	m.sampler.Refresh()
+	temp, err := m.sampler.Get()
+	// Kept across restarts, so that samples taken while the module is stopped
+	// (e.g. after an error) still trigger an update once it is restarted.
+	if m.nextTemp == nil {
+		m.nextTemp, _ = m.sampler.Subscribe()
+	}
 	outputFunc := m.outputFunc.Get().(func(unit.Temperature) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
@@ -77,10 +86,10 @@ func (m *Module) Stream(s bar.Sink) {
 		if s.Error(err) {
 			return
 		}
-		s.Output(outputFunc(temp))
+		s.Output(outputFunc(temp.(unit.Temperature)))
 		select {
-		case <-m.scheduler.C:
-			temp, err = getTemperature(m.thermalFile)
+		case <-m.nextTemp:
+			temp, err = m.sampler.Get()
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(unit.Temperature) bar.Output)
 		}
@@ -0,0 +1,207 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package kbdlayout provides an i3bar module that displays and switches the
+active keyboard layout in sway.
+
+The layout names reported by sway are the full xkb descriptions, which can be
+mapped to short codes in the output function:
+
+	codes := map[string]string{"English (US)": "us", "German": "de"}
+	kbdlayout.New().Output(func(l kbdlayout.Layout) bar.Output {
+		return outputs.Text(codes[l.Name])
+	})
+*/
+package kbdlayout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/value"
+	"github.com/leosunmo/barista/base/watchers/i3ipc"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+)
+
+// Layout represents the keyboard layouts of a keyboard.
+type Layout struct {
+	// Keyboard is the sway input identifier of the keyboard.
+	Keyboard string
+	// Name is the name of the active layout, e.g. "English (US)".
+	Name string
+	// Index is the index of the active layout in Layouts.
+	Index   int
+	Layouts []string
+}
+
+// quote quotes a string for use as an argument in a sway command.
+func quote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+func (k Layout) switchLayout(arg string) {
+	cmd := fmt.Sprintf("input %s xkb_switch_layout %s", quote(k.Keyboard), arg)
+	if err := i3ipc.RunCommand(cmd); err != nil {
+		l.Log("Failed to switch keyboard layout: %v", err)
+	}
+}
+
+// Next switches to the next layout.
+func (k Layout) Next() {
+	k.switchLayout("next")
+}
+
+// Previous switches to the previous layout.
+func (k Layout) Previous() {
+	k.switchLayout("prev")
+}
+
+// Switch switches to the layout at the given index.
+func (k Layout) Switch(index int) {
+	k.switchLayout(fmt.Sprintf("%d", index))
+}
+
+// Module represents a keyboard layout bar module.
+type Module struct {
+	keyboard   string
+	outputFunc value.Value // of func(Layout) bar.Output
+}
+
+func newModule(keyboard string) *Module {
+	m := &Module{keyboard: keyboard}
+	l.Register(m, "outputFunc")
+	m.Output(func(k Layout) bar.Output {
+		return outputs.Text(k.Name)
+	})
+	return m
+}
+
+// New constructs a keyboard layout module for the first keyboard that has
+// layouts configured.
+func New() *Module {
+	return newModule("")
+}
+
+// Keyboard constructs a keyboard layout module for the keyboard with the given
+// sway input identifier, e.g. "1:1:AT_Translated_Set_2_keyboard".
+func Keyboard(identifier string) *Module {
+	m := newModule(identifier)
+	l.Label(m, identifier)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Layout) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// defaultClickHandler switches to the next layout on left click or scroll
+// down, and to the previous layout on right click or scroll up.
+func defaultClickHandler(k Layout) func(bar.Event) {
+	return func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonLeft, bar.ScrollDown, bar.ScrollRight:
+			k.Next()
+		case bar.ButtonRight, bar.ScrollUp, bar.ScrollLeft:
+			k.Previous()
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	sub := i3ipc.Subscribe(i3ipc.InputEvents)
+	defer sub.Unsubscribe()
+	outputFunc := m.outputFunc.Get().(func(Layout) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	layout, err := m.layout()
+	for {
+		if err == errNotFound {
+			// Hide the module until the keyboard is plugged in.
+			s.Output(nil)
+		} else {
+			if s.Error(err) {
+				return
+			}
+			s.Output(outputs.Group(outputFunc(layout)).OnClick(defaultClickHandler(layout)))
+		}
+		select {
+		case <-sub.C:
+			layout, err = m.layoutFromEvent(sub.Get(), layout)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Layout) bar.Output)
+		}
+	}
+}
+
+// matches returns true if the input is the keyboard used by the module.
+func (m *Module) matches(in i3ipc.Input) bool {
+	if m.keyboard != "" {
+		return in.Identifier == m.keyboard
+	}
+	return in.Type == "keyboard" && len(in.XkbLayoutNames) > 0
+}
+
+func (m *Module) layout() (Layout, error) {
+	inputs, err := i3ipc.Inputs()
+	if err != nil {
+		return Layout{}, err
+	}
+	for _, in := range inputs {
+		if m.matches(in) {
+			return layoutFromInput(in), nil
+		}
+	}
+	return Layout{}, errNotFound
+}
+
+// errNotFound is returned when the keyboard is not connected. It's not shown
+// as an error, since keyboards can be plugged in later.
+var errNotFound = errors.New("keyboard not found")
+
+// layoutFromEvent returns the layout after an input event. Layout changes for
+// the current keyboard carry all the information needed, but other changes
+// (e.g. keyboards added or removed) require fetching all inputs.
+func (m *Module) layoutFromEvent(e i3ipc.Event, current Layout) (Layout, error) {
+	var ev i3ipc.InputEvent
+	if e.Decode(&ev) != nil {
+		return m.layout()
+	}
+	switch ev.Change {
+	case "xkb_layout", "xkb_keymap":
+		if m.matches(ev.Input) && ev.Input.Identifier == current.Keyboard {
+			return layoutFromInput(ev.Input), nil
+		}
+		return current, nil
+	case "added", "removed":
+		return m.layout()
+	}
+	return current, nil
+}
+
+func layoutFromInput(in i3ipc.Input) Layout {
+	return Layout{
+		Keyboard: in.Identifier,
+		Name:     in.XkbActiveLayoutName,
+		Index:    in.XkbActiveLayoutIndex,
+		Layouts:  in.XkbLayoutNames,
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kbdlayout
+
+import (
+	"testing"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/i3ipc"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+)
+
+var (
+	mouse    = i3ipc.Input{Identifier: "2:10:Mouse", Type: "pointer"}
+	laptop   = keyboard("1:1:AT_Translated_Set_2_keyboard", 0, "English (US)", "German")
+	external = keyboard("1241:662:USB_Keyboard", 1, "English (US)", "French")
+)
+
+func keyboard(id string, active int, layouts ...string) i3ipc.Input {
+	return i3ipc.Input{
+		Identifier:           id,
+		Type:                 "keyboard",
+		XkbLayoutNames:       layouts,
+		XkbActiveLayoutIndex: active,
+		XkbActiveLayoutName:  layouts[active],
+	}
+}
+
+func TestLayout(t *testing.T) {
+	srv := i3ipc.SetupTestServer()
+	srv.SetInputs(mouse, laptop, external)
+
+	codes := map[string]string{"English (US)": "us", "German": "de", "French": "fr"}
+	testBar.New(t)
+	first := New().Output(func(l Layout) bar.Output {
+		return outputs.Textf("%s (%d/%d)", codes[l.Name], l.Index+1, len(l.Layouts))
+	})
+	ext := Keyboard(external.Identifier)
+	testBar.Run(first, ext)
+
+	out := testBar.LatestOutput(0, 1)
+	out.AssertText([]string{"us (1/2)", "French"})
+
+	out.At(0).LeftClick()
+	srv.AssertCommand(t, `input "1:1:AT_Translated_Set_2_keyboard" xkb_switch_layout next`)
+	out.At(1).Click(bar.Event{Button: bar.ScrollUp})
+	srv.AssertCommand(t, `input "1241:662:USB_Keyboard" xkb_switch_layout prev`)
+
+	srv.Emit(i3ipc.InputEvents, i3ipc.InputEvent{
+		Change: "xkb_layout",
+		Input:  keyboard(laptop.Identifier, 1, "English (US)", "German"),
+	})
+	testBar.LatestOutput(0).AssertText(
+		[]string{"de (2/2)", "French"}, "on layout change")
+
+	srv.SetInputs(mouse, laptop)
+	srv.Emit(i3ipc.InputEvents, i3ipc.InputEvent{Change: "removed", Input: external})
+	testBar.LatestOutput(0, 1).AssertText(
+		[]string{"us (1/2)"}, "refetched inputs, and hides removed keyboard")
+
+	srv.SetInputs(mouse, laptop, external)
+	srv.Emit(i3ipc.InputEvents, i3ipc.InputEvent{Change: "added", Input: external})
+	testBar.LatestOutput(0, 1).AssertText(
+		[]string{"us (1/2)", "French"}, "when keyboard plugged in again")
+}
+
+func TestNoKeyboard(t *testing.T) {
+	srv := i3ipc.SetupTestServer()
+	srv.SetInputs(mouse)
+	testBar.New(t)
+	testBar.Run(New())
+	testBar.NextOutput().AssertEmpty("without keyboards")
+
+	srv.SetInputs(mouse, laptop)
+	srv.Emit(i3ipc.InputEvents, i3ipc.InputEvent{Change: "added", Input: laptop})
+	testBar.NextOutput().AssertText([]string{"English (US)"}, "when keyboard added")
+}
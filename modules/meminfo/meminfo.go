@@ -23,11 +23,11 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
 	"github.com/leosunmo/barista/base/value"
 	"github.com/leosunmo/barista/format"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/outputs"
-	"github.com/leosunmo/barista/timing"
 
 	"github.com/martinlindhe/unit"
 	"github.com/spf13/afero"
@@ -60,27 +60,17 @@ func (i Info) AvailFrac() float64 {
 	return float64(i.Available()) / float64(i["MemTotal"])
 }
 
-// currentInfo stores the last value read by the updater.
-// This allows newly created modules to start with data.
-var currentInfo = new(value.ErrorValue) // of Info
-
 var once sync.Once
-var updater *timing.Scheduler
+var updater *sampler.Sampler
 
 // construct initialises meminfo's global updating. All meminfo
-// modules are updated with just one read of /proc/meminfo.
+// modules are updated with just one read of /proc/meminfo, which
+// is shared with any other users of the same source.
 func construct() {
 	once.Do(func() {
-		updater = timing.NewScheduler()
-		l.Attach(nil, &currentInfo, "meminfo.currentInfo")
+		updater = sampler.New("/proc/meminfo", read)
 		l.Attach(nil, updater, "meminfo.updater")
 		updater.Every(3 * time.Second)
-		update()
-		go func(updater *timing.Scheduler) {
-			for range updater.C {
-				update()
-			}
-		}(updater)
 	})
 }
 
@@ -116,8 +106,8 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 
 // Stream subscribes to meminfo and updates the module's output accordingly.
 func (m *Module) Stream(s bar.Sink) {
-	i, err := currentInfo.Get()
-	nextInfo, done := currentInfo.Subscribe()
+	i, err := updater.Get()
+	nextInfo, done := updater.Subscribe()
 	defer done()
 	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
@@ -132,18 +122,18 @@ func (m *Module) Stream(s bar.Sink) {
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
 		case <-nextInfo:
-			i, err = currentInfo.Get()
+			i, err = updater.Get()
 		}
 	}
 }
 
 var fs = afero.NewOsFs()
 
-func update() {
+func read() (interface{}, error) {
 	info := make(Info)
 	f, err := fs.Open("/proc/meminfo")
-	if currentInfo.Error(err) {
-		return
+	if err != nil {
+		return nil, err
 	}
 	defer f.Close()
 	s := bufio.NewScanner(f)
@@ -167,5 +157,5 @@ func update() {
 			info[name] = unit.Datasize(intval) * mult
 		}
 	}
-	currentInfo.Set(info)
+	return info, nil
 }
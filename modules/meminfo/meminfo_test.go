@@ -22,7 +22,6 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
-	"github.com/leosunmo/barista/base/value"
 	"github.com/leosunmo/barista/format"
 	"github.com/leosunmo/barista/outputs"
 	testBar "github.com/leosunmo/barista/testing/bar"
@@ -45,13 +44,8 @@ func shouldReturn(t *testing.T, info meminfo) {
 }
 
 func resetForTest() {
-	currentInfo = &value.ErrorValue{}
 	once = sync.Once{}
 	construct()
-	// Flush upates for test.
-	n := currentInfo.Next()
-	update()
-	<-n
 }
 
 func TestMeminfo(t *testing.T) {
@@ -115,6 +109,11 @@ func TestMeminfo(t *testing.T) {
 	require.Equal(time.Minute, timing.Now().Sub(beforeTick), "RefreshInterval change")
 
 	testBar.LatestOutput().Expect("on tick after refresh interval change")
+
+	testBar.Tick()
+	now := timing.Now()
+	require.Equal(now.Truncate(time.Minute), now, "later ticks aligned to interval")
+	testBar.LatestOutput().Expect("on aligned tick")
 }
 
 func TestErrors(t *testing.T) {
@@ -0,0 +1,216 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package throttle provides a module that "wraps" an existing module and limits
+the rate at which its output is sent to the bar. This can be useful for
+modules that update many times a second (e.g. media position, netspeed, or
+shell.Tail), since each update causes the entire bar to be printed.
+
+By default, the last output of the wrapped module is always delivered, so the
+bar never shows stale content once the wrapped module stops updating. Click
+events are passed through to the wrapped module unchanged.
+
+For example, to show at most one media update every 2 seconds:
+
+	m := media.New("vlc")
+	t := throttle.New(m, 2*time.Second)
+
+Or to wait until a log file is quiet for half a second before updating, but
+still update at least every 5 seconds while it keeps changing:
+
+	s := shell.Tail("tail", "-f", "/var/log/messages")
+	t := throttle.New(s, 500*time.Millisecond).Debounce(5 * time.Second)
+*/
+package throttle
+
+import (
+	"sync"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/core"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/sink"
+	"github.com/leosunmo/barista/timing"
+)
+
+// Module wraps a bar.Module and rate-limits its output.
+type Module struct {
+	wrapped *core.Module
+
+	mu     sync.Mutex
+	config config
+}
+
+type config struct {
+	interval time.Duration
+	leading  bool
+	trailing bool
+	// maxWait is the longest output is delayed while debouncing, or zero
+	// to throttle instead.
+	maxWait time.Duration
+}
+
+// New wraps an existing bar.Module, sending at most one output to the bar
+// for each interval. By default the first output after a quiet period is
+// sent immediately, and any further outputs within the interval are
+// coalesced and the latest one sent at the end of the interval.
+func New(original bar.Module, interval time.Duration) *Module {
+	m := &Module{
+		wrapped: core.NewModule(original),
+		config: config{
+			interval: interval,
+			leading:  true,
+			trailing: true,
+		},
+	}
+	l.Label(m, l.ID(original))
+	return m
+}
+
+// Interval sets the minimum interval between outputs. It takes effect from
+// the next output of the wrapped module.
+func (m *Module) Interval(interval time.Duration) *Module {
+	l.Fine("%s.Interval(%v)", l.ID(m), interval)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config.interval = interval
+	return m
+}
+
+// Leading controls whether the first output after a quiet period is sent
+// immediately (true, the default), or delayed until the interval elapses.
+func (m *Module) Leading(leading bool) *Module {
+	l.Fine("%s.Leading(%v)", l.ID(m), leading)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config.leading = leading
+	return m
+}
+
+// Trailing controls whether output received during an interval is sent at
+// the end of the interval (true, the default). If false, intermediate output
+// is suppressed while the wrapped module keeps updating, and only its final
+// output is sent, once an interval passes without further updates.
+func (m *Module) Trailing(trailing bool) *Module {
+	l.Fine("%s.Trailing(%v)", l.ID(m), trailing)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config.trailing = trailing
+	return m
+}
+
+// Debounce switches from throttling to debouncing: each new output restarts
+// the interval, so output is only sent once the wrapped module has stopped
+// updating for the full interval. To avoid starving the bar while the
+// wrapped module updates continuously, output is never delayed for longer
+// than maxWait. A zero maxWait restores the default throttling.
+func (m *Module) Debounce(maxWait time.Duration) *Module {
+	l.Fine("%s.Debounce(%v)", l.ID(m), maxWait)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config.maxWait = maxWait
+	return m
+}
+
+func (m *Module) getConfig() config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config
+}
+
+// Stream starts the wrapped module and rate-limits its output.
+func (m *Module) Stream(s bar.Sink) {
+	outputs := make(chan bar.Segments)
+	go func() {
+		m.wrapped.Stream(sink.Func(func(o bar.Segments) { outputs <- o }))
+		close(outputs)
+	}()
+	m.throttle(s, outputs)
+}
+
+// throttle sends outputs to the sink, at most once per interval, until the
+// outputs channel is closed.
+func (m *Module) throttle(s bar.Sink, outputs <-chan bar.Segments) {
+	sch := timing.NewScheduler()
+	defer sch.Close()
+
+	// waiting is true while an interval is in progress, and pending
+	// tracks whether the latest output is yet to be sent to the bar.
+	waiting := false
+	pending := false
+	var out bar.Segments
+	// The time of the latest output, and of the first output that is yet
+	// to be sent, used to limit the delay when debouncing.
+	var lastUpdate, pendingSince time.Time
+
+	for {
+		select {
+		case o, ok := <-outputs:
+			c := m.getConfig()
+			if !ok {
+				if pending {
+					s.Output(out)
+				}
+				return
+			}
+			out = o
+			now := timing.Now()
+			lastUpdate = now
+			if !waiting {
+				waiting = true
+				sch.After(c.interval)
+				if c.leading {
+					s.Output(out)
+					continue
+				}
+			}
+			if !pending {
+				pending = true
+				pendingSince = now
+			}
+			if c.maxWait > 0 {
+				next := now.Add(c.interval)
+				if deadline := pendingSince.Add(c.maxWait); deadline.Before(next) {
+					next = deadline
+				}
+				sch.At(next)
+			}
+			l.Fine("%s: output delayed", l.ID(m))
+		case <-sch.C:
+			c := m.getConfig()
+			if !pending {
+				waiting = false
+				continue
+			}
+			quiet := timing.Now().Sub(lastUpdate)
+			if !c.trailing && quiet < c.interval {
+				// Still updating, so wait for the final output.
+				sch.After(c.interval - quiet)
+				continue
+			}
+			s.Output(out)
+			pending = false
+			// When the module has already been quiet for the full interval,
+			// the next output can be sent on the leading edge. Otherwise start
+			// a new interval to enforce the rate.
+			if (c.maxWait > 0 || !c.trailing) && quiet >= c.interval {
+				waiting = false
+			} else {
+				sch.After(c.interval)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leosunmo/barista/bar"
+	testBar "github.com/leosunmo/barista/testing/bar"
+	testModule "github.com/leosunmo/barista/testing/module"
+	"github.com/leosunmo/barista/timing"
+)
+
+func TestThrottle(t *testing.T) {
+	testBar.New(t)
+	original := testModule.New(t)
+	throttled := New(original, time.Second)
+	original.AssertNotStarted("on construction of throttled module")
+	testBar.Run(throttled)
+	original.AssertStarted("on stream of throttled module")
+
+	original.OutputText("a")
+	out := testBar.NextOutput("leading output")
+	out.AssertText([]string{"a"})
+
+	evt := bar.Event{Button: bar.ButtonLeft, Y: 1}
+	out.At(0).Click(evt)
+	recvEvt := original.AssertClicked("click events propagated")
+	require.Equal(t, evt, recvEvt, "click events passed through unchanged")
+
+	original.OutputText("b")
+	original.OutputText("c")
+	testBar.AssertNoOutput("within interval")
+
+	timing.AdvanceBy(500 * time.Millisecond)
+	testBar.AssertNoOutput("within interval")
+
+	timing.AdvanceBy(500 * time.Millisecond)
+	testBar.NextOutput("at end of interval").AssertText([]string{"c"})
+
+	original.OutputText("d")
+	testBar.AssertNoOutput("within interval after trailing output")
+
+	timing.AdvanceBy(time.Second)
+	testBar.NextOutput("at end of next interval").AssertText([]string{"d"})
+
+	timing.AdvanceBy(time.Second)
+	testBar.AssertNoOutput("when no updates in interval")
+
+	original.OutputText("e")
+	testBar.NextOutput("leading output after quiet period").
+		AssertText([]string{"e"})
+}
+
+func TestNoLeading(t *testing.T) {
+	testBar.New(t)
+	original := testModule.New(t)
+	throttled := New(original, time.Second).Leading(false)
+	testBar.Run(throttled)
+	original.AssertStarted()
+
+	original.OutputText("a")
+	testBar.AssertNoOutput("without leading output")
+
+	timing.AdvanceBy(time.Second)
+	testBar.NextOutput("at end of interval").AssertText([]string{"a"})
+}
+
+func TestNoTrailing(t *testing.T) {
+	testBar.New(t)
+	original := testModule.New(t)
+	throttled := New(original, time.Second).Trailing(false)
+	testBar.Run(throttled)
+	original.AssertStarted()
+
+	original.OutputText("a")
+	testBar.NextOutput("leading output").AssertText([]string{"a"})
+	timing.AdvanceBy(500 * time.Millisecond)
+	original.OutputText("b")
+	testBar.AssertNoOutput("within interval")
+	timing.AdvanceBy(500 * time.Millisecond)
+	testBar.AssertNoOutput("intermediate output suppressed")
+
+	original.OutputText("c")
+	testBar.AssertNoOutput("while updating")
+	timing.AdvanceBy(500 * time.Millisecond)
+	testBar.AssertNoOutput("while updating")
+	timing.AdvanceBy(500 * time.Millisecond)
+	testBar.NextOutput("final output when quiet").AssertText([]string{"c"})
+
+	original.OutputText("d")
+	testBar.NextOutput("leading output after quiet period").
+		AssertText([]string{"d"})
+
+	throttled.Leading(false)
+	timing.AdvanceBy(time.Second)
+	original.OutputText("e")
+	testBar.AssertNoOutput("without leading output")
+	timing.AdvanceBy(time.Second)
+	testBar.NextOutput("final output without leading output").
+		AssertText([]string{"e"})
+}
+
+func TestDebounce(t *testing.T) {
+	testBar.New(t)
+	original := testModule.New(t)
+	debounced := New(original, time.Second).Debounce(time.Hour)
+	testBar.Run(debounced)
+	original.AssertStarted()
+
+	original.OutputText("a")
+	testBar.NextOutput("leading output").AssertText([]string{"a"})
+
+	for _, txt := range []string{"b", "c", "d"} {
+		original.OutputText(txt)
+		testBar.AssertNoOutput("while updating")
+		timing.AdvanceBy(600 * time.Millisecond)
+		testBar.AssertNoOutput("while updating")
+	}
+
+	timing.AdvanceBy(400 * time.Millisecond)
+	testBar.NextOutput("when quiet").AssertText([]string{"d"})
+
+	original.OutputText("e")
+	testBar.NextOutput("leading output after quiet period").
+		AssertText([]string{"e"})
+
+	debounced.Leading(false).Interval(time.Minute)
+	timing.AdvanceBy(time.Second)
+	testBar.AssertNoOutput("when no updates in interval")
+	original.OutputText("f")
+	testBar.AssertNoOutput("without leading output")
+
+	timing.AdvanceBy(time.Minute)
+	testBar.NextOutput("at end of new interval").AssertText([]string{"f"})
+}
+
+func TestDebounceMaxWait(t *testing.T) {
+	testBar.New(t)
+	original := testModule.New(t)
+	debounced := New(original, time.Second).Debounce(2 * time.Second)
+	testBar.Run(debounced)
+	original.AssertStarted()
+
+	original.OutputText("a")
+	testBar.NextOutput("leading output").AssertText([]string{"a"})
+
+	start := timing.Now()
+	for _, txt := range []string{"b", "c", "d"} {
+		original.OutputText(txt)
+		testBar.AssertNoOutput("while updating")
+		timing.AdvanceBy(600 * time.Millisecond)
+	}
+	original.OutputText("e")
+	testBar.AssertNoOutput("while updating")
+	timing.AdvanceBy(200 * time.Millisecond)
+	testBar.NextOutput("at max wait while updating").AssertText([]string{"e"})
+	require.Equal(t, start.Add(2*time.Second), timing.Now())
+
+	timing.AdvanceBy(time.Second)
+	testBar.AssertNoOutput("when quiet without pending output")
+
+	debounced.Debounce(0)
+	original.OutputText("f")
+	testBar.NextOutput("leading output").AssertText([]string{"f"})
+	original.OutputText("g")
+	testBar.AssertNoOutput("throttled again")
+	timing.AdvanceBy(time.Second)
+	testBar.NextOutput("at end of interval").AssertText([]string{"g"})
+}
+
+func TestFinished(t *testing.T) {
+	testBar.New(t)
+	outputs := make(chan bar.Segments)
+	out := make(chan bar.Segments, 10)
+	done := make(chan struct{})
+	m := New(testModule.New(t), time.Second)
+	go func() {
+		m.throttle(func(o bar.Output) { out <- o.Segments() }, outputs)
+		close(done)
+	}()
+
+	text := func() string {
+		txt, _ := (<-out)[0].Content()
+		return txt
+	}
+	outputs <- bar.TextSegment("a").Segments()
+	require.Equal(t, "a", text())
+	outputs <- bar.TextSegment("b").Segments()
+	close(outputs)
+	<-done
+	require.Equal(t, "b", text(), "pending output flushed")
+}
@@ -74,7 +74,8 @@ func newWithSubscriber(subscriber func() *netlink.Subscription) *Module {
 	return m
 }
 
-// New constructs a netinfo module that scans all interfaces.
+// New constructs a netinfo module that scans all interfaces. If multiple
+// interfaces are connected, it shows the one carrying the default route.
 func New() *Module {
 	m := newWithSubscriber(netlink.Any)
 	l.Label(m, "*")
@@ -15,6 +15,7 @@
 package netinfo
 
 import (
+	"net"
 	"testing"
 
 	"github.com/leosunmo/barista/bar"
@@ -75,3 +76,27 @@ func TestNetinfo(t *testing.T) {
 	})
 	testBar.NextOutput().AssertText([]string{"6", "W:down", "E:eth1", "eth1"})
 }
+
+func TestDefaultRoute(t *testing.T) {
+	nlt := netlink.TestMode()
+	eth := nlt.AddLink(netlink.Link{Name: "eth0", State: netlink.Up})
+	wlan := nlt.AddLink(netlink.Link{Name: "wlan0", State: netlink.Up})
+
+	testBar.New(t)
+	n := New().Output(func(s State) bar.Output {
+		if s.Gateway() == nil {
+			return outputs.Text(s.Name)
+		}
+		return outputs.Textf("%s via %s", s.Name, s.Gateway())
+	})
+	testBar.Run(n)
+	testBar.LatestOutput().AssertText([]string{"eth0"})
+
+	nlt.AddRoute(wlan, netlink.Route{Gateway: net.IPv4(192, 168, 1, 1), Metric: 600})
+	testBar.LatestOutput().AssertText([]string{"wlan0 via 192.168.1.1"},
+		"when wifi has the only default route")
+
+	nlt.AddRoute(eth, netlink.Route{Gateway: net.IPv4(10, 0, 0, 1), Metric: 100})
+	testBar.LatestOutput().AssertText([]string{"eth0 via 10.0.0.1"},
+		"when ethernet has a better default route")
+}
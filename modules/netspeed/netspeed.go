@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
 	"github.com/leosunmo/barista/base/value"
 	"github.com/leosunmo/barista/format"
 	l "github.com/leosunmo/barista/logging"
@@ -52,18 +53,20 @@ func (s Speeds) Connected() bool {
 // format, click handler, and update frequency.
 type Module struct {
 	iface      string
-	scheduler  *timing.Scheduler
+	sampler    *sampler.Sampler
 	outputFunc value.Value // of func(Speeds) bar.Output
 }
 
 // New constructs an instance of the netspeed module for the given interface.
 func New(iface string) *Module {
 	m := &Module{
-		iface:     iface,
-		scheduler: timing.NewScheduler(),
+		iface: iface,
+		sampler: sampler.New("netlink:"+iface, func() (interface{}, error) {
+			return readLinkStats(iface)
+		}),
 	}
 	l.Label(m, iface)
-	l.Register(m, "scheduler", "outputFunc")
+	l.Register(m, "sampler", "outputFunc")
 	m.RefreshInterval(3 * time.Second)
 	// Default output is just the up and down speeds in SI.
 	m.Output(func(s Speeds) bar.Output {
@@ -83,7 +86,7 @@ func (m *Module) Output(outputFunc func(Speeds) bar.Output) *Module {
 // Since there is no concept of an instantaneous network speed, the speeds will
 // be averaged over this interval before being displayed.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
-	m.scheduler.Every(interval)
+	m.sampler.Every(interval)
 	return m
 }
 
@@ -92,11 +95,14 @@ var linkByName = netlink.LinkByName
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	lastRead := timing.Now()
-	lastRx, lastTx, _, err := linkRxTxState(m.iface)
+	m.sampler.Refresh()
+	sample, err := m.sampler.Get()
 	if s.Error(err) {
 		return
 	}
+	last := sample.(linkStats)
+	nextSample, done := m.sampler.Subscribe()
+	defer done()
 
 	var speeds Speeds
 	outputFunc := m.outputFunc.Get().(func(Speeds) bar.Output)
@@ -110,35 +116,48 @@ func (m *Module) Stream(s bar.Sink) {
 		select {
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Speeds) bar.Output)
-		case <-m.scheduler.C:
-			rx, tx, state, err := linkRxTxState(m.iface)
+		case <-nextSample:
+			sample, err := m.sampler.Get()
 			if s.Error(err) {
 				return
 			}
-			now := timing.Now()
-			duration := now.Sub(lastRead).Seconds()
+			stats := sample.(linkStats)
+			duration := stats.time.Sub(last.time).Seconds()
+			if duration <= 0 {
+				// Refreshed out of schedule, e.g. by another instance for
+				// the same interface, so there's no interval to compute
+				// rates over.
+				last = stats
+				continue
+			}
 
 			speeds.available = true
-			speeds.Rx = unit.Datarate(float64(rx-lastRx)/duration) * unit.BytePerSecond
-			speeds.Tx = unit.Datarate(float64(tx-lastTx)/duration) * unit.BytePerSecond
-			speeds.state = state
+			speeds.Rx = unit.Datarate(float64(stats.rx-last.rx)/duration) * unit.BytePerSecond
+			speeds.Tx = unit.Datarate(float64(stats.tx-last.tx)/duration) * unit.BytePerSecond
+			speeds.state = stats.state
 
-			lastRead = now
-			lastRx = rx
-			lastTx = tx
+			last = stats
 		}
 	}
 }
 
-func linkRxTxState(iface string) (rx, tx uint64, state netlink.LinkOperState, err error) {
-	var link netlink.Link
-	link, err = linkByName(iface)
+// linkStats is a single sample of a link's counters.
+type linkStats struct {
+	rx, tx uint64
+	state  netlink.LinkOperState
+	time   time.Time
+}
+
+func readLinkStats(iface string) (interface{}, error) {
+	link, err := linkByName(iface)
 	if err != nil {
-		return
+		return nil, err
 	}
-	state = link.Attrs().OperState
-	linkStats := link.Attrs().Statistics
-	rx = linkStats.RxBytes
-	tx = linkStats.TxBytes
-	return
+	stats := link.Attrs().Statistics
+	return linkStats{
+		rx:    stats.RxBytes,
+		tx:    stats.TxBytes,
+		state: link.Attrs().OperState,
+		time:  timing.Now(),
+	}, nil
 }
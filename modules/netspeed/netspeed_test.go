@@ -138,6 +138,11 @@ func TestNetspeed(t *testing.T) {
 	require.Equal(time.Minute, timing.Now().Sub(beforeTick),
 		"RefreshInterval change")
 	testBar.NextOutput().Expect("RefreshInterval change")
+
+	testBar.Tick()
+	now := timing.Now()
+	require.Equal(now.Truncate(time.Minute), now, "later ticks aligned to interval")
+	testBar.NextOutput().Expect("on aligned tick")
 }
 
 func TestConnectedState(t *testing.T) {
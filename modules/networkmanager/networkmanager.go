@@ -0,0 +1,420 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package networkmanager provides an i3bar module that displays the state
+// of NetworkManager over D-Bus, and can activate or deactivate connections.
+package networkmanager
+
+import (
+	"fmt"
+
+	godbus "github.com/godbus/dbus/v5"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/notifier"
+	"github.com/leosunmo/barista/base/value"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+)
+
+const (
+	nmService     = "org.freedesktop.NetworkManager"
+	nmPath        = "/org/freedesktop/NetworkManager"
+	nmIface       = "org.freedesktop.NetworkManager"
+	activeIface   = nmIface + ".Connection.Active"
+	apIface       = nmIface + ".AccessPoint"
+	settingsPath  = nmPath + "/Settings"
+	settingsIface = nmIface + ".Settings"
+	connIface     = settingsIface + ".Connection"
+	noObject      = godbus.ObjectPath("/")
+	wirelessType  = "802-11-wireless"
+	vpnType       = "vpn"
+	wireguardType = "wireguard"
+)
+
+// Replaced in tests.
+var busType = dbus.System
+
+// Connectivity represents the network connectivity as determined by
+// NetworkManager's connectivity checks.
+type Connectivity uint32
+
+// Connectivity states, from the NetworkManager documentation.
+const (
+	ConnectivityUnknown Connectivity = iota
+	ConnectivityNone
+	ConnectivityPortal
+	ConnectivityLimited
+	ConnectivityFull
+)
+
+func (c Connectivity) String() string {
+	switch c {
+	case ConnectivityNone:
+		return "none"
+	case ConnectivityPortal:
+		return "portal"
+	case ConnectivityLimited:
+		return "limited"
+	case ConnectivityFull:
+		return "full"
+	}
+	return "unknown"
+}
+
+// ConnectionState represents the state of an active connection.
+type ConnectionState uint32
+
+// Active connection states, from the NetworkManager documentation.
+const (
+	Unknown ConnectionState = iota
+	Activating
+	Activated
+	Deactivating
+	Deactivated
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case Activating:
+		return "activating"
+	case Activated:
+		return "activated"
+	case Deactivating:
+		return "deactivating"
+	case Deactivated:
+		return "deactivated"
+	}
+	return "unknown"
+}
+
+// AccessPoint represents a wifi access point.
+type AccessPoint struct {
+	SSID string
+	// Strength of the signal, in percent.
+	Strength int
+	// Frequency in MHz.
+	Frequency int
+}
+
+// Connection represents an active NetworkManager connection.
+type Connection struct {
+	ID   string
+	UUID string
+	// Type of the connection, e.g. "802-3-ethernet", "802-11-wireless", "vpn".
+	Type  string
+	VPN   bool
+	State ConnectionState
+	// AccessPoint is only set for wifi connections.
+	AccessPoint AccessPoint
+
+	path godbus.ObjectPath
+	call func(string, ...interface{}) ([]interface{}, error)
+}
+
+// Wireless returns true if the connection is a wifi connection.
+func (c Connection) Wireless() bool {
+	return c.Type == wirelessType
+}
+
+// Deactivate deactivates the connection.
+func (c Connection) Deactivate() error {
+	if c.call == nil {
+		return fmt.Errorf("connection %q is not active", c.ID)
+	}
+	_, err := c.call("DeactivateConnection", c.path)
+	return err
+}
+
+// Info represents the current state of NetworkManager.
+type Info struct {
+	Connectivity            Connectivity
+	NetworkingEnabled       bool
+	WirelessEnabled         bool
+	WirelessHardwareEnabled bool
+	// Primary is the connection that has the default route, or the zero
+	// value if there is none.
+	Primary Connection
+	// Connections are all active connections, including VPNs.
+	Connections []Connection
+
+	call func(string, ...interface{}) ([]interface{}, error)
+}
+
+// Connected returns true if there is a primary connection.
+func (i Info) Connected() bool {
+	return i.Primary.ID != ""
+}
+
+// Online returns true if the system has full internet access.
+func (i Info) Online() bool {
+	return i.Connectivity == ConnectivityFull
+}
+
+// VPNs returns the active VPN connections.
+func (i Info) VPNs() []Connection {
+	var vpns []Connection
+	for _, c := range i.Connections {
+		if c.VPN {
+			vpns = append(vpns, c)
+		}
+	}
+	return vpns
+}
+
+// Activate activates the saved connection with the given name or UUID.
+func (i Info) Activate(name string) error {
+	if i.call == nil {
+		return fmt.Errorf("NetworkManager is not running")
+	}
+	path, err := findConnection(name)
+	if err != nil {
+		return err
+	}
+	_, err = i.call("ActivateConnection", path, noObject, noObject)
+	return err
+}
+
+// Deactivate deactivates the active connection with the given name or UUID.
+func (i Info) Deactivate(name string) error {
+	for _, c := range i.Connections {
+		if c.ID == name || c.UUID == name {
+			return c.Deactivate()
+		}
+	}
+	return fmt.Errorf("connection %q is not active", name)
+}
+
+// ToggleWireless enables or disables wifi.
+func (i Info) ToggleWireless() error {
+	return i.setProperty("WirelessEnabled", !i.WirelessEnabled)
+}
+
+// ToggleNetworking enables or disables all networking.
+func (i Info) ToggleNetworking() error {
+	if i.call == nil {
+		return fmt.Errorf("NetworkManager is not running")
+	}
+	_, err := i.call("Enable", !i.NetworkingEnabled)
+	return err
+}
+
+func (i Info) setProperty(name string, val interface{}) error {
+	if i.call == nil {
+		return fmt.Errorf("NetworkManager is not running")
+	}
+	_, err := i.call(dbus.PropertiesSet, nmIface, name, godbus.MakeVariant(val))
+	return err
+}
+
+// findConnection returns the path of the saved connection with the given
+// name or UUID.
+func findConnection(name string) (godbus.ObjectPath, error) {
+	settings := dbus.WatchProperties(busType, nmService, settingsPath, settingsIface)
+	defer settings.Unsubscribe()
+	res, err := settings.Call("ListConnections")
+	if err != nil {
+		return "", err
+	}
+	var paths []godbus.ObjectPath
+	if len(res) > 0 {
+		paths, _ = res[0].([]godbus.ObjectPath)
+	}
+	for _, path := range paths {
+		conn := dbus.WatchProperties(busType, nmService, string(path), connIface)
+		res, err := conn.Call("GetSettings")
+		conn.Unsubscribe()
+		if err != nil || len(res) == 0 {
+			l.Log("Failed to get settings for %s: %v", path, err)
+			continue
+		}
+		s, _ := res[0].(map[string]map[string]godbus.Variant)
+		id, _ := s["connection"]["id"].Value().(string)
+		uuid, _ := s["connection"]["uuid"].Value().(string)
+		if id == name || uuid == name {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no saved connection %q", name)
+}
+
+// Module represents a NetworkManager bar module.
+type Module struct {
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a new NetworkManager module.
+func New() *Module {
+	m := new(Module)
+	l.Register(m, "outputFunc")
+	// Default output is the name of the primary connection, with the
+	// signal strength for wifi connections.
+	m.Output(func(i Info) bar.Output {
+		switch {
+		case !i.Connected():
+			return nil
+		case i.Primary.Wireless():
+			return outputs.Textf("%s (%d%%)", i.Primary.ID, i.Primary.AccessPoint.Strength)
+		}
+		return outputs.Text(i.Primary.ID)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	notifyFn, updates := notifier.New()
+	objs := &watchers{notify: notifyFn, all: map[string]*watcher{}}
+	defer objs.close()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	info := getInfo(objs)
+	for {
+		s.Output(outputFunc(info))
+		select {
+		case <-updates:
+			info = getInfo(objs)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func getInfo(objs *watchers) Info {
+	defer objs.sweep()
+	nm := objs.get(nmPath, nmIface,
+		"PrimaryConnection", "ActiveConnections", "Connectivity",
+		"NetworkingEnabled", "WirelessEnabled", "WirelessHardwareEnabled")
+	props := nm.Get()
+	info := Info{call: nm.Call}
+	if c, ok := props["Connectivity"].(uint32); ok {
+		info.Connectivity = Connectivity(c)
+	}
+	info.NetworkingEnabled, _ = props["NetworkingEnabled"].(bool)
+	info.WirelessEnabled, _ = props["WirelessEnabled"].(bool)
+	info.WirelessHardwareEnabled, _ = props["WirelessHardwareEnabled"].(bool)
+	primary, _ := props["PrimaryConnection"].(godbus.ObjectPath)
+	active, _ := props["ActiveConnections"].([]godbus.ObjectPath)
+	for _, path := range active {
+		c := getConnection(path, objs)
+		c.call = nm.Call
+		if path == primary {
+			info.Primary = c
+		}
+		info.Connections = append(info.Connections, c)
+	}
+	return info
+}
+
+func getConnection(path godbus.ObjectPath, objs *watchers) Connection {
+	props := objs.get(string(path), activeIface,
+		"Id", "Uuid", "Type", "Vpn", "State", "SpecificObject").Get()
+	c := Connection{path: path}
+	c.ID, _ = props["Id"].(string)
+	c.UUID, _ = props["Uuid"].(string)
+	c.Type, _ = props["Type"].(string)
+	c.VPN, _ = props["Vpn"].(bool)
+	c.VPN = c.VPN || c.Type == vpnType || c.Type == wireguardType
+	if s, ok := props["State"].(uint32); ok {
+		c.State = ConnectionState(s)
+	}
+	ap, _ := props["SpecificObject"].(godbus.ObjectPath)
+	if !c.Wireless() || ap == "" || ap == noObject {
+		return c
+	}
+	props = objs.get(string(ap), apIface, "Ssid", "Strength", "Frequency").Get()
+	ssid, _ := props["Ssid"].([]byte)
+	c.AccessPoint.SSID = string(ssid)
+	if s, ok := props["Strength"].(byte); ok {
+		c.AccessPoint.Strength = int(s)
+	}
+	if f, ok := props["Frequency"].(uint32); ok {
+		c.AccessPoint.Frequency = int(f)
+	}
+	return c
+}
+
+// watcher is a properties watcher for a single D-Bus object, which forwards
+// its updates to the module until closed.
+type watcher struct {
+	*dbus.PropertiesWatcher
+	used bool
+	done chan struct{}
+}
+
+// watchers tracks the D-Bus objects used for the most recent info, so that
+// watchers for connections and access points that are no longer active can
+// be closed.
+type watchers struct {
+	notify func()
+	all    map[string]*watcher
+}
+
+// get returns a watcher for the given object, creating it if needed.
+func (o *watchers) get(path, iface string, props ...string) *dbus.PropertiesWatcher {
+	key := path + "#" + iface
+	if w, ok := o.all[key]; ok {
+		w.used = true
+		return w.PropertiesWatcher
+	}
+	w := &watcher{
+		PropertiesWatcher: dbus.WatchProperties(busType, nmService, path, iface).Add(props...),
+		used:              true,
+		done:              make(chan struct{}),
+	}
+	go func() {
+		for {
+			select {
+			case <-w.Updates:
+				o.notify()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	o.all[key] = w
+	return w.PropertiesWatcher
+}
+
+// sweep closes all watchers that were not used since the previous sweep.
+func (o *watchers) sweep() {
+	for key, w := range o.all {
+		if !w.used {
+			w.close()
+			delete(o.all, key)
+		}
+		w.used = false
+	}
+}
+
+func (o *watchers) close() {
+	for _, w := range o.all {
+		w.close()
+	}
+}
+
+func (w *watcher) close() {
+	w.Unsubscribe()
+	close(w.done)
+}
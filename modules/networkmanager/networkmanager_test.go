@@ -0,0 +1,235 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkmanager
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+)
+
+func init() {
+	busType = dbus.Test
+}
+
+const (
+	wifiPath = nmPath + "/ActiveConnection/1"
+	vpnPath  = nmPath + "/ActiveConnection/2"
+	apPath   = nmPath + "/AccessPoint/7"
+)
+
+func setupTestNM() (svc *dbus.TestBusService, nm, wifi, ap *dbus.TestBusObject) {
+	bus := dbus.SetupTestBus()
+	svc = bus.RegisterService(nmService)
+	nm = svc.Object(nmPath, nmIface)
+	nm.SetProperties(map[string]interface{}{
+		"PrimaryConnection":       godbus.ObjectPath(wifiPath),
+		"ActiveConnections":       []godbus.ObjectPath{wifiPath},
+		"Connectivity":            uint32(ConnectivityFull),
+		"NetworkingEnabled":       true,
+		"WirelessEnabled":         true,
+		"WirelessHardwareEnabled": true,
+	}, dbus.SignalTypeNone)
+	wifi = svc.Object(wifiPath, activeIface)
+	wifi.SetProperties(map[string]interface{}{
+		"Id":             "Home",
+		"Uuid":           "1111",
+		"Type":           wirelessType,
+		"Vpn":            false,
+		"State":          uint32(Activated),
+		"SpecificObject": godbus.ObjectPath(apPath),
+	}, dbus.SignalTypeNone)
+	ap = svc.Object(apPath, apIface)
+	ap.SetProperties(map[string]interface{}{
+		"Ssid":      []byte("home-ssid"),
+		"Strength":  byte(70),
+		"Frequency": uint32(5180),
+	}, dbus.SignalTypeNone)
+	return svc, nm, wifi, ap
+}
+
+func describe(i Info) bar.Output {
+	var parts []string
+	for _, c := range i.Connections {
+		desc := fmt.Sprintf("%s:%s", c.ID, c.State)
+		if c.Wireless() {
+			desc += fmt.Sprintf("[%s %d%% %dMHz]",
+				c.AccessPoint.SSID, c.AccessPoint.Strength, c.AccessPoint.Frequency)
+		}
+		parts = append(parts, desc)
+	}
+	return outputs.Textf("%s %s vpns=%d %s",
+		i.Primary.ID, i.Connectivity, len(i.VPNs()), strings.Join(parts, ","))
+}
+
+func TestNetworkManager(t *testing.T) {
+	testBar.New(t)
+	svc, nm, wifi, ap := setupTestNM()
+
+	testBar.Run(New().Output(describe))
+	testBar.NextOutput("on start").AssertText([]string{
+		"Home full vpns=0 Home:activated[home-ssid 70% 5180MHz]"})
+
+	ap.SetPropertyForTest("Strength", byte(45), dbus.SignalTypeChanged)
+	testBar.NextOutput("on strength change").AssertText([]string{
+		"Home full vpns=0 Home:activated[home-ssid 45% 5180MHz]"})
+
+	nm.SetPropertyForTest("Connectivity", uint32(ConnectivityPortal), dbus.SignalTypeChanged)
+	testBar.NextOutput("on connectivity change").AssertText([]string{
+		"Home portal vpns=0 Home:activated[home-ssid 45% 5180MHz]"})
+
+	vpn := svc.Object(vpnPath, activeIface)
+	vpn.SetProperties(map[string]interface{}{
+		"Id":             "Work",
+		"Uuid":           "2222",
+		"Type":           vpnType,
+		"Vpn":            true,
+		"State":          uint32(Activating),
+		"SpecificObject": godbus.ObjectPath("/"),
+	}, dbus.SignalTypeNone)
+	nm.SetPropertyForTest("ActiveConnections",
+		[]godbus.ObjectPath{wifiPath, vpnPath}, dbus.SignalTypeChanged)
+	testBar.NextOutput("on vpn added").AssertText([]string{
+		"Home portal vpns=1 Home:activated[home-ssid 45% 5180MHz],Work:activating"})
+
+	vpn.SetPropertyForTest("State", uint32(Activated), dbus.SignalTypeChanged)
+	testBar.NextOutput("on vpn activated").AssertText([]string{
+		"Home portal vpns=1 Home:activated[home-ssid 45% 5180MHz],Work:activated"})
+
+	wifi.SetPropertyForTest("State", uint32(Deactivating), dbus.SignalTypeChanged)
+	testBar.NextOutput("on wifi deactivating").AssertText([]string{
+		"Home portal vpns=1 Home:deactivating[home-ssid 45% 5180MHz],Work:activated"})
+
+	nm.SetProperties(map[string]interface{}{
+		"PrimaryConnection": godbus.ObjectPath("/"),
+		"ActiveConnections": []godbus.ObjectPath{},
+		"Connectivity":      uint32(ConnectivityNone),
+	}, dbus.SignalTypeChanged)
+	testBar.NextOutput("on disconnect").AssertText([]string{" none vpns=0 "})
+
+	ap.SetPropertyForTest("Strength", byte(20), dbus.SignalTypeChanged)
+	testBar.AssertNoOutput("for inactive access point")
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	svc, nm, _, _ := setupTestNM()
+	eth := svc.Object(vpnPath, activeIface)
+	eth.SetProperties(map[string]interface{}{
+		"Id":    "Wired",
+		"Type":  "802-3-ethernet",
+		"State": uint32(Activated),
+	}, dbus.SignalTypeNone)
+
+	testBar.Run(New())
+	testBar.NextOutput("on start").AssertText([]string{"Home (70%)"})
+
+	nm.SetProperties(map[string]interface{}{
+		"PrimaryConnection": godbus.ObjectPath(vpnPath),
+		"ActiveConnections": []godbus.ObjectPath{wifiPath, vpnPath},
+	}, dbus.SignalTypeChanged)
+	testBar.NextOutput("on primary change").AssertText([]string{"Wired"})
+
+	nm.SetProperties(map[string]interface{}{
+		"PrimaryConnection": godbus.ObjectPath("/"),
+		"ActiveConnections": []godbus.ObjectPath{},
+	}, dbus.SignalTypeChanged)
+	testBar.NextOutput("on disconnect").AssertEmpty()
+}
+
+func TestActions(t *testing.T) {
+	testBar.New(t)
+	svc, nm, _, _ := setupTestNM()
+
+	calls := make(chan string, 10)
+	record := func(method string) func(...interface{}) ([]interface{}, error) {
+		return func(args ...interface{}) ([]interface{}, error) {
+			calls <- fmt.Sprint(method, args)
+			return nil, nil
+		}
+	}
+	nm.On("ActivateConnection", record("activate"))
+	nm.On("DeactivateConnection", record("deactivate"))
+	nm.On("Enable", record("enable"))
+	nm.On(dbus.PropertiesSet, func(args ...interface{}) ([]interface{}, error) {
+		calls <- fmt.Sprint("set", args[:2], args[2].(godbus.Variant).Value())
+		return nil, nil
+	})
+
+	settings := svc.Object(settingsPath, settingsIface)
+	settings.On("ListConnections", func(...interface{}) ([]interface{}, error) {
+		return []interface{}{[]godbus.ObjectPath{
+			settingsPath + "/1", settingsPath + "/2",
+		}}, nil
+	})
+	for idx, id := range []string{"Home", "Work"} {
+		id, uuid := id, fmt.Sprintf("uuid-%d", idx+1)
+		conn := svc.Object(godbus.ObjectPath(fmt.Sprintf("%s/%d", settingsPath, idx+1)), connIface)
+		conn.On("GetSettings", func(...interface{}) ([]interface{}, error) {
+			return []interface{}{map[string]map[string]godbus.Variant{
+				"connection": {
+					"id":   godbus.MakeVariant(id),
+					"uuid": godbus.MakeVariant(uuid),
+				},
+			}}, nil
+		})
+	}
+
+	var info Info
+	testBar.Run(New().Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Text(i.Primary.ID)
+	}))
+	testBar.NextOutput("on start").AssertText([]string{"Home"})
+
+	assertCall := func(expected string) {
+		t.Helper()
+		select {
+		case call := <-calls:
+			require.Equal(t, expected, call)
+		case <-time.After(time.Second):
+			require.Fail(t, "method not called", expected)
+		}
+	}
+
+	require.NoError(t, info.Activate("Work"))
+	assertCall("activate[/org/freedesktop/NetworkManager/Settings/2 / /]")
+	require.NoError(t, info.Activate("uuid-1"))
+	assertCall("activate[/org/freedesktop/NetworkManager/Settings/1 / /]")
+	require.Error(t, info.Activate("Cafe"))
+
+	require.NoError(t, info.Deactivate("Home"))
+	assertCall("deactivate[" + wifiPath + "]")
+	require.Error(t, info.Deactivate("Work"), "not active")
+	require.NoError(t, info.Primary.Deactivate())
+	assertCall("deactivate[" + wifiPath + "]")
+
+	require.NoError(t, info.ToggleWireless())
+	assertCall("set[org.freedesktop.NetworkManager WirelessEnabled] false")
+	require.NoError(t, info.ToggleNetworking())
+	assertCall("enable[false]")
+
+	require.Error(t, Info{}.ToggleWireless())
+	require.Error(t, Info{}.Activate("Home"))
+}
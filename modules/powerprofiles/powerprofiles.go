@@ -0,0 +1,170 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package powerprofiles provides an i3bar module that shows and switches the
+// power profile using power-profiles-daemon.
+package powerprofiles
+
+import (
+	"errors"
+
+	godbus "github.com/godbus/dbus/v5"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/value"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+)
+
+// Profiles supported by power-profiles-daemon. Performance is not available
+// on all systems.
+const (
+	PowerSaver  = "power-saver"
+	Balanced    = "balanced"
+	Performance = "performance"
+)
+
+// Info represents the state of power-profiles-daemon.
+type Info struct {
+	// ActiveProfile is the current profile, or empty if power-profiles-daemon
+	// is not running.
+	ActiveProfile string
+	// PerformanceDegraded is the reason the performance profile is running
+	// in a degraded mode, e.g. "lap-detected" or "high-operating-temperature".
+	// It is empty if performance is not degraded.
+	PerformanceDegraded string
+	// Profiles is the list of available profiles, from lowest to highest
+	// performance.
+	Profiles []string
+	call     func(string, ...interface{}) ([]interface{}, error)
+}
+
+// Connected returns true if power-profiles-daemon is running.
+func (i Info) Connected() bool {
+	return i.ActiveProfile != ""
+}
+
+// Degraded returns true if the performance profile is degraded.
+func (i Info) Degraded() bool {
+	return i.PerformanceDegraded != ""
+}
+
+// SetProfile changes the active profile.
+func (i Info) SetProfile(profile string) error {
+	if i.call == nil {
+		return errors.New("power-profiles-daemon is not running")
+	}
+	_, err := i.call(dbus.PropertiesSet, ppdIface, "ActiveProfile", godbus.MakeVariant(profile))
+	return err
+}
+
+// Cycle switches to the next available profile, wrapping around from the
+// highest performance profile to the lowest.
+func (i Info) Cycle() error {
+	if len(i.Profiles) == 0 {
+		return errors.New("no power profiles available")
+	}
+	next := i.Profiles[0]
+	for idx, p := range i.Profiles {
+		if p == i.ActiveProfile && idx+1 < len(i.Profiles) {
+			next = i.Profiles[idx+1]
+		}
+	}
+	return i.SetProfile(next)
+}
+
+var busType = dbus.System
+
+const (
+	ppdService = "net.hadess.PowerProfiles"
+	ppdPath    = "/net/hadess/PowerProfiles"
+	ppdIface   = "net.hadess.PowerProfiles"
+)
+
+// Module represents a power-profiles-daemon bar module.
+type Module struct {
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a new power profiles module.
+func New() *Module {
+	m := new(Module)
+	l.Register(m, "outputFunc")
+	// Default output is the active profile, and the reason if degraded.
+	m.Output(func(i Info) bar.Output {
+		if !i.Connected() {
+			return nil
+		}
+		if i.Degraded() {
+			return outputs.Textf("%s (%s)", i.ActiveProfile, i.PerformanceDegraded)
+		}
+		return outputs.Text(i.ActiveProfile)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// defaultClickHandler cycles through the profiles on left click.
+func defaultClickHandler(i Info) func(bar.Event) {
+	return func(e bar.Event) {
+		if e.Button != bar.ButtonLeft {
+			return
+		}
+		if err := i.Cycle(); err != nil {
+			l.Log("Error changing power profile: %v", err)
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	w := dbus.WatchProperties(busType, ppdService, ppdPath, ppdIface).
+		Add("ActiveProfile", "PerformanceDegraded", "Profiles")
+	defer w.Unsubscribe()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	info := getInfo(w)
+	for {
+		s.Output(outputs.Group(outputFunc(info)).OnClick(defaultClickHandler(info)))
+		select {
+		case <-w.Updates:
+			info = getInfo(w)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func getInfo(w *dbus.PropertiesWatcher) Info {
+	props := w.Get()
+	i := Info{call: w.Call}
+	i.ActiveProfile, _ = props["ActiveProfile"].(string)
+	i.PerformanceDegraded, _ = props["PerformanceDegraded"].(string)
+	profiles, _ := props["Profiles"].([]map[string]godbus.Variant)
+	for _, p := range profiles {
+		if name, ok := p["Profile"].Value().(string); ok {
+			i.Profiles = append(i.Profiles, name)
+		}
+	}
+	return i
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package powerprofiles
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+)
+
+func init() {
+	busType = dbus.Test
+}
+
+func profiles(names ...string) []map[string]godbus.Variant {
+	var ps []map[string]godbus.Variant
+	for _, n := range names {
+		ps = append(ps, map[string]godbus.Variant{
+			"Profile": godbus.MakeVariant(n),
+			"Driver":  godbus.MakeVariant("platform_profile"),
+		})
+	}
+	return ps
+}
+
+func setupTestPPD() (*dbus.TestBusService, *dbus.TestBusObject) {
+	bus := dbus.SetupTestBus()
+	svc := bus.RegisterService(ppdService)
+	obj := svc.Object(ppdPath, ppdIface)
+	obj.SetProperties(map[string]interface{}{
+		"ActiveProfile":       Balanced,
+		"PerformanceDegraded": "",
+		"Profiles":            profiles(PowerSaver, Balanced, Performance),
+	}, dbus.SignalTypeNone)
+	obj.On(dbus.PropertiesSet, func(args ...interface{}) ([]interface{}, error) {
+		if args[0] != ppdIface || args[1] != "ActiveProfile" {
+			return nil, errors.New("unexpected property")
+		}
+		// The object is locked while handling calls.
+		go obj.SetPropertyForTest("ActiveProfile",
+			args[2].(godbus.Variant).Value(), dbus.SignalTypeChanged)
+		return nil, nil
+	})
+	return svc, obj
+}
+
+func TestPowerProfiles(t *testing.T) {
+	testBar.New(t)
+	svc, obj := setupTestPPD()
+
+	m := New()
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"balanced"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"performance"})
+
+	obj.SetPropertyForTest("PerformanceDegraded", "lap-detected", dbus.SignalTypeChanged)
+	out = testBar.NextOutput("on degraded")
+	out.AssertText([]string{"performance (lap-detected)"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click wrapping around")
+	out.AssertText([]string{"power-saver (lap-detected)"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.AssertNoOutput("on scroll")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %v %s", i.ActiveProfile, i.Degraded(),
+			strings.Join(i.Profiles, ","))
+	})
+	testBar.NextOutput("on output change").AssertText(
+		[]string{"power-saver true power-saver,balanced,performance"})
+
+	svc.Unregister()
+	testBar.NextOutput("on disconnect").AssertText([]string{" false "})
+}
+
+func TestDefaultOutputDisconnected(t *testing.T) {
+	testBar.New(t)
+	dbus.SetupTestBus()
+	testBar.Run(New())
+	testBar.NextOutput("without daemon").AssertEmpty()
+}
+
+func TestInfo(t *testing.T) {
+	testBar.New(t)
+	_, obj := setupTestPPD()
+	obj.SetProperties(map[string]interface{}{
+		"ActiveProfile": PowerSaver,
+		"Profiles":      profiles(PowerSaver, Balanced),
+	}, dbus.SignalTypeNone)
+
+	var info Info
+	testBar.Run(New().Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Text(i.ActiveProfile)
+	}))
+	testBar.NextOutput("on start").AssertText([]string{"power-saver"})
+	require.True(t, info.Connected())
+	require.False(t, info.Degraded())
+
+	require.NoError(t, info.Cycle())
+	testBar.NextOutput("on cycle").AssertText([]string{"balanced"})
+
+	require.NoError(t, info.SetProfile(PowerSaver))
+	testBar.NextOutput("on set").AssertText([]string{"power-saver"})
+
+	require.Error(t, Info{}.Cycle())
+	require.Error(t, Info{Profiles: []string{Balanced}}.SetProfile(Balanced))
+}
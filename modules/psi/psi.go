@@ -0,0 +1,273 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package psi implements an i3bar module that shows Pressure Stall
+// Information, i.e. the share of time tasks were stalled waiting for CPU,
+// memory, or IO, from /proc/pressure or a cgroup v2 *.pressure file.
+package psi
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
+	"github.com/leosunmo/barista/base/value"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+
+	"github.com/spf13/afero"
+)
+
+// Resource is a resource that tasks can be stalled on.
+type Resource string
+
+// Resources tracked by PSI.
+const (
+	CPU    Resource = "cpu"
+	Memory Resource = "memory"
+	IO     Resource = "io"
+)
+
+// Stall represents the share of time that tasks were stalled.
+type Stall struct {
+	// Avg10, Avg60, and Avg300 are the percentage of time stalled over the
+	// last 10 seconds, 1 minute, and 5 minutes.
+	Avg10, Avg60, Avg300 float64
+	// Total is the total time stalled.
+	Total time.Duration
+}
+
+// Info represents the pressure on a single resource.
+type Info struct {
+	Resource Resource
+	// Some is the time at least some tasks were stalled.
+	Some Stall
+	// Full is the time all non-idle tasks were stalled at once. It is always
+	// zero for CPU pressure at the system level.
+	Full Stall
+}
+
+// trigger is a PSI trigger: a threshold of stall time within a window.
+type trigger struct {
+	full          bool
+	stall, window time.Duration
+}
+
+// spec returns the string written to the pressure file to create the trigger.
+func (t trigger) spec() string {
+	kind := "some"
+	if t.full {
+		kind = "full"
+	}
+	return fmt.Sprintf("%s %d %d", kind, t.stall.Microseconds(), t.window.Microseconds())
+}
+
+// Module represents a PSI bar module.
+type Module struct {
+	file       string
+	resource   Resource
+	sampler    *sampler.Sampler
+	outputFunc value.Value // of func(Info) bar.Output
+	triggers   value.Value // of []trigger
+}
+
+// New constructs a module for system-wide pressure on the given resource.
+func New(res Resource) *Module {
+	return newModule("/proc/pressure/"+string(res), res)
+}
+
+// Cgroup constructs a module for pressure on the given resource within a
+// cgroup, given by its path relative to the cgroup v2 root, e.g.
+// "user.slice/user-1000.slice".
+func Cgroup(cgroup string, res Resource) *Module {
+	return newModule(path.Join("/sys/fs/cgroup", cgroup, string(res)+".pressure"), res)
+}
+
+func newModule(file string, res Resource) *Module {
+	m := &Module{file: file, resource: res}
+	m.sampler = sampler.New(file, func() (interface{}, error) {
+		return read(file, res)
+	})
+	l.Label(m, file)
+	l.Register(m, "sampler", "outputFunc", "triggers")
+	m.triggers.Set([]trigger(nil))
+	m.RefreshInterval(5 * time.Second)
+	// Default output is the 10 second average of "some" pressure.
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s: %.1f%%", i.Resource, i.Some.Avg10)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for the pressure file.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.sampler.Every(interval)
+	return m
+}
+
+// Trigger adds a PSI trigger, which updates the module immediately when some
+// tasks are stalled for more than the given time within a window, instead of
+// waiting for the next refresh. The kernel requires the window to be between
+// 500ms and 10s, and a multiple of 2s for unprivileged users.
+func (m *Module) Trigger(stall, window time.Duration) *Module {
+	m.addTrigger(trigger{false, stall, window})
+	return m
+}
+
+// FullTrigger is like Trigger, but for the time all non-idle tasks were
+// stalled at once.
+func (m *Module) FullTrigger(stall, window time.Duration) *Module {
+	m.addTrigger(trigger{true, stall, window})
+	return m
+}
+
+func (m *Module) addTrigger(t trigger) {
+	triggers := m.triggers.Get().([]trigger)
+	m.triggers.Set(append(append([]trigger(nil), triggers...), t))
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.sampler.Get()
+	nextInfo, done := m.sampler.Subscribe()
+	defer done()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextTriggers, done := m.triggers.Subscribe()
+	defer done()
+	stopTriggers, triggerErr := m.watchTriggers(m.triggers.Get().([]trigger))
+	defer func() { stopTriggers() }()
+	for {
+		if s.Error(triggerErr) {
+			return
+		}
+		if err != nil {
+			s.Error(err)
+		} else {
+			s.Output(outputFunc(info.(Info)))
+		}
+		select {
+		case <-nextInfo:
+			info, err = m.sampler.Get()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextTriggers:
+			stopTriggers()
+			stopTriggers, triggerErr = m.watchTriggers(m.triggers.Get().([]trigger))
+		}
+	}
+}
+
+// watchTriggers creates the given triggers on the pressure file, and
+// refreshes the sampler whenever any of them fire. It returns a function
+// to remove all triggers.
+func (m *Module) watchTriggers(triggers []trigger) (stop func(), err error) {
+	var watchers []triggerWatcher
+	stop = func() {
+		for _, w := range watchers {
+			w.Close()
+		}
+	}
+	for _, t := range triggers {
+		w, err := openTrigger(m.file, t.spec())
+		if err != nil {
+			stop()
+			return func() {}, fmt.Errorf("%s: trigger %q: %w", m.file, t.spec(), err)
+		}
+		watchers = append(watchers, w)
+		go func(w triggerWatcher) {
+			for {
+				if err := w.Wait(); err != nil {
+					if err != errClosed {
+						l.Log("%s: trigger failed: %v", l.ID(m), err)
+					}
+					return
+				}
+				l.Fine("%s: trigger fired", l.ID(m))
+				m.sampler.Refresh()
+			}
+		}(w)
+	}
+	return stop, nil
+}
+
+var fs = afero.NewOsFs()
+
+// read parses a pressure file, which has the format:
+// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+// full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func read(file string, res Resource) (interface{}, error) {
+	f, err := fs.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info := Info{Resource: res}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var stall *Stall
+		switch fields[0] {
+		case "some":
+			stall = &info.Some
+		case "full":
+			stall = &info.Full
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			eq := strings.IndexByte(field, '=')
+			if eq < 0 {
+				return nil, fmt.Errorf("%s: malformed field %q", file, field)
+			}
+			key, val := field[:eq], field[eq+1:]
+			if key == "total" {
+				us, err := strconv.ParseUint(val, 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				stall.Total = time.Duration(us) * time.Microsecond
+				continue
+			}
+			avg, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "avg10":
+				stall.Avg10 = avg
+			case "avg60":
+				stall.Avg60 = avg
+			case "avg300":
+				stall.Avg300 = avg
+			}
+		}
+	}
+	return info, s.Err()
+}
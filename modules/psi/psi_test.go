@@ -0,0 +1,181 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package psi
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func shouldReturn(t *testing.T, file, contents string) {
+	t.Helper()
+	require.NoError(t, afero.WriteFile(fs, file, []byte(contents), 0644))
+}
+
+func TestPSI(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	shouldReturn(t, "/proc/pressure/cpu", `some avg10=1.25 avg60=0.50 avg300=0.10 total=123456
+full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+`)
+	shouldReturn(t, "/sys/fs/cgroup/user.slice/memory.pressure", `some avg10=12.00 avg60=6.00 avg300=2.00 total=5000000
+full avg10=8.50 avg60=4.00 avg300=1.00 total=3000000
+`)
+	testBar.New(t)
+
+	cpu := New(CPU)
+	mem := Cgroup("user.slice", Memory)
+	var info Info
+	mem.Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("mem %.1f/%.1f", i.Some.Avg60, i.Full.Avg10)
+	})
+	testBar.Run(cpu, mem)
+	testBar.LatestOutput().AssertText([]string{"cpu: 1.2%", "mem 6.0/8.5"}, "on start")
+	require.Equal(t, Info{
+		Resource: Memory,
+		Some:     Stall{Avg10: 12, Avg60: 6, Avg300: 2, Total: 5 * time.Second},
+		Full:     Stall{Avg10: 8.5, Avg60: 4, Avg300: 1, Total: 3 * time.Second},
+	}, info)
+
+	shouldReturn(t, "/proc/pressure/cpu",
+		"some avg10=45.00 avg60=10.00 avg300=2.00 total=223456\n")
+	testBar.Tick()
+	testBar.LatestOutput(0).AssertText([]string{"cpu: 45.0%", "mem 6.0/8.5"}, "on tick")
+
+	cpu.Output(func(i Info) bar.Output {
+		return outputs.Textf("%v", i.Some.Total)
+	})
+	testBar.LatestOutput(0).AssertText([]string{"223.456ms", "mem 6.0/8.5"}, "on output change")
+}
+
+func TestErrors(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+
+	cpu := New(CPU)
+	io := New(IO)
+	testBar.Run(cpu, io)
+	testBar.LatestOutput().AssertError("without pressure files")
+
+	shouldReturn(t, "/proc/pressure/cpu", "some avg10=abc avg60=0.00 avg300=0.00 total=0\n")
+	shouldReturn(t, "/proc/pressure/io", "some avg10 avg60 avg300\n")
+	testBar.Tick()
+	out := testBar.LatestOutput()
+	out.At(0).AssertError("non-numeric value")
+	out.At(1).AssertError("malformed field")
+
+	shouldReturn(t, "/proc/pressure/cpu", "some avg10=0.00 avg60=0.00 avg300=0.00 total=x\n")
+	shouldReturn(t, "/proc/pressure/io", "some avg10=2.00 avg60=0.00 avg300=0.00 total=0\n")
+	testBar.Tick()
+	out = testBar.LatestOutput()
+	out.At(0).AssertError("non-numeric total")
+	out.At(1).AssertText("io: 2.0%")
+}
+
+type testTrigger struct {
+	file, spec string
+	fire       chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+func (t *testTrigger) Wait() error {
+	select {
+	case <-t.fire:
+		return nil
+	case <-t.done:
+		return errClosed
+	}
+}
+
+func (t *testTrigger) Close() {
+	t.closeOnce.Do(func() { close(t.done) })
+}
+
+func mockTriggers(t *testing.T) <-chan *testTrigger {
+	triggers := make(chan *testTrigger, 10)
+	oldOpen := openTrigger
+	openTrigger = func(file, spec string) (triggerWatcher, error) {
+		if spec == "full 1000 1000" {
+			return nil, errors.New("invalid window")
+		}
+		trigger := &testTrigger{
+			file: file, spec: spec,
+			fire: make(chan struct{}),
+			done: make(chan struct{}),
+		}
+		triggers <- trigger
+		return trigger, nil
+	}
+	t.Cleanup(func() { openTrigger = oldOpen })
+	return triggers
+}
+
+func TestTriggers(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	triggers := mockTriggers(t)
+	shouldReturn(t, "/proc/pressure/memory",
+		"some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")
+	testBar.New(t)
+
+	mem := New(Memory).Trigger(150*time.Millisecond, time.Second)
+	testBar.Run(mem)
+	testBar.NextOutput("on start").AssertText([]string{"memory: 0.0%"})
+
+	some := <-triggers
+	require.Equal(t, "/proc/pressure/memory", some.file)
+	require.Equal(t, "some 150000 1000000", some.spec)
+
+	shouldReturn(t, "/proc/pressure/memory",
+		"some avg10=20.00 avg60=0.00 avg300=0.00 total=0\n")
+	some.fire <- struct{}{}
+	testBar.NextOutput("on trigger").AssertText([]string{"memory: 20.0%"})
+
+	mem.FullTrigger(100*time.Millisecond, 2*time.Second)
+	testBar.NextOutput("on trigger change").AssertText([]string{"memory: 20.0%"})
+	oldSome := <-triggers
+	full := <-triggers
+	require.Equal(t, "some 150000 1000000", oldSome.spec)
+	require.Equal(t, "full 100000 2000000", full.spec)
+	select {
+	case <-some.done:
+	case <-time.After(time.Second):
+		require.Fail(t, "previous trigger not closed on change")
+	}
+
+	shouldReturn(t, "/proc/pressure/memory",
+		"some avg10=30.00 avg60=0.00 avg300=0.00 total=0\n")
+	full.fire <- struct{}{}
+	testBar.NextOutput("on full trigger").AssertText([]string{"memory: 30.0%"})
+
+	mem.FullTrigger(time.Millisecond, time.Millisecond)
+	testBar.NextOutput("on invalid trigger").AssertError()
+	for _, tr := range []*testTrigger{oldSome, full, <-triggers, <-triggers} {
+		select {
+		case <-tr.done:
+		case <-time.After(time.Second):
+			require.Fail(t, "trigger not closed on error", tr.spec)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package psi
+
+import (
+	"golang.org/x/sys/unix"
+
+	"github.com/leosunmo/barista/base/watchers/pollpri"
+)
+
+// triggerWatcher waits for a PSI trigger to fire.
+type triggerWatcher interface {
+	// Wait blocks until the trigger fires, and returns errClosed once the
+	// watcher is closed.
+	Wait() error
+	// Close removes the trigger, and unblocks any pending Wait.
+	Close()
+}
+
+var errClosed = pollpri.ErrClosed
+
+// openTrigger creates a trigger on a pressure file. To allow tests to mock
+// out triggers, which require a real pressure file.
+var openTrigger = openPollTrigger
+
+// openPollTrigger creates a trigger by writing to a pressure file, which is
+// then signalled using POLLPRI. POLLERR is signalled if the file is removed,
+// e.g. because the cgroup was deleted.
+func openPollTrigger(file, spec string) (triggerWatcher, error) {
+	fd, err := unix.Open(file, unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	// The kernel expects the trigger to be null terminated.
+	if _, err := unix.Write(fd, append([]byte(spec), 0)); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	w, err := pollpri.New(fd, unix.POLLPRI)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
@@ -20,10 +20,10 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
 	"github.com/leosunmo/barista/base/value"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/outputs"
-	"github.com/leosunmo/barista/timing"
 
 	"github.com/martinlindhe/unit"
 	"golang.org/x/sys/unix"
@@ -44,26 +44,15 @@ type Info struct {
 	FreeHighRAM  unit.Datasize
 }
 
-// currentInfo stores the last value read by the updater.
-// This allows newly created modules to start with data.
-var currentInfo = new(value.ErrorValue) // of Info
-
 var once sync.Once
-var updater *timing.Scheduler
+var updater *sampler.Sampler
 
 // construct initialises sysinfo's global updating.
 func construct() {
 	once.Do(func() {
-		updater = timing.NewScheduler()
+		updater = sampler.New("sysinfo", read)
 		l.Attach(nil, updater, "sysinfo.updater")
-		l.Attach(nil, &currentInfo, "sysinfo.currentInfo")
 		updater.Every(3 * time.Second)
-		update()
-		go func() {
-			for range updater.C {
-				update()
-			}
-		}()
 	})
 }
 
@@ -99,8 +88,8 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 
 // Stream subscribes to sysinfo and updates the module's output.
 func (m *Module) Stream(s bar.Sink) {
-	i, err := currentInfo.Get()
-	nextInfo, done := currentInfo.Subscribe()
+	i, err := updater.Get()
+	nextInfo, done := updater.Subscribe()
 	defer done()
 	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
@@ -115,18 +104,18 @@ func (m *Module) Stream(s bar.Sink) {
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
 		case <-nextInfo:
-			i, err = currentInfo.Get()
+			i, err = updater.Get()
 		}
 	}
 }
 
 const loadScale = 65536.0 // LINUX_SYSINFO_LOADS_SCALE
 
-func update() {
+func read() (interface{}, error) {
 	var sysinfoT unix.Sysinfo_t
 	err := sysinfo(&sysinfoT)
-	if currentInfo.Error(err) {
-		return
+	if err != nil {
+		return nil, err
 	}
 	mult := unit.Datasize(sysinfoT.Unit) * unit.Byte
 	sysinfo := Info{
@@ -146,7 +135,7 @@ func update() {
 		TotalHighRAM: unit.Datasize(sysinfoT.Totalhigh) * mult,
 		FreeHighRAM:  unit.Datasize(sysinfoT.Freehigh) * mult,
 	}
-	currentInfo.Set(sysinfo)
+	return sysinfo, nil
 }
 
 // To allow tests to mock out unix.Sysinfo.
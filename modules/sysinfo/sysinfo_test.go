@@ -21,7 +21,6 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
-	"github.com/leosunmo/barista/base/value"
 	"github.com/leosunmo/barista/format"
 	"github.com/leosunmo/barista/outputs"
 	testBar "github.com/leosunmo/barista/testing/bar"
@@ -59,13 +58,8 @@ var mockSysinfo = func(out *unix.Sysinfo_t) error {
 func resetForTest() {
 	shouldReturn(unix.Sysinfo_t{})
 	sysinfo = mockSysinfo
-	currentInfo = &value.ErrorValue{}
 	once = sync.Once{}
 	construct()
-	// Flush upates for test.
-	n := currentInfo.Next()
-	update()
-	<-n
 }
 
 func TestSysinfo(t *testing.T) {
@@ -121,6 +115,11 @@ func TestSysinfo(t *testing.T) {
 	testBar.LatestOutput().Expect("on next tick")
 	require.Equal(time.Minute, afterTick.Sub(beforeTick))
 
+	afterTick = timing.NextTick()
+	testBar.LatestOutput().Expect("on aligned tick")
+	require.Equal(afterTick.Truncate(time.Minute), afterTick,
+		"later updates aligned to refresh interval")
+
 	testBar.AssertNoOutput("until next tick")
 }
 
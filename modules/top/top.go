@@ -0,0 +1,243 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package top provides an i3bar module that shows the processes using the
+// most CPU or memory.
+package top
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/procfs"
+	"github.com/leosunmo/barista/base/sampler"
+	"github.com/leosunmo/barista/base/value"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+
+	"github.com/spf13/afero"
+)
+
+// Process represents a running process and its resource usage.
+type Process struct {
+	procfs.Process
+	// CPU is the fraction of a single CPU used by the process since the
+	// previous sample, and can exceed 1 for multi-threaded processes. For
+	// new processes, it is the average since the process started.
+	CPU float64
+
+	onClick func(int, bar.Event)
+}
+
+// CPUPct returns the CPU usage of the process as a percentage of one CPU.
+func (p Process) CPUPct() int {
+	return int(p.CPU*100 + 0.5)
+}
+
+// Click calls the module's click handler with the PID of this process.
+// It can be used as the click handler for segments representing a process,
+// e.g. outputs.Text(p.Name).OnClick(p.Click).
+func (p Process) Click(e bar.Event) {
+	if p.onClick != nil {
+		p.onClick(p.PID, e)
+	}
+}
+
+// Info represents the processes using the most resources.
+type Info struct {
+	// ByCPU is the processes using the most CPU, in descending order.
+	ByCPU []Process
+	// ByRSS is the processes using the most memory, in descending order.
+	ByRSS []Process
+}
+
+// sample is all running processes, sorted by CPU and RSS.
+type sample struct {
+	byCPU, byRSS []Process
+}
+
+// cpuTime is the previous CPU time of a process. The start time is used to
+// detect PID reuse.
+type cpuTime struct {
+	start, total time.Duration
+}
+
+var (
+	once    sync.Once
+	updater *sampler.Sampler
+
+	// State carried between samples, protected by stateMu.
+	stateMu    sync.Mutex
+	prevUptime time.Duration
+	prevTimes  map[int]cpuTime
+)
+
+// construct initialises top's global updating. All top modules are updated
+// with just one scan of /proc.
+func construct() {
+	once.Do(func() {
+		updater = sampler.New("/proc#top", read)
+		l.Attach(nil, updater, "top.updater")
+		updater.Every(5 * time.Second)
+	})
+}
+
+// RefreshInterval configures the polling frequency. CPU usage is computed
+// over the time between samples.
+func RefreshInterval(interval time.Duration) {
+	construct()
+	updater.Every(interval)
+}
+
+// Module represents a bar.Module that displays the top processes.
+type Module struct {
+	count      int
+	outputFunc value.Value // of func(Info) bar.Output
+	onClick    value.Value // of func(int, bar.Event)
+}
+
+// New creates a module that displays the top count processes by CPU usage,
+// and provides the top count processes by memory usage to the output func.
+func New(count int) *Module {
+	construct()
+	m := &Module{count: count}
+	l.Labelf(m, "%d", count)
+	l.Register(m, "outputFunc", "onClick")
+	m.onClick.Set(func(int, bar.Event) {})
+	// Default output is the name and CPU usage of each process, with clicks
+	// on a process sent to the click handler for that process.
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, p := range i.ByCPU {
+			out.Append(outputs.Textf("%s %d%%", p.Name, p.CPUPct()).OnClick(p.Click))
+		}
+		return out
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// OnClick sets a function to be called with the PID of a process when it is
+// clicked, e.g. to kill it or open it in htop. Segments without a specific
+// process use the process with the highest CPU usage.
+func (m *Module) OnClick(f func(pid int, e bar.Event)) *Module {
+	if f == nil {
+		f = func(int, bar.Event) {}
+	}
+	m.onClick.Set(f)
+	return m
+}
+
+// Stream subscribes to process updates and updates the module's output
+// accordingly.
+func (m *Module) Stream(s bar.Sink) {
+	smp, err := updater.Get()
+	nextSample, done := updater.Subscribe()
+	defer done()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	onClick := m.onClick.Get().(func(int, bar.Event))
+	nextOnClick, done := m.onClick.Subscribe()
+	defer done()
+	for {
+		if err != nil {
+			s.Error(err)
+		} else if smp, ok := smp.(sample); ok {
+			i := m.info(smp, onClick)
+			out := outputs.Group(outputFunc(i))
+			if len(i.ByCPU) > 0 {
+				out.OnClick(i.ByCPU[0].Click)
+			}
+			s.Output(out)
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextOnClick:
+			onClick = m.onClick.Get().(func(int, bar.Event))
+		case <-nextSample:
+			smp, err = updater.Get()
+		}
+	}
+}
+
+// info returns the top processes for this module from a sample, with click
+// handlers bound to the given function.
+func (m *Module) info(smp sample, onClick func(int, bar.Event)) Info {
+	top := func(procs []Process) []Process {
+		if len(procs) > m.count {
+			procs = procs[:m.count]
+		}
+		res := make([]Process, len(procs))
+		for i, p := range procs {
+			p.onClick = onClick
+			res[i] = p
+		}
+		return res
+	}
+	return Info{ByCPU: top(smp.byCPU), ByRSS: top(smp.byRSS)}
+}
+
+var fs = afero.NewOsFs()
+
+func read() (interface{}, error) {
+	procs, err := procfs.Processes(fs)
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := procfs.Uptime(fs)
+	if err != nil {
+		return nil, err
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	elapsed := uptime - prevUptime
+	times := make(map[int]cpuTime, len(procs))
+	byCPU := make([]Process, len(procs))
+	for i, p := range procs {
+		total := p.CPUTime()
+		times[p.PID] = cpuTime{p.StartTime, total}
+		proc := Process{Process: p}
+		if prev, ok := prevTimes[p.PID]; ok && prev.start == p.StartTime && elapsed > 0 {
+			proc.CPU = float64(total-prev.total) / float64(elapsed)
+		} else if running := uptime - p.StartTime; running > 0 {
+			proc.CPU = float64(total) / float64(running)
+		}
+		byCPU[i] = proc
+	}
+	prevUptime, prevTimes = uptime, times
+
+	byRSS := append([]Process(nil), byCPU...)
+	sort.SliceStable(byCPU, func(a, b int) bool {
+		if byCPU[a].CPU != byCPU[b].CPU {
+			return byCPU[a].CPU > byCPU[b].CPU
+		}
+		return byCPU[a].PID < byCPU[b].PID
+	})
+	sort.SliceStable(byRSS, func(a, b int) bool {
+		if byRSS[a].RSS != byRSS[b].RSS {
+			return byRSS[a].RSS > byRSS[b].RSS
+		}
+		return byRSS[a].PID < byRSS[b].PID
+	})
+	return sample{byCPU, byRSS}, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package top
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/format"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+type proc struct {
+	name  string
+	ticks int // user + system, in clock ticks.
+	start int // in clock ticks.
+	rssKB int
+}
+
+func shouldReturn(t *testing.T, uptimeSecs float64, procs map[int]proc) {
+	t.Helper()
+	require.NoError(t, fs.RemoveAll("/proc"))
+	require.NoError(t, afero.WriteFile(fs, "/proc/uptime",
+		[]byte(fmt.Sprintf("%.2f 0.00\n", uptimeSecs)), 0644))
+	for pid, p := range procs {
+		stat := fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 0 0 0 0 0 %d 0 0 0 20 0 1 0 %d 0 0\n",
+			pid, p.name, pid, pid, p.ticks, p.start)
+		status := fmt.Sprintf("Name:\t%s\nVmRSS:\t%d kB\n", p.name, p.rssKB)
+		require.NoError(t, afero.WriteFile(fs, fmt.Sprintf("/proc/%d/stat", pid), []byte(stat), 0644))
+		require.NoError(t, afero.WriteFile(fs, fmt.Sprintf("/proc/%d/status", pid), []byte(status), 0644))
+	}
+}
+
+func resetForTest() {
+	stateMu.Lock()
+	prevUptime, prevTimes = 0, nil
+	stateMu.Unlock()
+	once = sync.Once{}
+	construct()
+}
+
+func TestTop(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	shouldReturn(t, 100, map[int]proc{
+		1:   {"init", 100, 0, 8192},
+		200: {"firefox", 4000, 2000, 1024 * 1024},
+		300: {"make", 1500, 9000, 4096},
+	})
+	testBar.New(t)
+	resetForTest()
+
+	def := New(2)
+	mem := New(3).Output(func(i Info) bar.Output {
+		var names []string
+		for _, p := range i.ByRSS {
+			names = append(names, fmt.Sprintf("%s:%s", p.Name, format.IBytesize(p.RSS)))
+		}
+		return outputs.Text(strings.Join(names, " "))
+	})
+	testBar.Run(def, mem)
+	testBar.LatestOutput().AssertText(
+		[]string{"make 150%", "firefox 50%", "firefox:1.0 GiB init:8.0 MiB make:4.0 MiB"},
+		"on start, average since process start")
+
+	shouldReturn(t, 110, map[int]proc{
+		1:   {"init", 150, 0, 8192},
+		200: {"firefox", 5500, 2000, 1024 * 1024},
+		// PID reused by a new process.
+		300: {"vim", 10, 10900, 16384},
+	})
+	testBar.Tick()
+	testBar.LatestOutput().AssertText(
+		[]string{"firefox 150%", "vim 10%", "firefox:1.0 GiB vim:16 MiB init:8.0 MiB"},
+		"on tick")
+}
+
+func TestClick(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	shouldReturn(t, 100, map[int]proc{
+		1:   {"init", 100, 0, 8192},
+		200: {"firefox", 4000, 2000, 1024 * 1024},
+	})
+	testBar.New(t)
+	resetForTest()
+
+	clicks := make(chan int, 10)
+	m := New(2).Output(func(i Info) bar.Output {
+		return outputs.Group(
+			outputs.Text("top"),
+			outputs.Text(i.ByRSS[1].Name).OnClick(i.ByRSS[1].Click),
+		)
+	})
+	testBar.Run(m)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"top", "init"})
+	out.At(0).LeftClick()
+	require.Equal(t, 0, len(clicks), "without click handler")
+
+	m.OnClick(func(pid int, e bar.Event) { clicks <- pid })
+	out = testBar.NextOutput("on click handler change")
+	out.At(0).LeftClick()
+	require.Equal(t, 200, <-clicks, "group click uses top process by CPU")
+	out.At(1).LeftClick()
+	require.Equal(t, 1, <-clicks, "process click uses process PID")
+
+	m.Output(func(i Info) bar.Output { return nil })
+	testBar.LatestOutput().AssertEmpty()
+}
+
+func TestErrors(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	resetForTest()
+
+	m := New(3)
+	testBar.Run(m)
+	testBar.LatestOutput().AssertError("on start without /proc")
+
+	shouldReturn(t, 10, map[int]proc{1: {"init", 0, 0, 0}})
+	testBar.Tick()
+	testBar.LatestOutput().AssertText([]string{"init 0%"}, "when /proc is readable")
+}
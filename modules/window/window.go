@@ -0,0 +1,222 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package window provides an i3bar module that displays the focused i3/sway
+// window.
+package window
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/value"
+	"github.com/leosunmo/barista/base/watchers/i3ipc"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+	"github.com/leosunmo/barista/timing"
+)
+
+// Window represents the focused window.
+type Window struct {
+	// ID is the container ID, or 0 if no window is focused.
+	ID    int64
+	Title string
+	// AppID is the Wayland app_id, only set for native Wayland windows
+	// in sway.
+	AppID string
+	// Class is the X11 window class.
+	Class      string
+	Floating   bool
+	Fullscreen bool
+}
+
+// Exists returns true if a window is focused, as opposed to e.g. an empty
+// workspace.
+func (w Window) Exists() bool {
+	return w.ID != 0
+}
+
+// App returns the application of the window, using the Wayland app_id if
+// set, and the X11 class otherwise.
+func (w Window) App() string {
+	if w.AppID != "" {
+		return w.AppID
+	}
+	return w.Class
+}
+
+func (w Window) command(cmd string) {
+	if !w.Exists() {
+		return
+	}
+	if err := i3ipc.RunCommand(fmt.Sprintf("[con_id=%d] %s", w.ID, cmd)); err != nil {
+		l.Log("Failed to %s window: %v", cmd, err)
+	}
+}
+
+// Kill closes the window.
+func (w Window) Kill() {
+	w.command("kill")
+}
+
+// ToggleFloating toggles the window between floating and tiling.
+func (w Window) ToggleFloating() {
+	w.command("floating toggle")
+}
+
+// ToggleFullscreen toggles fullscreen mode for the window.
+func (w Window) ToggleFullscreen() {
+	w.command("fullscreen toggle")
+}
+
+// Module represents a focused window bar module.
+type Module struct {
+	outputFunc value.Value // of func(Window) bar.Output
+}
+
+// New constructs a focused window module. By default it displays the window
+// title, truncated to 50 characters.
+func New() *Module {
+	m := new(Module)
+	l.Register(m, "outputFunc")
+	m.Output(func(w Window) bar.Output {
+		if !w.Exists() {
+			return nil
+		}
+		return outputs.Text(Truncate(w.Title, 50))
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Window) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// defaultClickHandler toggles fullscreen on left click, and toggles floating
+// on right click. Closing the window is not bound by default, since a stray
+// click could lose unsaved work, but can be added using a custom output, e.g.
+// outputs.Text(w.Title).OnClick(click.Middle(w.Kill)).
+func defaultClickHandler(w Window) func(bar.Event) {
+	return func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonLeft:
+			w.ToggleFullscreen()
+		case bar.ButtonRight:
+			w.ToggleFloating()
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	windowSub := i3ipc.Subscribe(i3ipc.WindowEvents)
+	defer windowSub.Unsubscribe()
+	workspaceSub := i3ipc.Subscribe(i3ipc.WorkspaceEvents)
+	defer workspaceSub.Unsubscribe()
+	outputFunc := m.outputFunc.Get().(func(Window) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	w, err := focusedWindow()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputs.Group(outputFunc(w)).OnClick(defaultClickHandler(w)))
+		select {
+		case <-windowSub.C:
+			w, err = windowFromEvent(windowSub.Get())
+		case <-workspaceSub.C:
+			w, err = focusedWindow()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Window) bar.Output)
+		}
+	}
+}
+
+// windowFromEvent returns the focused window after a window event. Events for
+// the focused window carry all the information needed, but anything else
+// (e.g. closing the focused window) requires fetching the tree.
+func windowFromEvent(e i3ipc.Event) (Window, error) {
+	var ev i3ipc.WindowEvent
+	if e.Decode(&ev) != nil || !ev.Container.Focused {
+		return focusedWindow()
+	}
+	switch ev.Change {
+	case "close", "move":
+		return focusedWindow()
+	}
+	return windowFromNode(&ev.Container), nil
+}
+
+func focusedWindow() (Window, error) {
+	tree, err := i3ipc.Tree()
+	if err != nil {
+		return Window{}, err
+	}
+	return windowFromNode(tree.FocusedNode()), nil
+}
+
+func windowFromNode(n *i3ipc.Node) Window {
+	if n == nil || (n.Window == 0 && n.AppID == "" && n.WindowProperties == nil) {
+		// Not a window, e.g. an empty workspace or split container.
+		return Window{}
+	}
+	w := Window{
+		ID:         n.ID,
+		Title:      n.Name,
+		AppID:      n.AppID,
+		Floating:   n.IsFloating(),
+		Fullscreen: n.FullscreenMode != 0,
+	}
+	if n.WindowProperties != nil {
+		w.Class = n.WindowProperties.Class
+	}
+	return w
+}
+
+// Truncate shortens text longer than width characters, replacing the end
+// with an ellipsis.
+func Truncate(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width || width < 1 {
+		return text
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// marqueeGap separates the end of the text from its start while scrolling.
+const marqueeGap = "   "
+
+// Marquee returns an output that scrolls text longer than width characters,
+// by one character at each interval. Shorter text is displayed as is.
+func Marquee(text string, width int, interval time.Duration) bar.Output {
+	runes := []rune(text)
+	if len(runes) <= width || width < 1 {
+		return outputs.Text(text)
+	}
+	loop := append(runes, []rune(marqueeGap)...)
+	start := timing.Now()
+	return outputs.Repeat(func(now time.Time) bar.Output {
+		offset := int(now.Sub(start)/interval) % len(loop)
+		visible := make([]rune, width)
+		for i := range visible {
+			visible[i] = loop[(offset+i)%len(loop)]
+		}
+		return outputs.Text(string(visible))
+	}).Every(interval)
+}
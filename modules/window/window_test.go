@@ -0,0 +1,121 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package window
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/click"
+	"github.com/leosunmo/barista/base/watchers/i3ipc"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+	testOutput "github.com/leosunmo/barista/testing/output"
+	"github.com/leosunmo/barista/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func workspace(windows ...*i3ipc.Node) i3ipc.Node {
+	return i3ipc.Node{ID: 1, Type: "root", Nodes: []*i3ipc.Node{
+		{ID: 2, Type: "workspace", Name: "1", Nodes: windows,
+			Focused: len(windows) == 0},
+	}}
+}
+
+func TestWindow(t *testing.T) {
+	srv := i3ipc.SetupTestServer()
+	srv.SetTree(workspace(&i3ipc.Node{ID: 10, Type: "con", Name: "vim",
+		Window: 0x1200001, Focused: true,
+		WindowProperties: &i3ipc.WindowProperties{Class: "URxvt"}}))
+
+	testBar.New(t)
+	m := New().Output(func(w Window) bar.Output {
+		if !w.Exists() {
+			return outputs.Text("-")
+		}
+		return outputs.Textf("%s|%s|%v|%v", w.App(), w.Title, w.Floating, w.Fullscreen)
+	})
+	testBar.Run(m)
+	out := testBar.NextOutput("initial window")
+	out.AssertText([]string{"URxvt|vim|false|false"})
+
+	out.At(0).LeftClick()
+	srv.AssertCommand(t, "[con_id=10] fullscreen toggle")
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	srv.AssertCommand(t, "[con_id=10] floating toggle")
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	srv.AssertNoCommand(t, "middle click does not close the window")
+
+	srv.Emit(i3ipc.WindowEvents, i3ipc.WindowEvent{Change: "title",
+		Container: i3ipc.Node{ID: 11, Type: "floating_con", Name: "Firefox",
+			AppID: "firefox", Focused: true, FullscreenMode: 1}})
+	testBar.NextOutput("on window event").AssertText(
+		[]string{"firefox|Firefox|true|true"})
+
+	srv.Emit(i3ipc.WindowEvents, i3ipc.WindowEvent{Change: "title",
+		Container: i3ipc.Node{ID: 12, Name: "background", AppID: "foot"}})
+	testBar.NextOutput("on unfocused window event").AssertText(
+		[]string{"URxvt|vim|false|false"}, "fetches focused window")
+
+	srv.SetTree(workspace())
+	srv.Emit(i3ipc.WindowEvents, i3ipc.WindowEvent{Change: "close",
+		Container: i3ipc.Node{ID: 10, Name: "vim", Focused: true}})
+	out = testBar.NextOutput("on close")
+	out.AssertText([]string{"-"}, "empty workspace")
+	out.At(0).LeftClick()
+	srv.AssertNoCommand(t, "without window")
+
+	srv.SetTree(workspace(&i3ipc.Node{ID: 13, Name: "htop", AppID: "foot", Focused: true}))
+	srv.Emit(i3ipc.WorkspaceEvents, i3ipc.WorkspaceEvent{Change: "focus"})
+	testBar.NextOutput("on workspace event").AssertText(
+		[]string{"foot|htop|false|false"})
+
+	m.Output(func(w Window) bar.Output {
+		return outputs.Text(w.Title).OnClick(click.Middle(w.Kill))
+	})
+	out = testBar.NextOutput("on output func change")
+	out.AssertText([]string{"htop"})
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	srv.AssertCommand(t, "[con_id=13] kill")
+}
+
+func TestTruncate(t *testing.T) {
+	require.Equal(t, "short", Truncate("short", 10))
+	require.Equal(t, "exactly 10", Truncate("exactly 10", 10))
+	require.Equal(t, "a longer …", Truncate("a longer title", 10))
+	require.Equal(t, "ünïcödé …", Truncate("ünïcödé title", 9))
+	require.Equal(t, "unlimited", Truncate("unlimited", 0))
+}
+
+func TestMarquee(t *testing.T) {
+	timing.TestMode()
+	testOutput.New(t, Marquee("short", 10, time.Second)).AssertText(
+		[]string{"short"})
+
+	out := Marquee("scrolling", 5, time.Second).(bar.TimedOutput)
+	var texts []string
+	for i := 0; i < 14; i++ {
+		txt, _ := out.Segments()[0].Content()
+		texts = append(texts, txt)
+		timing.AdvanceBy(time.Second)
+	}
+	require.Equal(t, []string{
+		"scrol", "croll", "rolli", "ollin", "lling", "ling ", "ing  ",
+		"ng   ", "g   s", "   sc", "  scr", " scro", "scrol", "croll",
+	}, texts)
+	require.Equal(t, timing.Now().Add(time.Second), out.NextRefresh())
+}
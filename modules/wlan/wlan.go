@@ -13,21 +13,18 @@
 // limitations under the License.
 
 // Package wlan provides an i3bar module for wireless information.
-// NOTE: This module REQUIRES the external command "iwgetid",
-// because getting the SSID is a privileged operation.
 package wlan
 
 import (
 	"net"
-	"os/exec"
-	"strconv"
-	"strings"
+	"time"
 
 	"github.com/leosunmo/barista/bar"
 	"github.com/leosunmo/barista/base/value"
 	"github.com/leosunmo/barista/base/watchers/netlink"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/outputs"
+	"github.com/leosunmo/barista/timing"
 	"github.com/martinlindhe/unit"
 )
 
@@ -40,6 +37,12 @@ type Info struct {
 	AccessPointMAC string
 	Channel        int
 	Frequency      unit.Frequency
+	// Signal strength in dBm, e.g. -55.
+	Signal int
+	// Quality of the signal in percent, derived from the signal strength.
+	Quality   int
+	TxBitrate unit.Datarate
+	RxBitrate unit.Datarate
 }
 
 // Connecting returns true if a connection is in progress.
@@ -60,14 +63,16 @@ func (i Info) Enabled() bool {
 // Module represents a wlan bar module.
 type Module struct {
 	intf       string
+	scheduler  *timing.Scheduler
 	outputFunc value.Value // of func(Info) bar.Output
 }
 
 // Named constructs an instance of the wlan module for the specified interface.
 func Named(iface string) *Module {
-	m := &Module{intf: iface}
+	m := &Module{intf: iface, scheduler: timing.NewScheduler()}
 	l.Label(m, iface)
-	l.Register(m, "outputFunc")
+	l.Register(m, "outputFunc", "scheduler")
+	m.RefreshInterval(5 * time.Second)
 	// Default output is just the SSID when connected.
 	m.Output(func(i Info) bar.Output {
 		if i.Connected() {
@@ -90,6 +95,13 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 	return m
 }
 
+// RefreshInterval configures the polling frequency for signal strength and
+// bitrates, which do not cause link updates.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
@@ -110,6 +122,8 @@ func (m *Module) Stream(s bar.Sink) {
 		select {
 		case <-linkSub.C:
 			info = handleUpdate(linkSub.Get())
+		case <-m.scheduler.C:
+			info = handleUpdate(linkSub.Get())
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
 		}
@@ -127,20 +141,53 @@ func handleUpdate(link netlink.Link) Info {
 }
 
 func fillWifiInfo(info *Info) {
-	ssid, err := iwgetid(info.Name, "-r")
-	if err != nil {
+	w, err := wireless(info.Name)
+	if err != nil || !w.Connected() {
 		return
 	}
-	info.SSID = ssid
-	info.AccessPointMAC, _ = iwgetid(info.Name, "-a")
-	ch, _ := iwgetid(info.Name, "-c")
-	info.Channel, _ = strconv.Atoi(ch)
-	freq, _ := iwgetid(info.Name, "-f")
-	freqFloat, _ := strconv.ParseFloat(freq, 64)
-	info.Frequency = unit.Frequency(freqFloat) * unit.Hertz
+	info.SSID = w.SSID
+	info.AccessPointMAC = w.BSSID.String()
+	info.Frequency = w.Frequency
+	info.Channel = channel(w.Frequency)
+	info.Signal = w.Signal
+	info.Quality = quality(w.Signal)
+	info.TxBitrate = w.TxBitrate
+	info.RxBitrate = w.RxBitrate
+}
+
+// channel returns the wifi channel number for a frequency.
+func channel(freq unit.Frequency) int {
+	mhz := int(freq.Megahertz() + 0.5)
+	switch {
+	case mhz == 2484:
+		return 14
+	case mhz >= 2412 && mhz < 2484:
+		return (mhz - 2407) / 5
+	case mhz >= 5955 && mhz <= 7115:
+		// 6 GHz band.
+		return (mhz - 5950) / 5
+	case mhz >= 5000 && mhz < 5955:
+		return (mhz - 5000) / 5
+	}
+	return 0
 }
 
-var iwgetid = func(intf, flag string) (string, error) {
-	out, err := exec.Command("/sbin/iwgetid", intf, "-r", flag).Output()
-	return strings.TrimSpace(string(out)), err
+// quality maps signal strength to a percentage, linearly from -100 dBm
+// (0%) to -50 dBm (100%), the same as NetworkManager.
+func quality(dBm int) int {
+	if dBm == 0 {
+		// Signal strength was not reported.
+		return 0
+	}
+	q := 2 * (dBm + 100)
+	switch {
+	case q < 0:
+		return 0
+	case q > 100:
+		return 100
+	}
+	return q
 }
+
+// To allow tests to mock out nl80211 queries.
+var wireless = netlink.Wireless
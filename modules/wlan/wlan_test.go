@@ -24,6 +24,10 @@ import (
 	"github.com/leosunmo/barista/base/watchers/netlink"
 	"github.com/leosunmo/barista/outputs"
 	testBar "github.com/leosunmo/barista/testing/bar"
+	"github.com/leosunmo/barista/timing"
+
+	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNoWlan(t *testing.T) {
@@ -35,44 +39,44 @@ func TestNoWlan(t *testing.T) {
 	testBar.LatestOutput().AssertEmpty("when no link is present")
 }
 
-// Map of interface -> map of iwgetid flag -> value.
+// Map of interface -> wireless info.
 var (
-	testData = map[string]map[string]string{}
+	testData = map[string]netlink.WirelessInfo{}
 	testMu   sync.RWMutex
 )
 
-func mockIwgetid(intf, flag string) (string, error) {
+func mockWireless(intf string) (netlink.WirelessInfo, error) {
 	testMu.RLock()
 	defer testMu.RUnlock()
 	d, ok := testData[intf]
 	if !ok {
-		return "", errors.New("No interface")
-	}
-	val, ok := d[flag]
-	if !ok {
-		return "", errors.New("Unknown flag")
+		return d, errors.New("No interface")
 	}
-	return val, nil
+	return d, nil
+}
+
+func wirelessShouldReturn(intf string, ssid, bssid string, freqMHz int) {
+	mac, _ := net.ParseMAC(bssid)
+	setWireless(intf, netlink.WirelessInfo{
+		SSID:      ssid,
+		BSSID:     mac,
+		Frequency: unit.Frequency(freqMHz) * unit.Megahertz,
+	})
 }
 
-func iwgetidShouldReturn(intf string, data map[string]string) {
+func setWireless(intf string, info netlink.WirelessInfo) {
 	testMu.Lock()
 	defer testMu.Unlock()
-	testData[intf] = data
+	testData[intf] = info
 }
 
 func init() {
-	iwgetid = mockIwgetid
+	wireless = mockWireless
 }
 
 func TestWlan(t *testing.T) {
 	nlt := netlink.TestMode()
-	iwgetidShouldReturn("wlan0", map[string]string{
-		"-r": "OtherNet",
-		"-a": "00:11:22:33:44:66",
-		"-c": "141",
-		"-f": "5.22e+09",
-	})
+	wirelessShouldReturn("wlan0", "OtherNet", "00:11:22:33:44:66", 5220)
 	link0 := nlt.AddLink(netlink.Link{Name: "wlan0", State: netlink.Up})
 	link1 := nlt.AddLink(netlink.Link{Name: "wlan1", State: netlink.Dormant})
 
@@ -110,12 +114,7 @@ func TestWlan(t *testing.T) {
 	})
 	testBar.LatestOutput().AssertText([]string{"5e+09", "WLAN ...", "wlan0/OtherNet"})
 
-	iwgetidShouldReturn("wlan1", map[string]string{
-		"-r": "NetworkName",
-		"-a": "00:11:22:33:44:55",
-		"-c": "11",
-		"-f": "2.4e+09",
-	})
+	wirelessShouldReturn("wlan1", "NetworkName", "00:11:22:33:44:55", 2462)
 	nlt.UpdateLink(link1, netlink.Link{Name: "wlan1", State: netlink.Up})
 	testBar.LatestOutput(1, 2).AssertText([]string{"5e+09", "NetworkName", "wlan0/OtherNet"})
 
@@ -142,12 +141,7 @@ func TestWlan(t *testing.T) {
 	nlt.UpdateLink(link0, netlink.Link{Name: "wlan0", State: netlink.Down})
 	testBar.LatestOutput(0, 2).At(2).AssertText("wlan1/NetworkName", "when active link switches")
 
-	iwgetidShouldReturn("wl1", map[string]string{
-		"-r": "NetworkName",
-		"-a": "00:11:22:33:44:55",
-		"-c": "11",
-		"-f": "2.4e+09",
-	})
+	wirelessShouldReturn("wl1", "NetworkName", "00:11:22:33:44:55", 2462)
 	nlt.UpdateLink(link1, netlink.Link{Name: "wl1", State: netlink.Up})
 	testBar.LatestOutput(1, 2).AssertText([]string{"::1", "wl1/NetworkName"}, "when active link is renamed")
 
@@ -157,3 +151,54 @@ func TestWlan(t *testing.T) {
 	nlt.RemoveLink(link0)
 	testBar.LatestOutput(0, 2).AssertText([]string{"<no wlan>"}, "when no links remain")
 }
+
+func TestSignal(t *testing.T) {
+	nlt := netlink.TestMode()
+	setWireless("wlan2", netlink.WirelessInfo{
+		SSID:      "Signal",
+		BSSID:     net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		Frequency: 5180 * unit.Megahertz,
+		Signal:    -60,
+		TxBitrate: 866.7 * unit.MegabitPerSecond,
+		RxBitrate: 54 * unit.MegabitPerSecond,
+	})
+	nlt.AddLink(netlink.Link{Name: "wlan2", State: netlink.Up})
+
+	testBar.New(t)
+	var info Info
+	wl := Named("wlan2").Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("%s %d dBm (%d%%)", i.SSID, i.Signal, i.Quality)
+	})
+	testBar.Run(wl)
+	testBar.NextOutput().AssertText([]string{"Signal -60 dBm (80%)"})
+	require.Equal(t, 36, info.Channel)
+	require.Equal(t, "00:01:02:03:04:05", info.AccessPointMAC)
+	require.InDelta(t, 866.7, info.TxBitrate.MegabitsPerSecond(), 1e-9)
+	require.InDelta(t, 54, info.RxBitrate.MegabitsPerSecond(), 1e-9)
+
+	setWireless("wlan2", netlink.WirelessInfo{
+		SSID:  "Signal",
+		BSSID: net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		// Above -50 dBm is full quality.
+		Signal: -42,
+	})
+	testBar.AssertNoOutput("until refresh")
+	timing.NextTick()
+	testBar.NextOutput("on refresh").AssertText([]string{"Signal -42 dBm (100%)"})
+
+	setWireless("wlan2", netlink.WirelessInfo{})
+	timing.NextTick()
+	testBar.NextOutput("on disconnect").AssertText([]string{" 0 dBm (0%)"})
+}
+
+func TestChannel(t *testing.T) {
+	for mhz, ch := range map[int]int{
+		2412: 1, 2437: 6, 2462: 11, 2484: 14,
+		5180: 36, 5220: 44, 5745: 149,
+		5955: 1, 6115: 33,
+		0: 0, 900: 0,
+	} {
+		require.Equal(t, ch, channel(unit.Frequency(mhz)*unit.Megahertz), "%d MHz", mhz)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package workspaces provides an i3bar module that displays i3/sway
+// workspaces, and switches between them on click.
+package workspaces
+
+import (
+	"strings"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/value"
+	"github.com/leosunmo/barista/base/watchers/i3ipc"
+	"github.com/leosunmo/barista/colors"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/outputs"
+)
+
+// Workspace represents a single i3/sway workspace.
+type Workspace struct {
+	Num    int
+	Name   string
+	Output string
+	// Visible is true if the workspace is shown on its output.
+	Visible bool
+	// Focused is true if the workspace has the input focus.
+	Focused bool
+	// Urgent is true if any window on the workspace is urgent.
+	Urgent bool
+}
+
+// quote quotes a string for use as an argument in an i3 command.
+func quote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// Switch focuses the workspace.
+func (w Workspace) Switch() {
+	cmd := "workspace --no-auto-back-and-forth " + quote(w.Name)
+	if err := i3ipc.RunCommand(cmd); err != nil {
+		l.Log("Failed to switch workspace: %v", err)
+	}
+}
+
+// Module represents a workspaces bar module. Each workspace is displayed
+// using the output function, and the workspaces are updated whenever i3
+// sends a workspace event.
+type Module struct {
+	output     string
+	outputFunc value.Value // of func(Workspace) bar.Output
+}
+
+func newModule(output string) *Module {
+	m := &Module{output: output}
+	l.Register(m, "outputFunc")
+	m.Output(defaultOutput)
+	return m
+}
+
+// New constructs a workspaces module that displays the workspaces on all
+// outputs.
+func New() *Module {
+	return newModule("")
+}
+
+// ForOutput constructs a workspaces module that only displays the workspaces
+// on the given output, e.g. for a bar that's only shown on that output.
+func ForOutput(output string) *Module {
+	m := newModule(output)
+	l.Label(m, output)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function
+// for each workspace.
+func (m *Module) Output(outputFunc func(Workspace) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// defaultOutput displays the workspace name using the workspace colours from
+// the colour scheme (e.g. focused_workspace_bg), which are set by
+// colors.LoadBarConfig to match the i3bar workspace buttons.
+func defaultOutput(w Workspace) bar.Output {
+	state := "inactive"
+	switch {
+	case w.Urgent:
+		state = "urgent"
+	case w.Focused:
+		state = "focused"
+	case w.Visible:
+		state = "active"
+	}
+	return outputs.Text(w.Name).
+		Color(colors.Scheme(state + "_workspace_text")).
+		Background(colors.Scheme(state + "_workspace_bg")).
+		Border(colors.Scheme(state + "_workspace_border")).
+		Urgent(w.Urgent)
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	sub := i3ipc.Subscribe(i3ipc.WorkspaceEvents)
+	defer sub.Unsubscribe()
+	outputFunc := m.outputFunc.Get().(func(Workspace) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	workspaces, err := m.workspaces()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(m.render(workspaces, outputFunc))
+		select {
+		case <-sub.C:
+			workspaces, err = m.workspaces()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Workspace) bar.Output)
+		}
+	}
+}
+
+// workspaces returns the workspaces to display, filtered by output.
+func (m *Module) workspaces() ([]Workspace, error) {
+	all, err := i3ipc.Workspaces()
+	if err != nil {
+		return nil, err
+	}
+	var result []Workspace
+	for _, w := range all {
+		if m.output != "" && w.Output != m.output {
+			continue
+		}
+		result = append(result, Workspace{
+			Num:     w.Num,
+			Name:    w.Name,
+			Output:  w.Output,
+			Visible: w.Visible,
+			Focused: w.Focused,
+			Urgent:  w.Urgent,
+		})
+	}
+	return result, nil
+}
+
+func (m *Module) render(workspaces []Workspace, outputFunc func(Workspace) bar.Output) bar.Output {
+	out := outputs.Group()
+	for _, w := range workspaces {
+		if o := outputFunc(w); o != nil {
+			out.Append(outputs.Group(o).OnClick(clickHandler(w, workspaces)))
+		}
+	}
+	return out
+}
+
+// clickHandler switches to the workspace on left click, and cycles through
+// all displayed workspaces on scroll.
+func clickHandler(w Workspace, all []Workspace) func(bar.Event) {
+	return func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonLeft:
+			w.Switch()
+		case bar.ScrollUp, bar.ScrollLeft:
+			cycle(all, -1)
+		case bar.ScrollDown, bar.ScrollRight:
+			cycle(all, 1)
+		}
+	}
+}
+
+// cycle switches to the workspace delta positions away from the current
+// one, wrapping around at either end. The current workspace is the focused
+// one, or the visible one if the focus is on a different output.
+func cycle(all []Workspace, delta int) {
+	if len(all) == 0 {
+		return
+	}
+	current := -1
+	for i, w := range all {
+		if w.Focused || (w.Visible && current < 0) {
+			current = i
+		}
+	}
+	if current < 0 {
+		current = 0
+	}
+	next := ((current+delta)%len(all) + len(all)) % len(all)
+	all[next].Switch()
+}
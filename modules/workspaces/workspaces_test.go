@@ -0,0 +1,99 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workspaces
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/i3ipc"
+	"github.com/leosunmo/barista/colors"
+	"github.com/leosunmo/barista/outputs"
+	testBar "github.com/leosunmo/barista/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaces(t *testing.T) {
+	srv := i3ipc.SetupTestServer()
+	srv.SetWorkspaces(
+		i3ipc.Workspace{Num: 1, Name: "1", Output: "eDP-1", Visible: true},
+		i3ipc.Workspace{Num: 2, Name: `2: "web"`, Output: "eDP-1"},
+		i3ipc.Workspace{Num: 3, Name: "3", Output: "HDMI-1", Visible: true, Focused: true},
+		i3ipc.Workspace{Num: 4, Name: "4", Output: "eDP-1", Urgent: true},
+	)
+	colors.LoadFromMap(map[string]string{
+		"focused_workspace_bg": "#0000ff",
+		"urgent_workspace_bg":  "#ff0000",
+	})
+
+	testBar.New(t)
+	all := New()
+	laptop := ForOutput("eDP-1").Output(func(w Workspace) bar.Output {
+		if !w.Visible && !w.Urgent {
+			return nil
+		}
+		return outputs.Textf("%d", w.Num)
+	})
+	testBar.Run(all, laptop)
+
+	out := testBar.LatestOutput(0, 1)
+	out.AssertText([]string{`1`, `2: "web"`, "3", "4", "1", "4"})
+	bg, _ := out.At(2).Segment().GetBackground()
+	require.Equal(t, colors.Hex("#0000ff"), bg, "focused workspace colour")
+	bg, _ = out.At(3).Segment().GetBackground()
+	require.Equal(t, colors.Hex("#ff0000"), bg, "urgent workspace colour")
+	urgent, _ := out.At(3).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	out.At(1).LeftClick()
+	srv.AssertCommand(t, `workspace --no-auto-back-and-forth "2: \"web\""`)
+
+	// Workspaces on eDP-1 are 1, 2, 4; 1 is visible.
+	out.At(4).Click(bar.Event{Button: bar.ScrollDown})
+	srv.AssertCommand(t, `workspace --no-auto-back-and-forth "2: \"web\""`,
+		"scroll from visible workspace")
+	out.At(5).Click(bar.Event{Button: bar.ScrollUp})
+	srv.AssertCommand(t, `workspace --no-auto-back-and-forth "4"`,
+		"scroll wraps around")
+	// All workspaces: 3 is focused.
+	out.At(0).Click(bar.Event{Button: bar.ScrollRight})
+	srv.AssertCommand(t, `workspace --no-auto-back-and-forth "4"`,
+		"scroll from focused workspace")
+
+	srv.SetWorkspaces(
+		i3ipc.Workspace{Num: 1, Name: "1", Output: "eDP-1", Visible: true, Focused: true},
+		i3ipc.Workspace{Num: 3, Name: "3", Output: "HDMI-1", Visible: true},
+	)
+	testBar.AssertNoOutput("until workspace event")
+	srv.Emit(i3ipc.WorkspaceEvents, i3ipc.WorkspaceEvent{Change: "focus"})
+	testBar.LatestOutput(0, 1).AssertText([]string{"1", "3", "1"},
+		"on workspace event")
+
+	laptop.Output(func(w Workspace) bar.Output {
+		return outputs.Text(fmt.Sprintf("[%s]", w.Name))
+	})
+	testBar.NextOutput("on output func change").AssertText(
+		[]string{"1", "3", "[1]"})
+}
+
+func TestNoWindowManager(t *testing.T) {
+	srv := i3ipc.SetupTestServer()
+	srv.Close()
+	testBar.New(t)
+	testBar.Run(New())
+	testBar.NextOutput("on error").AssertError()
+}
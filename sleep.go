@@ -0,0 +1,102 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/watchers/dbus"
+	l "github.com/leosunmo/barista/logging"
+	"github.com/leosunmo/barista/timing"
+)
+
+const (
+	logindService = "org.freedesktop.login1"
+	logindPath    = "/org/freedesktop/login1"
+	logindManager = "org.freedesktop.login1.Manager"
+)
+
+// watchSleep listens for logind's PrepareForSleep signal, pausing timing
+// before the system suspends, and refreshing all modules once it resumes.
+func (b *i3Bar) watchSleep(busType dbus.BusType) {
+	defer func() {
+		// Connecting to the bus panics if it's unavailable, which should
+		// not take down the bar.
+		if r := recover(); r != nil {
+			l.Log("Not watching for system sleep: %v", r)
+		}
+	}()
+	w := dbus.WatchProperties(busType, logindService, logindPath, logindManager).
+		AddSignalHandler("PrepareForSleep", func(s *dbus.Signal, _ dbus.Fetcher) map[string]interface{} {
+			if len(s.Body) == 0 {
+				return nil
+			}
+			start, ok := s.Body[0].(bool)
+			if !ok {
+				return nil
+			}
+			return map[string]interface{}{"PreparingForSleep": start}
+		})
+	go func() {
+		defer w.Unsubscribe()
+		for u := range w.Updates {
+			if ch, ok := u["PreparingForSleep"]; ok {
+				if sleeping, _ := ch[1].(bool); sleeping {
+					b.sleep()
+				} else {
+					b.wake()
+				}
+			}
+		}
+	}()
+}
+
+// sleep pauses all timing while the system is suspended.
+func (b *i3Bar) sleep() {
+	l.Log("System going to sleep")
+	b.Lock()
+	defer b.Unlock()
+	if b.sleeping {
+		return
+	}
+	b.sleeping = true
+	timing.Pause()
+	b.emitDebugEvent(dEvtSleep, "")
+}
+
+// wake resumes timing after the system resumes from suspend, triggering any
+// schedulers that elapsed while suspended, and refreshes all modules that
+// support it, since their data is likely to be stale. Only modules added to
+// the bar directly are refreshed; modules wrapped by others (e.g. group or
+// throttle) are not, and pick up changes on their next scheduled update.
+func (b *i3Bar) wake() {
+	l.Log("System woke up")
+	b.Lock()
+	if !b.sleeping {
+		b.Unlock()
+		return
+	}
+	b.sleeping = false
+	timing.Wake()
+	if !b.paused {
+		timing.Resume()
+	}
+	b.emitDebugEvent(dEvtWake, "")
+	b.Unlock()
+	for _, m := range b.modules {
+		if r, ok := m.(bar.RefresherModule); ok {
+			go r.Refresh()
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/leosunmo/barista/bar"
+	"github.com/leosunmo/barista/base/sampler"
 	"github.com/leosunmo/barista/core"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/oauth"
@@ -52,6 +53,7 @@ func New(t require.TestingT) {
 	}
 	instance.Store(b)
 	timing.TestMode()
+	sampler.TestMode()
 	encryptionKeySet.Do(func() {
 		oauth.SetEncryptionKey([]byte(`not-an-encryption-key`))
 	})
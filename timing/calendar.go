@@ -0,0 +1,402 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule represents a recurring set of calendar times, such as those
+// described by a cron expression.
+type Schedule interface {
+	// Next returns the first time matching the schedule that is strictly
+	// after t, or the zero time if there are no further matches. Times are
+	// matched against the wall clock in t's location, unless the schedule
+	// specifies its own time zone.
+	Next(t time.Time) time.Time
+}
+
+// calendar is a Schedule that matches a set of values for each component
+// of the wall clock time.
+type calendar struct {
+	spec                          string
+	years, months, days, weekdays field
+	hours, minutes, seconds       field
+	// If true, a time matches if either the day of month or the weekday
+	// match, provided both are restricted (cron semantics). Otherwise both
+	// must always match.
+	dayOr bool
+	// If nil, times are matched in the location of the time given to Next.
+	loc *time.Location
+}
+
+// Bounds for years in calendar specifications, same as systemd.
+const (
+	minYear = 1970
+	maxYear = 2199
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonths = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronWeekdays = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// ParseCron parses a standard five-field cron expression, consisting of
+// minute, hour, day of month, month, and day of week. Each field can be
+// a '*', a value, a range (a-b), or a comma-separated list of these, and
+// any '*' or range can be followed by a step (/n). Months and weekdays can
+// also be given as three-letter English names, and both 0 and 7 represent
+// Sunday. The macros @yearly, @annually, @monthly, @weekly, @daily,
+// @midnight, and @hourly are also supported.
+//
+// As with cron, if both the day of month and day of week are restricted
+// (i.e. not '*'), a time matches if either of them matches.
+func ParseCron(expr string) (Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields, got %d in %q", len(fields), expr)
+	}
+	c := &calendar{
+		spec:    expr,
+		years:   allValues(minYear, maxYear),
+		seconds: singleValue(0, 59, 0),
+	}
+	var err error
+	for _, f := range []struct {
+		dest     *field
+		min, max int
+		names    map[string]int
+	}{
+		{&c.minutes, 0, 59, nil},
+		{&c.hours, 0, 23, nil},
+		{&c.days, 1, 31, nil},
+		{&c.months, 1, 12, cronMonths},
+		{&c.weekdays, 0, 7, cronWeekdays},
+	} {
+		if *f.dest, err = parseField(fields[0], f.min, f.max, f.names, "-"); err != nil {
+			return nil, fmt.Errorf("cron: %v in %q", err, expr)
+		}
+		fields = fields[1:]
+	}
+	c.weekdays = foldSunday(c.weekdays)
+	c.dayOr = !c.days.star && !c.weekdays.star
+	return c, nil
+}
+
+var calendarShorthands = map[string]string{
+	"minutely":     "*-*-* *:*:00",
+	"hourly":       "*-*-* *:00:00",
+	"daily":        "*-*-* 00:00:00",
+	"monthly":      "*-*-01 00:00:00",
+	"weekly":       "Mon *-*-* 00:00:00",
+	"yearly":       "*-01-01 00:00:00",
+	"annually":     "*-01-01 00:00:00",
+	"quarterly":    "*-01,04,07,10-01 00:00:00",
+	"semiannually": "*-01,07-01 00:00:00",
+}
+
+var calendarWeekdays = map[string]int{
+	"mon": 1, "monday": 1,
+	"tue": 2, "tuesday": 2,
+	"wed": 3, "wednesday": 3,
+	"thu": 4, "thursday": 4,
+	"fri": 5, "friday": 5,
+	"sat": 6, "saturday": 6,
+	"sun": 7, "sunday": 7,
+}
+
+// ParseCalendar parses a calendar event specification, in the format used by
+// systemd's OnCalendar, i.e.
+//
+//	[Weekdays] [[Year-]Month-Day] [Hour:Minute[:Second]] [Timezone]
+//
+// For example, "Mon..Fri 09:00", "*-*-01 00:00:00", or "*:0/15 Europe/Paris".
+// Each date or time component can be a '*', a value, a range (a..b), or a
+// comma-separated list of these, and any '*', value, or range can be followed
+// by a repetition (/n). Weekdays can be given as English names (e.g. Mon or
+// Monday), lists, or ranges. A missing date matches every day, a missing time
+// is 00:00:00, and if the timezone is omitted the local time zone is used.
+// The shorthands minutely, hourly, daily, weekly, monthly, quarterly,
+// semiannually, yearly, and annually are also supported.
+func ParseCalendar(spec string) (Schedule, error) {
+	s := strings.TrimSpace(spec)
+	if shorthand, ok := calendarShorthands[strings.ToLower(s)]; ok {
+		s = shorthand
+	}
+	tokens := strings.Fields(s)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("calendar: empty specification")
+	}
+	c := &calendar{
+		spec:     spec,
+		years:    allValues(minYear, maxYear),
+		months:   allValues(1, 12),
+		days:     allValues(1, 31),
+		weekdays: allValues(0, 6),
+		hours:    singleValue(0, 23, 0),
+		minutes:  singleValue(0, 59, 0),
+		seconds:  singleValue(0, 59, 0),
+	}
+	var err error
+	if first := tokens[0][0]; (first >= 'a' && first <= 'z') || (first >= 'A' && first <= 'Z') {
+		if c.weekdays, err = parseField(tokens[0], 1, 7, calendarWeekdays, ".."); err == nil {
+			c.weekdays = foldSunday(c.weekdays)
+			tokens = tokens[1:]
+		}
+	}
+	haveDate, haveTime := false, false
+	for i, tok := range tokens {
+		switch {
+		case strings.Contains(tok, ":") && !haveTime:
+			haveTime = true
+			err = c.parseTime(tok)
+		case strings.Contains(tok, "-") && !haveDate:
+			haveDate = true
+			err = c.parseDate(tok)
+			if err != nil && i == len(tokens)-1 {
+				// Zones such as Etc/GMT-5 also contain a '-'.
+				c.loc, err = time.LoadLocation(tok)
+			}
+		case i == len(tokens)-1:
+			c.loc, err = time.LoadLocation(tok)
+		default:
+			err = fmt.Errorf("unexpected %q", tok)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("calendar: %v in %q", err, spec)
+		}
+	}
+	return c, nil
+}
+
+func (c *calendar) parseDate(date string) (err error) {
+	parts := strings.Split(date, "-")
+	switch len(parts) {
+	case 2:
+		parts = append([]string{"*"}, parts...)
+	case 3:
+	default:
+		return fmt.Errorf("invalid date %q", date)
+	}
+	if c.years, err = parseField(parts[0], minYear, maxYear, nil, ".."); err != nil {
+		return err
+	}
+	if c.months, err = parseField(parts[1], 1, 12, nil, ".."); err != nil {
+		return err
+	}
+	c.days, err = parseField(parts[2], 1, 31, nil, "..")
+	return err
+}
+
+func (c *calendar) parseTime(tm string) (err error) {
+	parts := strings.Split(tm, ":")
+	switch len(parts) {
+	case 2:
+		parts = append(parts, "00")
+	case 3:
+	default:
+		return fmt.Errorf("invalid time %q", tm)
+	}
+	if c.hours, err = parseField(parts[0], 0, 23, nil, ".."); err != nil {
+		return err
+	}
+	if c.minutes, err = parseField(parts[1], 0, 59, nil, ".."); err != nil {
+		return err
+	}
+	c.seconds, err = parseField(parts[2], 0, 59, nil, "..")
+	return err
+}
+
+// Next implements the Schedule interface.
+func (c *calendar) Next(after time.Time) time.Time {
+	loc := after.Location()
+	if c.loc != nil {
+		loc = c.loc
+		after = after.In(loc)
+	}
+	t := wallTime(loc, after.Year(), after.Month(), after.Day(),
+		after.Hour(), after.Minute(), after.Second()+1)
+	for t.Year() <= maxYear {
+		y, mo, d := t.Date()
+		h, mi, s := t.Clock()
+		switch {
+		case !c.years.has(y):
+			t = wallTime(loc, y+1, time.January, 1, 0, 0, 0)
+		case !c.months.has(int(mo)):
+			t = wallTime(loc, y, mo+1, 1, 0, 0, 0)
+		case !c.dayMatches(t):
+			t = wallTime(loc, y, mo, d+1, 0, 0, 0)
+		case !c.hours.has(h):
+			t = wallTime(loc, y, mo, d, h+1, 0, 0)
+		case !c.minutes.has(mi):
+			t = wallTime(loc, y, mo, d, h, mi+1, 0)
+		case !c.seconds.has(s), !t.After(after):
+			// Wall clock times that occur twice when the clock goes back
+			// resolve to the first occurrence, so only trigger once.
+			t = wallTime(loc, y, mo, d, h, mi, s+1)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *calendar) String() string {
+	return c.spec
+}
+
+func (c *calendar) dayMatches(t time.Time) bool {
+	day := c.days.has(t.Day())
+	weekday := c.weekdays.has(int(t.Weekday()))
+	if c.dayOr {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// wallTime returns the first instant at which the wall clock in loc reads
+// the given time (normalised as time.Date does). If the wall clock skips
+// over the time, e.g. when moving forward for DST, this is the instant at
+// which the clock skips.
+func wallTime(loc *time.Location, y int, mo time.Month, d, h, mi, s int) time.Time {
+	t := time.Date(y, mo, d, h, mi, s, 0, loc)
+	want := time.Date(y, mo, d, h, mi, s, 0, time.UTC)
+	if got := wallClock(t); got.Before(want) {
+		t = t.Add(want.Sub(got))
+	}
+	return t
+}
+
+// wallClock returns a UTC time with the same wall clock reading as t, which
+// allows comparing wall clock times across offset changes.
+func wallClock(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	return time.Date(y, mo, d, h, mi, s, 0, time.UTC)
+}
+
+// field is a set of permitted values for a single calendar component.
+type field struct {
+	min  int
+	set  []bool // indexed by value-min.
+	star bool   // whether the field was specified using '*'.
+}
+
+func (f field) has(v int) bool {
+	i := v - f.min
+	return i >= 0 && i < len(f.set) && f.set[i]
+}
+
+func allValues(min, max int) field {
+	f := field{min: min, set: make([]bool, max-min+1), star: true}
+	for i := range f.set {
+		f.set[i] = true
+	}
+	return f
+}
+
+func singleValue(min, max, value int) field {
+	f := field{min: min, set: make([]bool, max-min+1)}
+	f.set[value-min] = true
+	return f
+}
+
+// foldSunday converts a weekday field where Sunday can be 0 or 7 into one
+// indexed by time.Weekday.
+func foldSunday(f field) field {
+	out := field{min: 0, set: make([]bool, 7), star: f.star}
+	for d := 0; d <= 7; d++ {
+		if f.has(d) {
+			out.set[d%7] = true
+		}
+	}
+	return out
+}
+
+// parseField parses a comma-separated list of values, ranges (using sep),
+// and steps into a field.
+func parseField(spec string, min, max int, names map[string]int, sep string) (field, error) {
+	f := field{min: min, set: make([]bool, max-min+1), star: strings.HasPrefix(spec, "*")}
+	value := func(s string) (int, error) {
+		if v, ok := names[strings.ToLower(s)]; ok {
+			return v, nil
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+		return v, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		rng, stepStr := part, ""
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			rng, stepStr = part[:i], part[i+1:]
+		}
+		lo, hi := min, max
+		var err error
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, sep):
+			bounds := strings.SplitN(rng, sep, 2)
+			if lo, err = value(bounds[0]); err != nil {
+				return f, err
+			}
+			if hi, err = value(bounds[1]); err != nil {
+				return f, err
+			}
+		default:
+			if lo, err = value(rng); err != nil {
+				return f, err
+			}
+			if stepStr == "" {
+				hi = lo
+			}
+		}
+		step := 1
+		if stepStr != "" {
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return f, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return f, fmt.Errorf("%q out of range [%d, %d]", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			f.set[v-min] = true
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Wednesday.
+var calendarStart = time.Date(2020, time.January, 1, 10, 30, 15, 0, time.UTC)
+
+func assertSchedule(t *testing.T, sched Schedule, start time.Time, expected ...string) {
+	t.Helper()
+	now := start
+	for _, e := range expected {
+		next := sched.Next(now)
+		require.Equal(t, e, next.Format("Mon 2006-01-02 15:04:05 MST"),
+			"%v after %v", sched, now)
+		now = next
+	}
+}
+
+func TestCron(t *testing.T) {
+	for _, tc := range []struct {
+		expr     string
+		expected []string
+	}{
+		{"* * * * *", []string{
+			"Wed 2020-01-01 10:31:00 UTC",
+			"Wed 2020-01-01 10:32:00 UTC",
+		}},
+		{"*/20 9-17 * * mon-fri", []string{
+			"Wed 2020-01-01 10:40:00 UTC",
+			"Wed 2020-01-01 11:00:00 UTC",
+		}},
+		{"0 9 * * 1-5", []string{
+			"Thu 2020-01-02 09:00:00 UTC",
+			"Fri 2020-01-03 09:00:00 UTC",
+			"Mon 2020-01-06 09:00:00 UTC",
+		}},
+		{"0 0 29 feb *", []string{
+			"Sat 2020-02-29 00:00:00 UTC",
+			"Thu 2024-02-29 00:00:00 UTC",
+		}},
+		{"0 12 13 * fri", []string{
+			"Fri 2020-01-03 12:00:00 UTC",
+			"Fri 2020-01-10 12:00:00 UTC",
+			"Mon 2020-01-13 12:00:00 UTC",
+		}},
+		{"15,45 */6 * JAN,Jul sun,7", []string{
+			"Sun 2020-01-05 00:15:00 UTC",
+			"Sun 2020-01-05 00:45:00 UTC",
+			"Sun 2020-01-05 06:15:00 UTC",
+		}},
+		{"@hourly", []string{
+			"Wed 2020-01-01 11:00:00 UTC",
+			"Wed 2020-01-01 12:00:00 UTC",
+		}},
+		{"@monthly", []string{
+			"Sat 2020-02-01 00:00:00 UTC",
+			"Sun 2020-03-01 00:00:00 UTC",
+		}},
+	} {
+		sched, err := ParseCron(tc.expr)
+		require.NoError(t, err, tc.expr)
+		assertSchedule(t, sched, calendarStart, tc.expected...)
+	}
+
+	for _, invalid := range []string{
+		"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *",
+		"* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *",
+		"*/0 * * * *", "foo * * * *", "@reboot",
+	} {
+		_, err := ParseCron(invalid)
+		require.Error(t, err, "%q", invalid)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	for _, tc := range []struct {
+		spec     string
+		expected []string
+	}{
+		{"Mon..Fri 09:00", []string{
+			"Thu 2020-01-02 09:00:00 UTC",
+			"Fri 2020-01-03 09:00:00 UTC",
+			"Mon 2020-01-06 09:00:00 UTC",
+		}},
+		{"*:0/15", []string{
+			"Wed 2020-01-01 10:45:00 UTC",
+			"Wed 2020-01-01 11:00:00 UTC",
+		}},
+		{"*-*-* *:*:0/20", []string{
+			"Wed 2020-01-01 10:30:20 UTC",
+			"Wed 2020-01-01 10:30:40 UTC",
+			"Wed 2020-01-01 10:31:00 UTC",
+		}},
+		{"Sat,Sunday 2020-*-01..07 12:00", []string{
+			"Sat 2020-01-04 12:00:00 UTC",
+			"Sun 2020-01-05 12:00:00 UTC",
+			"Sat 2020-02-01 12:00:00 UTC",
+		}},
+		{"2021-02-29", nil},
+		{"02-29 06:00:00", []string{
+			"Sat 2020-02-29 06:00:00 UTC",
+			"Thu 2024-02-29 06:00:00 UTC",
+		}},
+		{"quarterly", []string{
+			"Wed 2020-04-01 00:00:00 UTC",
+			"Wed 2020-07-01 00:00:00 UTC",
+		}},
+		{"weekly", []string{
+			"Mon 2020-01-06 00:00:00 UTC",
+			"Mon 2020-01-13 00:00:00 UTC",
+		}},
+		{"*-*-* 09:00 Asia/Kolkata", []string{
+			"Thu 2020-01-02 09:00:00 IST",
+			"Fri 2020-01-03 09:00:00 IST",
+		}},
+	} {
+		sched, err := ParseCalendar(tc.spec)
+		if tc.expected == nil {
+			if err == nil {
+				require.True(t, sched.Next(calendarStart).IsZero(),
+					"%q should never match", tc.spec)
+			}
+			continue
+		}
+		require.NoError(t, err, tc.spec)
+		assertSchedule(t, sched, calendarStart, tc.expected...)
+	}
+
+	for _, invalid := range []string{
+		"", "Mon..Foo", "25:00", "*-13-01", "1969-01-01", "*:*:*:*",
+		"10:00 11:00 12:00", "*-*-* 10:00 Not/AZone",
+	} {
+		_, err := ParseCalendar(invalid)
+		require.Error(t, err, "%q", invalid)
+	}
+}
+
+func TestCalendarDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Timezone data unavailable: %v", err)
+	}
+
+	// 02:30 does not exist on 2021-03-14 in New York.
+	daily, _ := ParseCalendar("*-*-* 02:30")
+	assertSchedule(t, daily, time.Date(2021, time.March, 13, 12, 0, 0, 0, ny),
+		"Mon 2021-03-15 02:30:00 EDT",
+		"Tue 2021-03-16 02:30:00 EDT")
+
+	hourly, _ := ParseCron("30 * * * *")
+	assertSchedule(t, hourly, time.Date(2021, time.March, 14, 0, 45, 0, 0, ny),
+		"Sun 2021-03-14 01:30:00 EST",
+		"Sun 2021-03-14 03:30:00 EDT",
+		"Sun 2021-03-14 04:30:00 EDT")
+	assertSchedule(t, hourly, time.Date(2021, time.November, 7, 0, 45, 0, 0, ny),
+		"Sun 2021-11-07 01:30:00 EDT",
+		"Sun 2021-11-07 02:30:00 EST")
+
+	// Starting in the repeated hour should not trigger again for the same
+	// wall clock time.
+	repeated := time.Date(2021, time.November, 7, 1, 40, 0, 0, ny).Add(time.Hour)
+	require.Equal(t, "01:40 EST", repeated.Format("15:04 MST"))
+	assertSchedule(t, hourly, repeated, "Sun 2021-11-07 02:30:00 EST")
+
+	midnight, _ := ParseCron("@midnight")
+	sp, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skipf("Timezone data unavailable: %v", err)
+	}
+	// Midnight did not exist on 2018-11-04 in Sao Paulo.
+	assertSchedule(t, midnight, time.Date(2018, time.November, 3, 12, 0, 0, 0, sp),
+		"Mon 2018-11-05 00:00:00 -02",
+		"Tue 2018-11-06 00:00:00 -02")
+}
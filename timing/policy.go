@@ -0,0 +1,85 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"time"
+
+	"github.com/leosunmo/barista/base/value"
+	l "github.com/leosunmo/barista/logging"
+)
+
+// IntervalPolicy adjusts the interval of repeating schedulers, for example
+// to poll less often while running on battery. It receives the interval
+// requested using Every, and returns the interval to use instead.
+type IntervalPolicy func(time.Duration) time.Duration
+
+// Stretch returns an IntervalPolicy that multiplies all intervals by factor.
+func Stretch(factor float64) IntervalPolicy {
+	return func(interval time.Duration) time.Duration {
+		return time.Duration(float64(interval) * factor)
+	}
+}
+
+// IntervalTable returns an IntervalPolicy that replaces any interval present
+// in the table with the corresponding value, and leaves all other intervals
+// unchanged.
+func IntervalTable(table map[time.Duration]time.Duration) IntervalPolicy {
+	return func(interval time.Duration) time.Duration {
+		if replacement, ok := table[interval]; ok {
+			return replacement
+		}
+		return interval
+	}
+}
+
+var intervalPolicy value.Value // of IntervalPolicy
+
+// SetIntervalPolicy sets the policy used to adjust the interval of all
+// schedulers repeating using Every, including existing ones, which rearm
+// themselves as soon as they see the change. A nil policy restores the
+// requested intervals.
+//
+// Schedulers using EveryAlign are not affected, since they are usually
+// aligned to the wall clock for display (e.g. a clock).
+func SetIntervalPolicy(policy IntervalPolicy) {
+	l.Fine("SetIntervalPolicy(%v)", policy != nil)
+	intervalPolicy.Set(policy)
+}
+
+// AdjustInterval returns the interval to use for a repeating trigger with
+// the given interval, after applying the current IntervalPolicy. It's
+// intended for code that manages its own repeating triggers.
+func AdjustInterval(interval time.Duration) time.Duration {
+	policy, _ := intervalPolicy.Get().(IntervalPolicy)
+	if policy == nil {
+		return interval
+	}
+	if adjusted := policy(interval); adjusted > 0 {
+		return adjusted
+	}
+	return interval
+}
+
+// IntervalPolicyChanged returns a channel that will be closed when the
+// IntervalPolicy next changes.
+func IntervalPolicyChanged() <-chan struct{} {
+	return intervalPolicy.Next()
+}
+
+// resetIntervalPolicy clears the policy.
+func resetIntervalPolicy() {
+	intervalPolicy.Set(IntervalPolicy(nil))
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leosunmo/barista/testing/notifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntervalPolicies(t *testing.T) {
+	require.Equal(t, 5*time.Second, Stretch(2.5)(2*time.Second))
+	require.Equal(t, time.Second, Stretch(0.5)(2*time.Second))
+
+	table := IntervalTable(map[time.Duration]time.Duration{
+		time.Second: 10 * time.Second,
+		time.Minute: 5 * time.Minute,
+	})
+	require.Equal(t, 10*time.Second, table(time.Second))
+	require.Equal(t, 5*time.Minute, table(time.Minute))
+	require.Equal(t, 3*time.Second, table(3*time.Second), "unlisted interval")
+}
+
+// waitForTrigger waits until the scheduler is set to trigger at the given
+// time, since schedulers rearm asynchronously when the policy changes.
+func waitForTrigger(t *testing.T, s *Scheduler, when time.Time, msg string) {
+	require.Eventually(t, func() bool {
+		triggersMu.Lock()
+		defer triggersMu.Unlock()
+		for _, tr := range triggers {
+			if tr.what == s.schedulerImpl && tr.when.Equal(when) {
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond, msg)
+}
+
+func TestIntervalPolicy_TestMode(t *testing.T) {
+	TestMode()
+	defer ExitTestMode()
+
+	require.Equal(t, time.Second, AdjustInterval(time.Second), "without policy")
+
+	every := NewScheduler().Every(time.Second)
+	aligned := NewScheduler().EveryAlign(3*time.Second, 0)
+	once := NewScheduler().After(2 * time.Second)
+	start := Now()
+
+	changed := IntervalPolicyChanged()
+	SetIntervalPolicy(Stretch(5))
+	notifier.AssertClosed(t, changed, "when policy is set")
+	require.Equal(t, 10*time.Second, AdjustInterval(2*time.Second))
+	waitForTrigger(t, every, start.Add(5*time.Second), "rearmed on policy change")
+
+	require.Equal(t, start.Add(2*time.Second), NextTick())
+	notifier.AssertNotified(t, once.C, "one-off trigger unaffected")
+	require.Equal(t, start.Add(3*time.Second), NextTick())
+	notifier.AssertNotified(t, aligned.C, "aligned trigger unaffected")
+	notifier.AssertNoUpdate(t, every.C, "interval stretched")
+	require.Equal(t, start.Add(5*time.Second), NextTick())
+	notifier.AssertNotified(t, every.C, "at stretched interval")
+
+	SetIntervalPolicy(func(time.Duration) time.Duration { return -1 })
+	require.Equal(t, time.Minute, AdjustInterval(time.Minute),
+		"invalid adjustment ignored")
+
+	SetIntervalPolicy(nil)
+	aligned.Stop()
+	start = Now()
+	waitForTrigger(t, every, start.Add(time.Second), "rearmed on policy change")
+	require.Equal(t, start.Add(time.Second), NextTick(),
+		"restored immediately")
+	notifier.AssertNotified(t, every.C)
+
+	SetIntervalPolicy(Stretch(2))
+	every.Stop()
+	require.Nil(t, every.stopPolicy, "stops watching policy when stopped")
+	require.Equal(t, start.Add(time.Second), NextTick(),
+		"stopped scheduler not rearmed")
+
+	SetIntervalPolicy(Stretch(3))
+	TestMode()
+	require.Equal(t, time.Second, AdjustInterval(time.Second),
+		"policy cleared in new test")
+}
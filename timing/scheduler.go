@@ -37,6 +37,13 @@ type Scheduler struct {
 	waiting  int32 // basically bool, but we need atomics.
 
 	schedulerImpl schedulerImpl
+
+	// The interval requested using Every, if that is the current trigger,
+	// so that it can be rearmed when the IntervalPolicy changes.
+	intervalMu sync.Mutex
+	interval   time.Duration
+	// Closed to stop watching the IntervalPolicy when no longer repeating.
+	stopPolicy chan struct{}
 }
 
 var (
@@ -54,6 +61,7 @@ type schedulerImpl interface {
 	After(time.Duration, func())
 	Every(time.Duration, func())
 	EveryAlign(time.Duration, time.Duration, func())
+	Schedule(Schedule, func())
 	Stop()
 	Close()
 }
@@ -113,7 +121,7 @@ func (s *Scheduler) Tick() bool {
 // This will replace any pending triggers.
 func (s *Scheduler) At(when time.Time) *Scheduler {
 	l.Fine("%s At(%v)", l.ID(s), when)
-	s.schedulerImpl.At(when, s.maybeTrigger)
+	s.setInterval(0, func() { s.schedulerImpl.At(when, s.maybeTrigger) })
 	return s
 }
 
@@ -121,18 +129,21 @@ func (s *Scheduler) At(when time.Time) *Scheduler {
 // This will replace any pending triggers.
 func (s *Scheduler) After(delay time.Duration) *Scheduler {
 	l.Fine("%s After(%v)", l.ID(s), delay)
-	s.schedulerImpl.After(delay, s.maybeTrigger)
+	s.setInterval(0, func() { s.schedulerImpl.After(delay, s.maybeTrigger) })
 	return s
 }
 
-// Every sets the scheduler to trigger at an interval.
+// Every sets the scheduler to trigger at an interval. The interval is
+// adjusted by the current IntervalPolicy, if any.
 // This will replace any pending triggers.
 func (s *Scheduler) Every(interval time.Duration) *Scheduler {
 	if interval <= 0 {
 		panic(errors.New("non-positive interval for Scheduler#Every"))
 	}
 	l.Fine("%s Every(%v)", l.ID(s), interval)
-	s.schedulerImpl.Every(interval, s.maybeTrigger)
+	s.setInterval(interval, func() {
+		s.schedulerImpl.Every(AdjustInterval(interval), s.maybeTrigger)
+	})
 	return s
 }
 
@@ -155,20 +166,106 @@ func (s *Scheduler) EveryAlign(interval time.Duration, offset time.Duration) *Sc
 		panic(errors.New("negative offset for Scheduler#EveryAlign"))
 	}
 	l.Fine("%s EveryAlign(%v, %v)", l.ID(s), interval, offset)
-	s.schedulerImpl.EveryAlign(interval, offset, s.maybeTrigger)
+	s.setInterval(0, func() { s.schedulerImpl.EveryAlign(interval, offset, s.maybeTrigger) })
+	return s
+}
+
+// Cron sets the scheduler to trigger at times matching a cron expression,
+// e.g. "0 9-17 * * mon-fri" for every hour during work hours. See ParseCron
+// for the supported syntax. It panics if the expression is invalid, use
+// ParseCron and Schedule to handle the error instead.
+//
+// This will replace any pending triggers.
+func (s *Scheduler) Cron(expr string) *Scheduler {
+	sched, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s.Schedule(sched)
+}
+
+// OnCalendar sets the scheduler to trigger at times matching a calendar
+// event specification, e.g. "Mon..Fri 09:00". See ParseCalendar for the
+// supported syntax. It panics if the specification is invalid, use
+// ParseCalendar and Schedule to handle the error instead.
+//
+// This will replace any pending triggers.
+func (s *Scheduler) OnCalendar(spec string) *Scheduler {
+	sched, err := ParseCalendar(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s.Schedule(sched)
+}
+
+// Schedule sets the scheduler to trigger at each time in the schedule.
+// Unless the schedule has its own time zone, times are evaluated in the
+// machine's local time zone, and are recomputed if the zone changes.
+//
+// Wall clock times skipped by a DST transition do not trigger, and times
+// repeated by a DST transition trigger only once.
+//
+// This will replace any pending triggers.
+func (s *Scheduler) Schedule(sched Schedule) *Scheduler {
+	l.Fine("%s Schedule(%v)", l.ID(s), sched)
+	s.setInterval(0, func() { s.schedulerImpl.Schedule(sched, s.maybeTrigger) })
 	return s
 }
 
 // Stop cancels all further triggers for the scheduler.
 func (s *Scheduler) Stop() {
 	l.Fine("%s Stop", l.ID(s))
-	s.schedulerImpl.Stop()
+	s.setInterval(0, func() { s.schedulerImpl.Stop() })
 }
 
 // Close cleans up all resources allocated by the scheduler, if necessary.
 func (s *Scheduler) Close() {
 	l.Fine("%s Close", l.ID(s))
-	s.schedulerImpl.Close()
+	s.setInterval(0, func() { s.schedulerImpl.Close() })
+}
+
+// setInterval records the interval requested using Every, or 0 for any
+// other trigger, and replaces the trigger using fn. While repeating, the
+// scheduler watches for IntervalPolicy changes to rearm itself.
+func (s *Scheduler) setInterval(interval time.Duration, fn func()) {
+	s.intervalMu.Lock()
+	defer s.intervalMu.Unlock()
+	// Before fn, so that a policy change while arming is not missed.
+	changed := IntervalPolicyChanged()
+	fn()
+	s.interval = interval
+	if interval > 0 && s.stopPolicy == nil {
+		s.stopPolicy = make(chan struct{})
+		go s.watchIntervalPolicy(changed, s.stopPolicy)
+	}
+	if interval == 0 && s.stopPolicy != nil {
+		close(s.stopPolicy)
+		s.stopPolicy = nil
+	}
+}
+
+// watchIntervalPolicy rearms the scheduler each time the IntervalPolicy
+// changes, until stop is closed.
+func (s *Scheduler) watchIntervalPolicy(changed <-chan struct{}, stop <-chan struct{}) {
+	for {
+		select {
+		case <-changed:
+			changed = IntervalPolicyChanged()
+			s.applyIntervalPolicy()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// applyIntervalPolicy rearms the scheduler using the current IntervalPolicy,
+// if it's repeating using Every.
+func (s *Scheduler) applyIntervalPolicy() {
+	s.intervalMu.Lock()
+	defer s.intervalMu.Unlock()
+	if s.interval > 0 {
+		s.schedulerImpl.Every(AdjustInterval(s.interval), s.maybeTrigger)
+	}
 }
 
 func (s *Scheduler) maybeTrigger() {
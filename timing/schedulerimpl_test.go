@@ -157,4 +157,28 @@ func testSchedulerImplementation(t *testing.T, create func() *Scheduler) {
 		}
 	})
 
+	t.Run("Schedule", func(t *testing.T) {
+		s := create()
+		defer s.Close()
+
+		s.OnCalendar("*:*:*")
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-s.C:
+				now := time.Now()
+				if now.Sub(now.Truncate(time.Second)) > 10*time.Millisecond {
+					t.Errorf("trigger time was not on schedule (tick %d) now=%v", i, now)
+				}
+			case <-time.After(2 * time.Second):
+				t.Errorf("scheduler did not trigger (tick %d)", i)
+			}
+		}
+		s.Stop()
+		select {
+		case <-s.C:
+			t.Error("scheduler triggered even though stopped")
+		case <-time.After(2 * time.Second):
+		}
+	})
 }
@@ -31,6 +31,7 @@ type testModeScheduler struct {
 	testModeID  uint32
 	interval    time.Duration
 	alignOffset time.Duration
+	schedule    Schedule
 	f           func()
 }
 
@@ -106,6 +107,7 @@ func reset(fn func()) {
 	triggersMu.Lock()
 	defer triggersMu.Unlock()
 	fn()
+	resetIntervalPolicy()
 	waiters = nil
 	triggers = nil
 	paused = false
@@ -132,6 +134,7 @@ func (s *testModeScheduler) At(when time.Time, f func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	l.Fine("%s At[Test](%v)", l.ID(s), when)
+	s.schedule = nil
 	s.f = f
 	s.setNextTrigger(when)
 }
@@ -141,6 +144,7 @@ func (s *testModeScheduler) After(delay time.Duration, f func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	l.Fine("%s After[Test](%v)", l.ID(s), delay)
+	s.schedule = nil
 	s.f = f
 	s.setNextTrigger(Now().Add(delay))
 }
@@ -151,6 +155,7 @@ func (s *testModeScheduler) Every(interval time.Duration, f func()) {
 	defer s.mu.Unlock()
 	l.Fine("%s Every[Test](%v)", l.ID(s), interval)
 	s.interval = interval
+	s.schedule = nil
 	s.alignOffset = Now().Sub(Now().Truncate(interval))
 	s.f = f
 	s.setNextTrigger(s.nextRepeatingTick())
@@ -162,16 +167,29 @@ func (s *testModeScheduler) EveryAlign(interval time.Duration, offset time.Durat
 	defer s.mu.Unlock()
 	l.Fine("%s EveryAlign[Test](%v)", l.ID(s), interval)
 	s.interval = interval
+	s.schedule = nil
 	s.alignOffset = offset
 	s.f = f
 	s.setNextTrigger(s.nextRepeatingTick())
 }
 
+// Schedule implements the schedulerImpl interface.
+func (s *testModeScheduler) Schedule(sched Schedule, f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l.Fine("%s Schedule[Test](%v)", l.ID(s), sched)
+	s.interval = 0
+	s.schedule = sched
+	s.f = f
+	s.setNextTrigger(s.nextRepeatingTick())
+}
+
 // Stop implements the schedulerImpl interface.
 func (s *testModeScheduler) Stop() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	l.Fine("%s Stop[Test]", l.ID(s))
+	s.schedule = nil
 	s.f = nil
 	s.setNextTrigger(time.Time{})
 }
@@ -181,7 +199,14 @@ func (s *testModeScheduler) Close() {
 	s.Stop()
 }
 
+func (s *testModeScheduler) repeating() bool {
+	return s.interval > 0 || s.schedule != nil
+}
+
 func (s *testModeScheduler) nextRepeatingTick() time.Time {
+	if s.schedule != nil {
+		return s.schedule.Next(Now())
+	}
 	return nextAlignedExpiration(Now(), s.interval, s.alignOffset)
 }
 
@@ -232,9 +257,11 @@ func advanceToLocked(newTime time.Time) time.Time {
 		if triggers[i].when.After(nextTick) {
 			break
 		}
-		if t.what.interval > 0 {
+		if t.what.repeating() {
 			t.when = t.what.nextRepeatingTick()
-			triggers = append(triggers, t)
+			if !t.when.IsZero() {
+				triggers = append(triggers, t)
+			}
 		}
 		idx = i + 1
 		t.what.f()
@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/leosunmo/barista/base/watchers/localtz"
 	"github.com/leosunmo/barista/testing/notifier"
 	"github.com/stretchr/testify/require"
 )
@@ -120,6 +121,54 @@ func TestRepeatingChange_TestMode(t *testing.T) {
 	require.Equal(t, now.Add(3*time.Hour), NextTick())
 }
 
+func TestSchedule_TestMode(t *testing.T) {
+	TestMode()
+	defer ExitTestMode()
+	// Test mode starts on Friday, 2016-11-25 20:47:00 UTC.
+	sch := NewScheduler()
+
+	sch.Cron("0 9-17/4 * * mon-fri")
+	require.Equal(t, "Mon 09:00", NextTick().Format("Mon 15:04"))
+	notifier.AssertNotified(t, sch.C, "on cron schedule")
+	require.Equal(t, "Mon 13:00", NextTick().Format("Mon 15:04"))
+	require.Equal(t, "Mon 17:00", NextTick().Format("Mon 15:04"))
+	require.Equal(t, "Tue 09:00", NextTick().Format("Mon 15:04"))
+
+	sch.OnCalendar("Sat,Sun *-*-* 10:00:30")
+	require.Equal(t, "Sat 2016-12-03 10:00:30",
+		NextTick().Format("Mon 2006-01-02 15:04:05"))
+	notifier.AssertNotified(t, sch.C, "on calendar schedule")
+
+	sched, _ := ParseCalendar("2016-12-04")
+	sch.Schedule(sched)
+	require.Equal(t, "2016-12-04 00:00", NextTick().Format("2006-01-02 15:04"))
+	notifier.AssertNotified(t, sch.C, "on last trigger")
+	now := Now()
+	require.Equal(t, now, NextTick(), "no ticks after last trigger")
+
+	sch.Cron("@hourly")
+	sch.Every(time.Minute)
+	require.Equal(t, now.Add(time.Minute), NextTick(), "schedule replaced")
+
+	require.Panics(t, func() { sch.Cron("not a cron") })
+	require.Panics(t, func() { sch.OnCalendar("not a calendar") })
+}
+
+func TestScheduleTimezone_TestMode(t *testing.T) {
+	TestMode()
+	defer ExitTestMode()
+	tz, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("Timezone data unavailable: %v", err)
+	}
+	localtz.SetForTest(tz)
+	sch := NewScheduler().OnCalendar("daily")
+	tick := NextTick()
+	require.Equal(t, "2016-11-27 00:00 IST", tick.Format("2006-01-02 15:04 MST"),
+		"schedule evaluated in local time zone")
+	notifier.AssertNotified(t, sch.C)
+}
+
 func TestMultipleTriggers_TestMode(t *testing.T) {
 	TestMode()
 	defer ExitTestMode()
@@ -3,6 +3,8 @@ package timing
 import (
 	"sync"
 	"time"
+
+	"github.com/leosunmo/barista/base/watchers/localtz"
 )
 
 var _ schedulerImpl = &timeScheduler{}
@@ -13,43 +15,145 @@ type timeScheduler struct {
 	timer   *time.Timer
 	ticker  *time.Ticker
 	quitter chan struct{}
+
+	// The wall clock time of the next trigger, and the function to
+	// re-apply the current trigger (with mu held), used to recover from
+	// system suspend. The generation is incremented whenever the trigger
+	// changes, so that Wake does not rearm a trigger that was replaced.
+	deadline   time.Time
+	f          func()
+	rearm      func()
+	repeating  bool
+	generation uint64
 }
 
+// All schedulers backed by the "time" package, so that they can be
+// triggered on Wake.
+var (
+	timeSchedulers   = map[*timeScheduler]struct{}{}
+	timeSchedulersMu sync.Mutex
+)
+
 // NewScheduler creates a new scheduler.
 //
 // The scheduler is backed by "time" package. Its "At" implementation
 // is unreliable, as it's unable to take system suspend and time adjustments
-// into account.
+// into account, unless Wake is called when the system resumes.
 func NewScheduler() *Scheduler {
 	if testModeScheduler := maybeNewTestModeScheduler(); testModeScheduler != nil {
 		return newScheduler(testModeScheduler)
 	}
-	return newScheduler(&timeScheduler{})
+	s := &timeScheduler{}
+	timeSchedulersMu.Lock()
+	timeSchedulers[s] = struct{}{}
+	timeSchedulersMu.Unlock()
+	return newScheduler(s)
+}
+
+// Wake triggers any schedulers that should have triggered while the system
+// was suspended, and rearms all others against the wall clock. It should be
+// called when the system resumes from suspend, since the timers used by
+// NewScheduler do not advance while the system is suspended.
+//
+// Schedulers created by NewRealtimeScheduler already handle suspend, and
+// schedulers in test mode are not affected.
+func Wake() {
+	timeSchedulersMu.Lock()
+	var all []*timeScheduler
+	for s := range timeSchedulers {
+		all = append(all, s)
+	}
+	timeSchedulersMu.Unlock()
+	// Strip the monotonic clock reading, which also does not advance while
+	// the system is suspended.
+	now := time.Now().Round(0)
+	for _, s := range all {
+		s.wake(now)
+	}
+}
+
+func (s *timeScheduler) wake(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deadline.IsZero() {
+		return
+	}
+	if s.deadline.After(now) {
+		s.rearm()
+		return
+	}
+	f, repeating, generation := s.f, s.repeating, s.generation
+	if !repeating {
+		s.stop()
+	}
+	s.mu.Unlock()
+	f()
+	s.mu.Lock()
+	// The trigger may have been changed while unlocked, either by f or
+	// concurrently, in which case the new trigger takes precedence.
+	if repeating && s.generation == generation {
+		s.rearm()
+	}
+}
+
+// trackLocked records the next trigger of the scheduler. If repeating, the
+// rearm function is called after the expired trigger on Wake.
+func (s *timeScheduler) trackLocked(deadline time.Time, f func(), repeating bool, rearm func()) {
+	s.deadline = deadline.Round(0)
+	s.f = f
+	s.repeating = repeating
+	s.rearm = rearm
+	s.generation++
+}
+
+// setDeadline updates the wall clock time of the next repeating trigger.
+func (s *timeScheduler) setDeadline(quitter chan struct{}, deadline time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quitter == quitter {
+		s.deadline = deadline.Round(0)
+	}
 }
 
 // At implements the schedulerImpl interface.
 func (s *timeScheduler) At(when time.Time, f func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.atLocked(when, f)
+}
+
+func (s *timeScheduler) atLocked(when time.Time, f func()) {
 	s.stop()
-	s.timer = time.AfterFunc(when.Sub(Now()), f)
+	var timer *time.Timer
+	timer = time.AfterFunc(when.Sub(Now()), func() {
+		s.mu.Lock()
+		if s.timer == timer {
+			s.deadline = time.Time{}
+		}
+		s.mu.Unlock()
+		f()
+	})
+	s.timer = timer
+	s.trackLocked(when, f, false, func() { s.atLocked(when, f) })
 }
 
 // After implements the schedulerImpl interface.
 func (s *timeScheduler) After(delay time.Duration, f func()) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.stop()
-	s.timer = time.AfterFunc(delay, f)
+	s.At(Now().Add(delay), f)
 }
 
 // Every implements the schedulerImpl interface.
 func (s *timeScheduler) Every(interval time.Duration, f func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.everyLocked(interval, f)
+}
+
+func (s *timeScheduler) everyLocked(interval time.Duration, f func()) {
 	s.stop()
 	s.quitter = make(chan struct{})
 	s.ticker = time.NewTicker(interval)
+	s.trackLocked(Now().Add(interval), f, true, func() { s.everyLocked(interval, f) })
 	go func() {
 		s.mu.Lock()
 		ticker := s.ticker
@@ -62,6 +166,7 @@ func (s *timeScheduler) Every(interval time.Duration, f func()) {
 		for {
 			select {
 			case <-ticker.C:
+				s.setDeadline(quitter, Now().Add(interval))
 				f()
 			case <-quitter:
 				return
@@ -74,14 +179,21 @@ func (s *timeScheduler) Every(interval time.Duration, f func()) {
 func (s *timeScheduler) EveryAlign(interval time.Duration, offset time.Duration, f func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.everyAlignLocked(interval, offset, f)
+}
+
+func (s *timeScheduler) everyAlignLocked(interval time.Duration, offset time.Duration, f func()) {
 	s.stop()
 	quitter := make(chan struct{})
 	s.quitter = quitter
+	s.trackLocked(nextAlignedExpiration(time.Now(), interval, offset), f, true,
+		func() { s.everyAlignLocked(interval, offset, f) })
 	go func() {
 		var timer *time.Timer
 		for {
 			now := time.Now()
 			next := nextAlignedExpiration(now, interval, offset)
+			s.setDeadline(quitter, next)
 			delay := next.Sub(now)
 			if timer == nil {
 				timer = time.NewTimer(delay)
@@ -99,6 +211,41 @@ func (s *timeScheduler) EveryAlign(interval time.Duration, offset time.Duration,
 	}()
 }
 
+// Schedule implements the schedulerImpl interface.
+func (s *timeScheduler) Schedule(sched Schedule, f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scheduleLocked(sched, f)
+}
+
+func (s *timeScheduler) scheduleLocked(sched Schedule, f func()) {
+	s.stop()
+	quitter := make(chan struct{})
+	s.quitter = quitter
+	s.trackLocked(sched.Next(Now()), f, true, func() { s.scheduleLocked(sched, f) })
+	go func() {
+		for {
+			tzChanged := localtz.Next()
+			now := Now()
+			next := sched.Next(now)
+			s.setDeadline(quitter, next)
+			if next.IsZero() {
+				return
+			}
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-timer.C:
+				f()
+			case <-tzChanged:
+				timer.Stop()
+			case <-quitter:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
 // Stop implements the schedulerImpl interface.
 func (s *timeScheduler) Stop() {
 	s.mu.Lock()
@@ -109,6 +256,9 @@ func (s *timeScheduler) Stop() {
 // Close implements the schedulerImpl interface.
 func (s *timeScheduler) Close() {
 	s.Stop()
+	timeSchedulersMu.Lock()
+	delete(timeSchedulers, s)
+	timeSchedulersMu.Unlock()
 }
 
 func (s *timeScheduler) stop() {
@@ -124,4 +274,5 @@ func (s *timeScheduler) stop() {
 		close(s.quitter)
 		s.quitter = nil
 	}
+	s.trackLocked(time.Time{}, nil, false, nil)
 }
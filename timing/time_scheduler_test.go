@@ -16,8 +16,72 @@ package timing
 
 import (
 	"testing"
+	"time"
+
+	"github.com/leosunmo/barista/testing/notifier"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTimeSchedulerImpl(t *testing.T) {
 	testSchedulerImplementation(t, NewScheduler)
 }
+
+func TestWake(t *testing.T) {
+	ExitTestMode()
+	sch := NewScheduler().After(time.Hour)
+	defer sch.Close()
+	impl := sch.schedulerImpl.(*timeScheduler)
+	// Simulates the passage of wall clock time while the system is suspended.
+	suspend := func() {
+		impl.mu.Lock()
+		defer impl.mu.Unlock()
+		impl.deadline = impl.deadline.Add(-2 * time.Hour)
+	}
+
+	Wake()
+	notifier.AssertNoUpdate(t, sch.C, "before deadline")
+
+	suspend()
+	Wake()
+	notifier.AssertNotified(t, sch.C, "on wake after deadline")
+	Wake()
+	notifier.AssertNoUpdate(t, sch.C, "one-shot trigger only fires once")
+
+	sch.Every(time.Hour)
+	suspend()
+	Wake()
+	notifier.AssertNotified(t, sch.C, "on wake after repeating deadline")
+	impl.mu.Lock()
+	require.True(t, impl.deadline.After(time.Now()), "repeating trigger rearmed")
+	impl.mu.Unlock()
+
+	sch.OnCalendar("*-*-* *:00:00")
+	suspend()
+	Wake()
+	notifier.AssertNotified(t, sch.C, "on wake after scheduled time")
+
+	sch.Stop()
+	Wake()
+	notifier.AssertNoUpdate(t, sch.C, "when stopped")
+}
+
+func TestWakeReschedule(t *testing.T) {
+	ExitTestMode()
+	impl := &timeScheduler{}
+	defer impl.Close()
+	later := time.Now().Add(3 * time.Hour).Round(0)
+	impl.Every(time.Hour, func() {
+		// Changing the trigger when fired, e.g. from the scheduler's
+		// consumer, replaces the expired repeating trigger.
+		impl.At(later, func() {})
+	})
+	impl.mu.Lock()
+	impl.deadline = impl.deadline.Add(-2 * time.Hour)
+	impl.mu.Unlock()
+
+	impl.wake(time.Now().Round(0))
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	require.False(t, impl.repeating, "new trigger not overwritten by rearm")
+	require.Equal(t, later, impl.deadline)
+}
@@ -25,6 +25,7 @@ import (
 
 	"golang.org/x/sys/unix"
 
+	"github.com/leosunmo/barista/base/watchers/localtz"
 	l "github.com/leosunmo/barista/logging"
 	"github.com/leosunmo/barista/timing/internal/timerfd"
 )
@@ -37,7 +38,10 @@ type timerfdScheduler struct {
 	timerfd  *timerfd.Timerfd
 	interval time.Duration
 	offset   time.Duration
+	schedule Schedule
 	f        func()
+	// Closed to stop watching for time zone changes to a schedule.
+	tzQuitter chan struct{}
 }
 
 // NewRealtimeScheduler creates a scheduler backed by system real-time clock.
@@ -86,6 +90,7 @@ func (s *timerfdScheduler) At(when time.Time, f func()) {
 	}
 
 	s.interval = 0
+	s.clearScheduleLocked()
 	s.f = f
 }
 
@@ -102,6 +107,7 @@ func (s *timerfdScheduler) After(delay time.Duration, f func()) {
 	}
 
 	s.interval = 0
+	s.clearScheduleLocked()
 	s.f = f
 }
 
@@ -123,21 +129,86 @@ func (s *timerfdScheduler) EveryAlign(interval time.Duration, offset time.Durati
 
 	s.interval = interval
 	s.offset = offset - offset.Truncate(interval)
+	s.clearScheduleLocked()
 	s.f = f
 
 	s.rearmPeriodicTimerLocked()
 }
 
+// Schedule implements the schedulerImpl interface.
+func (s *timerfdScheduler) Schedule(sched Schedule, f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.interval = 0
+	s.clearScheduleLocked()
+	s.schedule = sched
+	s.f = f
+	s.rearmScheduleLocked()
+
+	quitter := make(chan struct{})
+	s.tzQuitter = quitter
+	go func() {
+		for {
+			select {
+			case <-localtz.Next():
+				s.mu.Lock()
+				s.rearmScheduleLocked()
+				s.mu.Unlock()
+			case <-quitter:
+				return
+			}
+		}
+	}()
+}
+
 // Stop implements the schedulerImpl interface.
 func (s *timerfdScheduler) Stop() {
+	s.mu.Lock()
+	s.clearScheduleLocked()
+	s.mu.Unlock()
 	_ = s.timerfd.Settime(&unix.ItimerSpec{}, nil, false, false)
 }
 
 // Close implements the schedulerImpl interface.
 func (s *timerfdScheduler) Close() {
+	s.mu.Lock()
+	s.clearScheduleLocked()
+	s.mu.Unlock()
 	s.timerfd.Close()
 }
 
+func (s *timerfdScheduler) clearScheduleLocked() {
+	s.schedule = nil
+	if s.tzQuitter != nil {
+		close(s.tzQuitter)
+		s.tzQuitter = nil
+	}
+}
+
+// rearmScheduleLocked arms the timer for the next time in the schedule,
+// evaluated in the current local time zone.
+func (s *timerfdScheduler) rearmScheduleLocked() {
+	if s.schedule == nil {
+		return
+	}
+	next := s.schedule.Next(Now())
+	if next.IsZero() {
+		_ = s.timerfd.Settime(&unix.ItimerSpec{}, nil, false, false)
+		return
+	}
+	timespec, err := unix.TimeToTimespec(next)
+	if err != nil {
+		panic("rearmSchedule failed: " + err.Error())
+	}
+	err = s.timerfd.Settime(&unix.ItimerSpec{
+		Value: timespec,
+	}, nil, true, false)
+	if err != nil {
+		panic("rearmSchedule failed: " + err.Error())
+	}
+}
+
 // rearmPeriodicTimerLocked panics when an error occurs,
 // because any errors are not handleable runtime failures,
 // but rather extreme conditions. See comments near panics below.
@@ -183,6 +254,7 @@ func (s *timerfdScheduler) loop() {
 				s.mu.Lock()
 				f := s.f
 				s.rearmPeriodicTimerLocked()
+				s.rearmScheduleLocked()
 				s.mu.Unlock()
 				f()
 			} else if err == os.ErrClosed {
@@ -195,6 +267,7 @@ func (s *timerfdScheduler) loop() {
 		} else {
 			s.mu.Lock()
 			f := s.f
+			s.rearmScheduleLocked()
 			s.mu.Unlock()
 			f()
 		}
@@ -26,6 +26,10 @@ and use the scheduling calls to control the update timing:
 
 	mod.sch.Every(time.Second)
 
+or to follow a calendar schedule:
+
+	mod.sch.Cron("0 9 * * mon-fri")
+
 The Stream() goroutine will then loop over the ticker, and update
 the module with fresh information:
 